@@ -0,0 +1,290 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm/logger"
+)
+
+// DefaultJWTSecret is the development signing key. The server refuses to start
+// with it outside development mode.
+const DefaultJWTSecret = "your_super_secret_key"
+
+// placeholderSecrets are signing keys published with the code, the built-in
+// default and the one in config.example.yaml
+var placeholderSecrets = []string{DefaultJWTSecret, "change-me"}
+
+// Config holds the server settings. Values are read from defaults, then an
+// optional YAML file, then environment variables, each overriding the last.
+type Config struct {
+	Env            string        `yaml:"env"`              // LIBRARY_ENV: development or production (the default)
+	DatabaseDriver string        `yaml:"database_driver"`  // DATABASE_DRIVER: postgres or sqlite
+	DatabaseDSN    string        `yaml:"database_dsn"`     // DATABASE_DSN
+	ListenAddr     string        `yaml:"listen_addr"`      // LISTEN_ADDR
+	JWTSecret      string        `yaml:"jwt_secret"`       // JWT_SECRET
+	TokenTTL       time.Duration `yaml:"token_ttl"`        // TOKEN_TTL: access token lifetime, e.g. "15m"
+	RefreshTTL     time.Duration `yaml:"refresh_ttl"`      // REFRESH_TTL: refresh token lifetime, e.g. "168h"
+	LoanPeriodDays int           `yaml:"loan_period_days"` // LOAN_PERIOD_DAYS
+	MaxLoans       int           `yaml:"max_loans"`        // MAX_LOANS: books a reader may have out per library, 0 for no limit
+	MaxRenewals    int           `yaml:"max_renewals"`     // MAX_RENEWALS: times a loan may be extended
+	HoldPickupDays int           `yaml:"hold_pickup_days"` // HOLD_PICKUP_DAYS: days a reader has to collect a held copy
+	MaxNewCopies   int           `yaml:"max_new_copies"`   // MAX_NEW_COPIES: most copies one request or import row may add
+	FinePerDay     int64         `yaml:"fine_per_day"`     // FINE_PER_DAY: cents charged for each full day a loan is overdue
+	MaxFine        int64         `yaml:"max_fine"`         // MAX_FINE: cap in cents on the fine for one loan, 0 for no cap
+	MaxUnpaidFines int64         `yaml:"max_unpaid_fines"` // MAX_UNPAID_FINES: cents a reader may owe a library and still borrow
+	RequestExpiry  time.Duration `yaml:"request_expiry"`   // REQUEST_EXPIRY: how long a request waits for approval, 0 to wait forever
+	PickupExpiry   time.Duration `yaml:"pickup_expiry"`    // PICKUP_EXPIRY: how long an approved request waits for collection, 0 to wait forever
+	JobInterval    time.Duration `yaml:"job_interval"`     // JOB_INTERVAL: how often background jobs run, e.g. "1m"
+	ImportMaxBytes int64         `yaml:"import_max_bytes"` // IMPORT_MAX_BYTES: largest catalog file accepted for import
+	ImportTimeout  time.Duration `yaml:"import_timeout"`   // IMPORT_TIMEOUT: how long a running import may go without progress before it is requeued
+	LogLevel       string        `yaml:"log_level"`        // LOG_LEVEL: debug, info, warn or error
+	MigrateOnStart bool          `yaml:"migrate_on_start"` // MIGRATE_ON_START: apply pending migrations when serving
+}
+
+// AppConfig holds the settings the server was started with
+var AppConfig = Default()
+
+// Default returns the built-in settings. They run in production mode, so the
+// built-in JWT secret is refused until env is set to development or a real
+// secret is configured.
+func Default() *Config {
+	return &Config{
+		Env:            "production",
+		DatabaseDriver: "postgres",
+		DatabaseDSN:    "host=localhost user=postgres password=postgres dbname=library_management sslmode=disable",
+		ListenAddr:     ":8080",
+		JWTSecret:      DefaultJWTSecret,
+		TokenTTL:       15 * time.Minute,
+		RefreshTTL:     7 * 24 * time.Hour,
+		LoanPeriodDays: 14,
+		MaxLoans:       5,
+		MaxRenewals:    2,
+		HoldPickupDays: 3,
+		MaxNewCopies:   1000,
+		FinePerDay:     25,
+		MaxUnpaidFines: 1000,
+		RequestExpiry:  7 * 24 * time.Hour,
+		PickupExpiry:   3 * 24 * time.Hour,
+		JobInterval:    time.Minute,
+		ImportMaxBytes: 20 << 20,
+		ImportTimeout:  10 * time.Minute,
+		LogLevel:       "info",
+	}
+}
+
+// Load builds the configuration from defaults, the YAML file at path (skipped
+// when path is empty) and the environment, then validates the result
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) applyEnv() error {
+	stringVars := map[string]*string{
+		"LIBRARY_ENV":     &c.Env,
+		"DATABASE_DRIVER": &c.DatabaseDriver,
+		"DATABASE_DSN":    &c.DatabaseDSN,
+		"LISTEN_ADDR":     &c.ListenAddr,
+		"JWT_SECRET":      &c.JWTSecret,
+		"LOG_LEVEL":       &c.LogLevel,
+	}
+	for name, field := range stringVars {
+		if value, ok := os.LookupEnv(name); ok {
+			*field = value
+		}
+	}
+
+	durationVars := map[string]*time.Duration{
+		"TOKEN_TTL":      &c.TokenTTL,
+		"REFRESH_TTL":    &c.RefreshTTL,
+		"REQUEST_EXPIRY": &c.RequestExpiry,
+		"PICKUP_EXPIRY":  &c.PickupExpiry,
+		"JOB_INTERVAL":   &c.JobInterval,
+		"IMPORT_TIMEOUT": &c.ImportTimeout,
+	}
+	for name, field := range durationVars {
+		if value, ok := os.LookupEnv(name); ok {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*field = duration
+		}
+	}
+
+	if value, ok := os.LookupEnv("MIGRATE_ON_START"); ok {
+		migrate, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("MIGRATE_ON_START: %w", err)
+		}
+		c.MigrateOnStart = migrate
+	}
+
+	intVars := map[string]*int{
+		"LOAN_PERIOD_DAYS": &c.LoanPeriodDays,
+		"MAX_LOANS":        &c.MaxLoans,
+		"MAX_RENEWALS":     &c.MaxRenewals,
+		"HOLD_PICKUP_DAYS": &c.HoldPickupDays,
+		"MAX_NEW_COPIES":   &c.MaxNewCopies,
+	}
+	for name, field := range intVars {
+		if value, ok := os.LookupEnv(name); ok {
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*field = number
+		}
+	}
+
+	int64Vars := map[string]*int64{
+		"FINE_PER_DAY":     &c.FinePerDay,
+		"MAX_FINE":         &c.MaxFine,
+		"MAX_UNPAID_FINES": &c.MaxUnpaidFines,
+		"IMPORT_MAX_BYTES": &c.ImportMaxBytes,
+	}
+	for name, field := range int64Vars {
+		if value, ok := os.LookupEnv(name); ok {
+			number, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*field = number
+		}
+	}
+
+	return nil
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.Env != "development" && c.Env != "production" {
+		problems = append(problems, fmt.Sprintf("env must be 'development' or 'production', got %q", c.Env))
+	}
+	if c.DatabaseDriver != "postgres" && c.DatabaseDriver != "sqlite" {
+		problems = append(problems, fmt.Sprintf("database_driver must be 'postgres' or 'sqlite', got %q", c.DatabaseDriver))
+	}
+	if c.DatabaseDSN == "" {
+		problems = append(problems, "database_dsn is required")
+	}
+	if c.ListenAddr == "" {
+		problems = append(problems, "listen_addr is required")
+	}
+	if c.JWTSecret == "" {
+		problems = append(problems, "jwt_secret is required")
+	} else if !c.IsDevelopment() && isPlaceholderSecret(c.JWTSecret) {
+		problems = append(problems, "jwt_secret must be changed from the default outside development (set env to development to use it locally)")
+	}
+	if c.TokenTTL <= 0 {
+		problems = append(problems, "token_ttl must be positive")
+	}
+	if c.RefreshTTL <= c.TokenTTL {
+		problems = append(problems, "refresh_ttl must be longer than token_ttl")
+	}
+	if c.LoanPeriodDays <= 0 {
+		problems = append(problems, "loan_period_days must be positive")
+	}
+	if c.MaxLoans < 0 {
+		problems = append(problems, "max_loans cannot be negative")
+	}
+	if c.MaxRenewals < 0 {
+		problems = append(problems, "max_renewals cannot be negative")
+	}
+	if c.HoldPickupDays <= 0 {
+		problems = append(problems, "hold_pickup_days must be positive")
+	}
+	if c.FinePerDay < 0 {
+		problems = append(problems, "fine_per_day cannot be negative")
+	}
+	if c.MaxFine < 0 {
+		problems = append(problems, "max_fine cannot be negative")
+	}
+	if c.MaxUnpaidFines < 0 {
+		problems = append(problems, "max_unpaid_fines cannot be negative")
+	}
+	if c.RequestExpiry < 0 {
+		problems = append(problems, "request_expiry cannot be negative")
+	}
+	if c.PickupExpiry < 0 {
+		problems = append(problems, "pickup_expiry cannot be negative")
+	}
+	if c.JobInterval <= 0 {
+		problems = append(problems, "job_interval must be positive")
+	}
+	if c.MaxNewCopies <= 0 {
+		problems = append(problems, "max_new_copies must be positive")
+	}
+	if c.ImportMaxBytes <= 0 {
+		problems = append(problems, "import_max_bytes must be positive")
+	}
+	if c.ImportTimeout <= 0 {
+		problems = append(problems, "import_timeout must be positive")
+	}
+	if _, ok := logLevels[strings.ToLower(c.LogLevel)]; !ok {
+		problems = append(problems, fmt.Sprintf("log_level must be one of debug, info, warn, error, got %q", c.LogLevel))
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func isPlaceholderSecret(secret string) bool {
+	for _, placeholder := range placeholderSecrets {
+		if secret == placeholder {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDevelopment reports whether the server runs in development mode
+func (c *Config) IsDevelopment() bool {
+	return c.Env == "development"
+}
+
+// logLevels maps log_level onto gorm's SQL logger, which has no level between
+// logging every statement (Info) and only slow statements and errors (Warn).
+// Every statement is logged at debug only; info, the default, logs like warn so
+// a normal deployment does not write each query to its log.
+var logLevels = map[string]logger.LogLevel{
+	"debug": logger.Info,
+	"info":  logger.Warn,
+	"warn":  logger.Warn,
+	"error": logger.Error,
+}
+
+// GormLogLevel maps the configured log level onto gorm's SQL logger
+func (c *Config) GormLogLevel() logger.LogLevel {
+	if level, ok := logLevels[strings.ToLower(c.LogLevel)]; ok {
+		return level
+	}
+	return logger.Warn
+}
@@ -1,40 +1,61 @@
 package config
 
 import (
-	"library-management/models"
+	"fmt"
 	"log"
+	"strings"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 // DB holds the database connection instance
 var DB *gorm.DB
 
+// openDialector selects the gorm driver for the configured backend
+func openDialector(cfg *Config) (gorm.Dialector, error) {
+	switch cfg.DatabaseDriver {
+	case "postgres":
+		return postgres.Open(cfg.DatabaseDSN), nil
+	case "sqlite":
+		return sqlite.Open(cfg.DatabaseDSN), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.DatabaseDriver)
+	}
+}
+
 // ConnectDatabase initializes the database connection
-func ConnectDatabase() (*gorm.DB, error) {
-	dsn := "host=localhost user=postgres password=postgres dbname=library_management sslmode=disable"
-	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+func ConnectDatabase(cfg *Config) (*gorm.DB, error) {
+	dialector, err := openDialector(cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
 		return nil, err
 	}
 
-	// Auto-migrate database tables
-	err = database.AutoMigrate(
-		&models.Library{},
-		&models.User{},
-		&models.Book{},
-		&models.RequestEvent{},
-		&models.IssueRegistry{},
-		&models.UserLibrary{},
-	)
+	database, err := gorm.Open(dialector, &gorm.Config{
+		Logger: logger.Default.LogMode(cfg.GormLogLevel()),
+	})
 	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
-		return nil, err
+		return nil, fmt.Errorf("connecting to database: %w", err)
 	}
 
+	// Every connection to an in-memory SQLite database sees its own empty
+	// database, so keep exactly one open
+	if cfg.DatabaseDriver == "sqlite" && isSQLiteMemory(cfg.DatabaseDSN) {
+		sqlDB, err := database.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	// Schema changes are applied by the migrations package (see `migrate up`)
 	DB = database
-	log.Println("Database connected and migrated successfully!")
+	log.Println("Database connected successfully!")
 	return DB, nil
 }
+
+func isSQLiteMemory(dsn string) bool {
+	return strings.Contains(dsn, ":memory:") || strings.Contains(dsn, "mode=memory")
+}
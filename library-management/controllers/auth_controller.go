@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"errors"
 	"library-management/models"
+	"library-management/services/tokens"
 	"library-management/utils"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -32,19 +35,96 @@ func Login(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Directly compare passwords
-		if input.Password != user.Password {
+		// Verify the bcrypt hash (legacy rows may still hold plaintext)
+		match, needsRehash := utils.CheckPassword(user.Password, input.Password)
+		if !match {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 			return
 		}
 
-		// Generate JWT token
-		token, err := utils.GenerateJWT(user.ID, user.Role)
+		// Transparently upgrade legacy plaintext passwords to bcrypt
+		if needsRehash {
+			if hash, err := utils.HashPassword(input.Password); err != nil {
+				log.Printf("Could not rehash password for user %d: %v", user.ID, err)
+			} else if err := db.Model(&user).Update("password", hash).Error; err != nil {
+				log.Printf("Could not store rehashed password for user %d: %v", user.ID, err)
+			}
+		}
+
+		// Generate an access token and start a refresh token family
+		pair, err := tokens.Issue(db, user)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"token": token})
+		c.JSON(http.StatusOK, tokenResponse(pair))
+	}
+}
+
+// RefreshToken exchanges a refresh token for a new access/refresh token pair
+func RefreshToken(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			RefreshToken string `json:"refresh_token" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		pair, err := tokens.Rotate(db, input.RefreshToken)
+		if err != nil {
+			switch {
+			case errors.Is(err, tokens.ErrRefreshTokenReused):
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has already been used; all sessions from this login were revoked"})
+			case errors.Is(err, tokens.ErrInvalidRefreshToken):
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not refresh token"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, tokenResponse(pair))
+	}
+}
+
+// Logout revokes the caller's access token and, if supplied, its refresh token family
+func Logout(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+
+		// The body is optional; an empty body only revokes the access token
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		claims, exists := c.Get("tokenClaims")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		if err := tokens.Logout(db, claims.(utils.TokenClaims), input.RefreshToken); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not log out"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+	}
+}
+
+func tokenResponse(pair tokens.Pair) gin.H {
+	return gin.H{
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    pair.ExpiresIn,
 	}
 }
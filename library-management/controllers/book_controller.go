@@ -1,20 +1,30 @@
 package controllers
 
 import (
+	"fmt"
+	"library-management/config"
+	"library-management/middleware"
 	"library-management/models"
+	"library-management/services/holds"
+	"library-management/services/inventory"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-// AddBook adds a book or increments copies - Only Admin
+// AddBook adds a book or new copies of it - Only Admin. Each copy gets an item
+// with a barcode: pass "items" to label them, or "total_copies" to have
+// barcodes generated.
 func AddBook(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var input models.Book
+		var input struct {
+			models.Book
+			Items []inventory.NewItem `json:"items"`
+		}
 
 		// Extract user ID and role from JWT
-		userID, exists := c.Get("userID")
+		_, exists := c.Get("userID")
 		userRole, roleExists := c.Get("userRole")
 		if !exists || !roleExists || userRole != "admin" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
@@ -26,53 +36,78 @@ func AddBook(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		var ok bool
+		if input.ISBN, ok = normalizeISBN(c, input.ISBN); !ok {
+			return
+		}
 
-		// Ensure user is an admin of the library
-		var admin models.UserLibrary
-		if err := db.Where("user_id = ? AND library_id = ?", userID, input.LibraryID).First(&admin).Error; err != nil {
+		// Ensure user is an admin of the library (checked by RequireLibraryRole)
+		libraryID, scoped := middleware.ScopedLibrary(c)
+		if !scoped || libraryID != input.LibraryID {
 			c.JSON(http.StatusForbidden, gin.H{"error": "You can only add books to libraries you manage"})
 			return
 		}
 
-		// Ensure book has valid copies
-		if input.TotalCopies <= 0 {
+		// Ensure book has valid copies, and not more than one request may add
+		if maxCopies := config.AppConfig.MaxNewCopies; len(input.Items) > maxCopies || input.TotalCopies > maxCopies {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d copies can be added at once", maxCopies)})
+			return
+		}
+		items := input.Items
+		if len(items) == 0 && input.TotalCopies > 0 {
+			items = make([]inventory.NewItem, input.TotalCopies)
+		}
+		if len(items) == 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Number of copies must be greater than zero"})
 			return
 		}
 
-		// Check if book already exists in the library
-		var existingBook models.Book
-		if err := db.Where("isbn = ? AND library_id = ?", input.ISBN, input.LibraryID).First(&existingBook).Error; err == nil {
-			existingBook.TotalCopies += input.TotalCopies
-			existingBook.AvailableCopies += input.TotalCopies
-
-			if err := db.Save(&existingBook).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update book copies"})
-				return
+		// Add copies to an existing book or insert a new one; new copies go to
+		// readers waiting in the holds queue first
+		var book models.Book
+		var created bool
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if book, created, err = inventory.AddCopies(tx, input.Book, items); err != nil {
+				return err
 			}
-
-			c.JSON(http.StatusOK, gin.H{"message": "Book copies updated successfully", "book": existingBook})
+			_, err = holds.Allocate(tx, book.ISBN, book.LibraryID)
+			return err
+		})
+		if err != nil {
+			respondTxError(c, err, "Could not add book")
 			return
 		}
 
-		// New book → Insert into DB
-		input.AvailableCopies = input.TotalCopies
-		if err := db.Create(&input).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not add book"})
+		if !created {
+			c.JSON(http.StatusOK, gin.H{"message": "Book copies updated successfully", "book": book})
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{"message": "Book added successfully", "book": input})
+		c.JSON(http.StatusCreated, gin.H{"message": "Book added successfully", "book": book})
 	}
 }
 
-// UpdateBook updates book details - Only Admin
+// UpdateBook updates book details - Only Admin. Fields left out of the request
+// keep their value; setting the total copies to zero removes the book, as
+// withdrawing its last copy does.
 func UpdateBook(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		isbn := c.Param("isbn")
-		var input models.Book
+		isbn, ok := normalizeISBN(c, c.Param("isbn"))
+		if !ok {
+			return
+		}
+		var input struct {
+			Title       *string
+			Authors     *string
+			Publisher   *string
+			Version     *string
+			Category    *string
+			TotalCopies *int
+			LibraryID   uint
+		}
 
-		userID, exists := c.Get("userID")
+		_, exists := c.Get("userID")
 		userRole, roleExists := c.Get("userRole")
 		if !exists || !roleExists || userRole != "admin" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
@@ -84,38 +119,37 @@ func UpdateBook(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		if input.LibraryID == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Library ID is required"})
-			return
-		}
-
-		var admin models.UserLibrary
-		if err := db.Where("user_id = ? AND library_id = ?", userID, input.LibraryID).First(&admin).Error; err != nil {
+		libraryID, scoped := middleware.ScopedLibrary(c)
+		if !scoped || libraryID != input.LibraryID {
 			c.JSON(http.StatusForbidden, gin.H{"error": "You are not assigned as an admin for this library"})
 			return
 		}
 
-		var book models.Book
-		if err := db.Where("isbn = ? AND library_id = ?", isbn, input.LibraryID).First(&book).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found in the specified library"})
-			return
+		changes := inventory.BookChanges{
+			Title:       input.Title,
+			Authors:     input.Authors,
+			Publisher:   input.Publisher,
+			Version:     input.Version,
+			Category:    input.Category,
+			TotalCopies: input.TotalCopies,
 		}
-
-		issuedCopies := book.TotalCopies - book.AvailableCopies
-		if input.TotalCopies < issuedCopies {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Total copies cannot be less than issued copies"})
+		var book models.Book
+		var removed bool
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if book, removed, err = inventory.Update(tx, isbn, input.LibraryID, changes); err != nil || removed {
+				return err
+			}
+			_, err = holds.Allocate(tx, book.ISBN, book.LibraryID)
+			return err
+		})
+		if err != nil {
+			respondTxError(c, err, "Failed to update book")
 			return
 		}
 
-		book.Title = input.Title
-		book.Authors = input.Authors
-		book.Publisher = input.Publisher
-		book.Version = input.Version
-		book.TotalCopies = input.TotalCopies
-		book.AvailableCopies = input.TotalCopies - issuedCopies
-
-		if err := db.Save(&book).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update book"})
+		if removed {
+			c.JSON(http.StatusOK, gin.H{"message": "Book removed from inventory"})
 			return
 		}
 
@@ -123,15 +157,20 @@ func UpdateBook(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-// RemoveBook removes a book - Only Admin
+// RemoveBook withdraws one copy of a book that is on the shelf - Only Admin.
+// Pass "barcode" to choose the copy; otherwise the newest is withdrawn.
 func RemoveBook(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		isbn := c.Param("isbn")
+		isbn, ok := normalizeISBN(c, c.Param("isbn"))
+		if !ok {
+			return
+		}
 		var input struct {
-			LibraryID uint `json:"libraryid"`
+			LibraryID uint   `json:"libraryid"`
+			Barcode   string `json:"barcode"`
 		}
 
-		userID, exists := c.Get("userID")
+		_, exists := c.Get("userID")
 		userRole, roleExists := c.Get("userRole")
 		if !exists || !roleExists || userRole != "admin" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
@@ -143,32 +182,48 @@ func RemoveBook(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var admin models.UserLibrary
-		if err := db.Where("user_id = ? AND library_id = ?", userID, input.LibraryID).First(&admin).Error; err != nil {
+		libraryID, scoped := middleware.ScopedLibrary(c)
+		if !scoped || libraryID != input.LibraryID {
 			c.JSON(http.StatusForbidden, gin.H{"error": "You are not assigned as an admin for this library"})
 			return
 		}
 
-		var book models.Book
-		if err := db.Where("isbn = ? AND library_id = ?", isbn, input.LibraryID).First(&book).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found in the specified library"})
+		book, removed, err := inventory.Withdraw(db, isbn, input.LibraryID, input.Barcode)
+		if err != nil {
+			respondTxError(c, err, "Failed to remove book")
 			return
 		}
 
-		if book.TotalCopies > 1 {
-			book.TotalCopies--
-			book.AvailableCopies--
-			if err := db.Save(&book).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrement book copies"})
-				return
-			}
-			c.JSON(http.StatusOK, gin.H{"message": "Book copies decremented", "book": book})
-		} else {
-			if err := db.Delete(&book).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove book"})
-				return
-			}
+		if removed {
 			c.JSON(http.StatusOK, gin.H{"message": "Book removed from inventory"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Book copies decremented", "book": book})
+	}
+}
+
+// ListBookItems lists every copy of a book in a library with its barcode,
+// condition and status - Only Admin
+func ListBookItems(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryID, scoped := middleware.ScopedLibrary(c)
+		if !scoped {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not assigned as an admin for this library"})
+			return
 		}
+
+		isbn, ok := normalizeISBN(c, c.Param("isbn"))
+		if !ok {
+			return
+		}
+
+		items, err := inventory.Items(db, isbn, libraryID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch items"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"items": items})
 	}
 }
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"errors"
+	"library-management/isbn"
+	"library-management/services/eligibility"
+	"library-management/services/fines"
+	"library-management/services/holds"
+	"library-management/services/imports"
+	"library-management/services/inventory"
+	"library-management/services/loans"
+	"library-management/services/policies"
+	"library-management/services/requests"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestError carries an HTTP status out of a database transaction so the
+// handler can report it after the transaction has been rolled back
+type requestError struct {
+	status  int
+	message string
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+func newRequestError(status int, message string) error {
+	return &requestError{status: status, message: message}
+}
+
+// serviceErrors maps circulation service failures to client responses
+var serviceErrors = map[error]requestError{
+	isbn.ErrInvalid:                 {http.StatusBadRequest, "Invalid ISBN: use a 10 or 13 digit ISBN with a correct check digit"},
+	inventory.ErrBookNotFound:       {http.StatusNotFound, "Book not found in the specified library"},
+	inventory.ErrInvalidCopyCount:   {http.StatusBadRequest, "Number of copies must be greater than zero"},
+	inventory.ErrTooManyCopies:      {http.StatusBadRequest, "Too many copies requested at once"},
+	inventory.ErrNoCopiesAvailable:  {http.StatusBadRequest, "No available copies to issue"},
+	inventory.ErrCopiesOnLoan:       {http.StatusBadRequest, "Total copies cannot be less than issued copies"},
+	inventory.ErrAllCopiesAvailable: {http.StatusConflict, "All copies of this book are already available"},
+	inventory.ErrItemNotFound:       {http.StatusNotFound, "No copy with that barcode in this library"},
+	inventory.ErrItemNotAvailable:   {http.StatusConflict, "That copy is not on the shelf"},
+	inventory.ErrBarcodeTaken:       {http.StatusConflict, "Barcode is already in use"},
+	inventory.ErrInvalidCondition:   {http.StatusBadRequest, "Condition must be one of new, good, fair, poor, damaged"},
+	loans.ErrLoanNotFound:           {http.StatusNotFound, "Loan not found"},
+	loans.ErrLoanNotActive:          {http.StatusBadRequest, "Book has already been returned"},
+	loans.ErrRenewalLimit:           {http.StatusConflict, "This loan has already been renewed the maximum number of times"},
+	loans.ErrTitleRequested:         {http.StatusConflict, "This loan cannot be renewed because another reader is waiting for the book"},
+	loans.ErrLoanNotFlagged:         {http.StatusConflict, "This loan already has a library"},
+	loans.ErrItemNotOnLoan:          {http.StatusConflict, "That copy is not out on loan or is recorded on another loan"},
+	eligibility.ErrReaderNotFound:   {http.StatusNotFound, "Reader not found"},
+	policies.ErrPolicyNotFound:      {http.StatusNotFound, "Policy rule not found"},
+	holds.ErrCopiesAvailable:        {http.StatusConflict, "Copies of this book are available; request it instead"},
+	holds.ErrAlreadyQueued:          {http.StatusConflict, "You already have a hold on this book"},
+	holds.ErrHoldNotFound:           {http.StatusNotFound, "Hold not found"},
+	holds.ErrHoldClosed:             {http.StatusConflict, "Hold is no longer open"},
+	fines.ErrInvalidAmount:          {http.StatusBadRequest, "Amount must be greater than zero"},
+	fines.ErrExceedsBalance:         {http.StatusConflict, "Amount is more than the reader's outstanding balance"},
+	fines.ErrReasonRequired:         {http.StatusBadRequest, "A reason is required to waive a fine"},
+	fines.ErrReaderNotFound:         {http.StatusNotFound, "Reader not found"},
+	imports.ErrUnknownFormat:        {http.StatusBadRequest, "Format must be csv, marc or marcxml"},
+	imports.ErrEmptyFile:            {http.StatusBadRequest, "The uploaded file is empty"},
+	imports.ErrNotFound:             {http.StatusNotFound, "Import not found"},
+}
+
+// respondTxError writes the response for an error returned from db.Transaction,
+// a service operation or a request status change
+func respondTxError(c *gin.Context, err error, fallback string) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		c.JSON(reqErr.status, gin.H{"error": reqErr.message})
+		return
+	}
+	var ineligibleErr *eligibility.IneligibleError
+	if errors.As(err, &ineligibleErr) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Reader is not eligible to borrow", "reasons": ineligibleErr.Reasons})
+		return
+	}
+	var transitionErr *requests.TransitionError
+	if errors.As(err, &transitionErr) {
+		message := transitionErr.Error()
+		c.JSON(http.StatusConflict, gin.H{"error": strings.ToUpper(message[:1]) + message[1:], "status": transitionErr.From})
+		return
+	}
+	for target, mapped := range serviceErrors {
+		if errors.Is(err, target) {
+			c.JSON(mapped.status, gin.H{"error": mapped.message})
+			return
+		}
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
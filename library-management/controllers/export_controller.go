@@ -0,0 +1,60 @@
+// 📤 Catalog Export
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/catalog"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ExportCatalog streams every book a library holds, with its copy counts and
+// current loans, as CSV, JSON Lines or MARCXML. Pick one with ?format=
+// (default csv).
+func ExportCatalog(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryID, scoped := middleware.ScopedLibrary(c)
+		if !scoped {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only export the catalog of your assigned library"})
+			return
+		}
+		if err := db.First(&models.Library{}, libraryID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch library"})
+			return
+		}
+
+		format := c.DefaultQuery("format", catalog.CSV)
+		contentType, extension, err := catalog.ContentType(format)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export format", "allowed": catalog.Formats})
+			return
+		}
+
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="library-%d-catalog.%s"`, libraryID, extension))
+		c.Status(http.StatusOK)
+
+		err = catalog.Export(db, libraryID, format, c.Writer, c.Writer.Flush)
+		if err == nil {
+			return
+		}
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Disposition")
+			c.Writer.Header().Del("Content-Type")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not export catalog"})
+			return
+		}
+		// The status has already been sent, so the file just ends early
+		log.Printf("⚠️ Catalog export for library %d failed: %v", libraryID, err)
+	}
+}
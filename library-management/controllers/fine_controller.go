@@ -0,0 +1,109 @@
+// 💰 Fines
+package controllers
+
+import (
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/fines"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListFines shows the readers who owe a library money. Pass ?reader_id= to
+// see one reader's balance and ledger instead.
+func ListFines(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryID, scoped := middleware.ScopedLibrary(c)
+		if !scoped {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only view fines in your assigned library"})
+			return
+		}
+
+		if value := c.Query("reader_id"); value != "" {
+			readerID, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reader ID"})
+				return
+			}
+
+			balance, err := fines.ReaderBalance(db, uint(readerID), libraryID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch fines"})
+				return
+			}
+			entries, err := fines.Ledger(db, uint(readerID), libraryID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch fines"})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"reader_id": readerID, "balance_cents": balance, "entries": entries})
+			return
+		}
+
+		balances, err := fines.LibraryBalances(db, libraryID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch fines"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"balances": balances})
+	}
+}
+
+// fineCreditInput is the body of a payment or waiver
+type fineCreditInput struct {
+	LibraryID   uint   `json:"library_id" binding:"required"`
+	ReaderID    uint   `json:"reader_id" binding:"required"`
+	AmountCents int64  `json:"amount_cents" binding:"required"`
+	Reason      string `json:"reason"`
+}
+
+// RecordFinePayment records money an admin took from a reader
+func RecordFinePayment(db *gorm.DB) gin.HandlerFunc {
+	return creditFine(db, fines.Pay, "Payment recorded", "Could not record payment")
+}
+
+// WaiveFine writes off part or all of a reader's balance. A reason is required.
+func WaiveFine(db *gorm.DB) gin.HandlerFunc {
+	return creditFine(db, fines.Waive, "Fine waived", "Could not waive fine")
+}
+
+func creditFine(db *gorm.DB, credit func(*gorm.DB, uint, uint, int64, uint, string) (models.FineEntry, error), message, fallback string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input fineCreditInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		adminID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		// Library access was checked against the body by RequireLibraryRole
+		if libraryID, scoped := middleware.ScopedLibrary(c); !scoped || libraryID != input.LibraryID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only manage fines in your assigned library"})
+			return
+		}
+
+		entry, err := credit(db, input.ReaderID, input.LibraryID, input.AmountCents, adminID.(uint), input.Reason)
+		if err != nil {
+			respondTxError(c, err, fallback)
+			return
+		}
+
+		balance, err := fines.ReaderBalance(db, input.ReaderID, input.LibraryID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": message, "entry": entry, "balance_cents": balance})
+	}
+}
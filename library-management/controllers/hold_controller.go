@@ -0,0 +1,40 @@
+// 📋 Holds Queue
+package controllers
+
+import (
+	"library-management/middleware"
+	"library-management/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListHolds shows the open holds queue of a library, oldest first. Pass
+// ?isbn= to see the queue for a single title.
+func ListHolds(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryID, scoped := middleware.ScopedLibrary(c)
+		if !scoped {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only view holds in your assigned library"})
+			return
+		}
+
+		query := db.Where("library_id = ? AND status IN ?", libraryID, models.OpenHoldStatuses)
+		if raw := c.Query("isbn"); raw != "" {
+			isbn, ok := normalizeISBN(c, raw)
+			if !ok {
+				return
+			}
+			query = query.Where("isbn = ?", isbn)
+		}
+
+		var queue []models.Hold
+		if err := query.Order("id").Find(&queue).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch holds"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"holds": queue})
+	}
+}
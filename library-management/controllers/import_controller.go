@@ -0,0 +1,120 @@
+// 📥 Catalog Import
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"library-management/config"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/imports"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ImportCatalog queues a CSV, MARC21 or MARCXML file of books for import into
+// a library - Only Admin. The file goes in the "file" form field; its format
+// is taken from the "format" field or else the file extension. The import
+// runs in the background; poll GET /imports/:id for progress and row errors.
+func ImportCatalog(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryID, scoped := middleware.ScopedLibrary(c)
+		if !scoped {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only import books into libraries you manage"})
+			return
+		}
+		uploaderID := c.GetUint("userID")
+
+		limit := config.AppConfig.ImportMaxBytes
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		header, err := c.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Import files can be at most %d bytes", limit)})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Upload the catalog in the \"file\" form field"})
+			return
+		}
+
+		format := c.PostForm("format")
+		if format == "" {
+			format = imports.DetectFormat(header.Filename)
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the uploaded file"})
+			return
+		}
+		defer file.Close()
+		payload, err := io.ReadAll(file)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the uploaded file"})
+			return
+		}
+
+		job, err := imports.Create(db, libraryID, &uploaderID, format, header.Filename, payload)
+		if errors.Is(err, imports.ErrUnreadable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The file could not be read", "detail": err.Error()})
+			return
+		}
+		if err != nil {
+			respondTxError(c, err, "Could not queue import")
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":    "Import queued",
+			"import":     job,
+			"status_url": fmt.Sprintf("/api/imports/%d", job.ID),
+		})
+	}
+}
+
+// GetImport reports an import's progress and every row it skipped, with why
+func GetImport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		importID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import ID"})
+			return
+		}
+
+		job, rowErrors, err := imports.Get(db, uint(importID))
+		if err != nil {
+			respondTxError(c, err, "Could not fetch import")
+			return
+		}
+
+		progress := 100
+		if job.TotalRows > 0 {
+			progress = job.ProcessedRows * 100 / job.TotalRows
+		}
+		c.JSON(http.StatusOK, gin.H{"import": job, "progress": progress, "errors": rowErrors})
+	}
+}
+
+// ListImports lists a library's imports, newest first
+func ListImports(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryID, scoped := middleware.ScopedLibrary(c)
+		if !scoped {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only view imports of libraries you manage"})
+			return
+		}
+
+		var jobs []models.CatalogImport
+		if err := db.Omit("payload").Where("library_id = ?", libraryID).Order("id DESC").Find(&jobs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch imports"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"imports": jobs})
+	}
+}
@@ -2,69 +2,96 @@
 package controllers
 
 import (
+	"errors"
+	"library-management/config"
+	"library-management/middleware"
 	"library-management/models"
+	"library-management/services/eligibility"
+	"library-management/services/holds"
+	"library-management/services/inventory"
+	"library-management/services/policies"
+	"library-management/services/requests"
 	"net/http"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/gin-gonic/gin"
 )
 
-// ListIssueRequests retrieves all issue requests for admin's libraries
+// ListIssueRequests retrieves the requests made in the admin's libraries,
+// optionally filtered with ?status=
 func ListIssueRequests(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		adminID, exists := c.Get("userID")
-		if !exists {
+		if _, exists := c.Get("userID"); !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
 			return
 		}
 
-		var adminLibraryIDs []uint
-		if err := db.Table("user_libraries").Where("user_id = ?", adminID).Pluck("library_id", &adminLibraryIDs).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch admin libraries"})
+		adminLibraryIDs := middleware.AuthorizedLibraries(c)
+		if len(adminLibraryIDs) == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin is not associated with any library"})
 			return
 		}
 
-		if len(adminLibraryIDs) == 0 {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin is not associated with any library"})
+		// Optional filter by lifecycle status
+		status := c.Query("status")
+		if status != "" && !requests.IsStatus(status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status filter", "allowed": models.RequestStatuses})
 			return
 		}
 
-		var requests []models.RequestEvent
-		if err := db.Joins("JOIN books ON request_events.book_id = books.isbn").
-			Where("books.library_id IN (?)", adminLibraryIDs).
-			Find(&requests).Error; err != nil {
+		query := db.Where("library_id IN ?", adminLibraryIDs)
+		if status != "" {
+			query = query.Where("status = ?", status)
+		}
+
+		var issueRequests []models.RequestEvent
+		if err := query.Order("id").Find(&issueRequests).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch issue requests"})
 			return
 		}
 
-		formattedRequests := make([]gin.H, len(requests))
-		for i, request := range requests {
+		formattedRequests := make([]gin.H, len(issueRequests))
+		for i, request := range issueRequests {
 			formattedRequests[i] = gin.H{
 				"id":            request.ID,
 				"book_id":       request.BookID,
 				"user_id":       request.ReaderID,
+				"library_id":    request.LibraryID,
 				"request_type":  request.RequestType,
+				"status":        request.Status,
 				"request_date":  formatUnixTime(&request.RequestDate),
 				"approval_date": formatUnixTime(request.ApprovalDate),
 				"approver_id":   request.ApproverID,
 			}
+			if request.RejectedAt != nil {
+				formattedRequests[i]["rejected_at"] = formatUnixTime(request.RejectedAt)
+				formattedRequests[i]["rejected_by_id"] = request.RejectedByID
+				formattedRequests[i]["rejection_reason"] = request.RejectionReason
+			}
+			if request.Status == models.RequestReadyForPickup {
+				if deadline := requests.Deadline(request, config.AppConfig.RequestExpiry, config.AppConfig.PickupExpiry); deadline != nil {
+					formattedRequests[i]["pickup_deadline"] = formatUnixTime(deadline)
+				}
+			}
+			if request.ExpiredAt != nil {
+				formattedRequests[i]["expired_at"] = formatUnixTime(request.ExpiredAt)
+				formattedRequests[i]["expiry_reason"] = request.ExpiryReason
+			}
 		}
 		c.JSON(http.StatusOK, gin.H{"requests": formattedRequests})
 	}
 }
 
-// ApproveIssue allows an admin to approve a book issue request
+// ApproveIssue allows an admin to approve a book issue request. Approval sets
+// a copy aside for the reader and leaves the request ready for pickup; the loan
+// starts when the reader collects the book at the desk (CheckoutIssue).
 func ApproveIssue(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		requestID := c.Param("id")
-		var request models.RequestEvent
-
-		if err := db.First(&request, requestID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Issue request not found"})
-			return
-		}
 
 		adminID, exists := c.Get("userID")
 		if !exists {
@@ -72,65 +99,199 @@ func ApproveIssue(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var book models.Book
-		if err := db.Where("isbn = ?", request.BookID).First(&book).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
-			return
-		}
+		var request models.RequestEvent
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, requestID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return newRequestError(http.StatusNotFound, "Issue request not found")
+				}
+				return err
+			}
 
-		var count int64
-		if err := db.Table("user_libraries").Where("user_id = ? AND library_id = ?", adminID, book.LibraryID).Count(&count).Error; err != nil || count == 0 {
-			c.JSON(http.StatusForbidden, gin.H{"error": "You can only approve requests for books in your assigned library"})
+			if request.RequestType != "issue" {
+				return newRequestError(http.StatusBadRequest, "Request is not an issue request")
+			}
+
+			if !middleware.CanAccessLibrary(c, request.LibraryID) {
+				return newRequestError(http.StatusForbidden, "You can only approve requests for books in your assigned library")
+			}
+
+			approverID := adminID.(uint)
+			now := time.Now().Unix()
+			request.ApprovalDate = &now
+			request.ApproverID = &approverID
+			if err := requests.Transition(tx, &request, models.RequestApproved, &approverID, ""); err != nil {
+				return err
+			}
+
+			_, item, _, err := reserveCopy(tx, request.BookID, request.LibraryID, request.ReaderID)
+			if err != nil {
+				return err
+			}
+			request.ItemID = &item.ID
+			return requests.Transition(tx, &request, models.RequestReadyForPickup, &approverID, "")
+		})
+		if err != nil {
+			respondTxError(c, err, "Could not approve request")
 			return
 		}
 
-		if request.ApprovalDate != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Request is already approved"})
-			return
+		response := gin.H{"message": "Issue request approved, the book is ready for pickup", "request": request}
+		if deadline := requests.Deadline(request, config.AppConfig.RequestExpiry, config.AppConfig.PickupExpiry); deadline != nil {
+			response["pickup_deadline"] = formatUnixTime(deadline)
 		}
+		c.JSON(http.StatusOK, response)
+	}
+}
 
-		if book.AvailableCopies == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No available copies to issue"})
+// CheckoutIssue hands a book that is ready for pickup to the reader at the desk.
+// The reader must still be eligible to borrow; the loan period starts now. A
+// request set aside for a hold fulfils the hold too.
+func CheckoutIssue(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.Param("id")
+
+		adminID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
 			return
 		}
 
-		now := time.Now().Unix()
-		request.ApprovalDate = &now
-		request.ApproverID = new(uint)
-		*request.ApproverID = adminID.(uint)
+		var issueRecord models.IssueRegistry
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var request models.RequestEvent
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, requestID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return newRequestError(http.StatusNotFound, "Issue request not found")
+				}
+				return err
+			}
+
+			if request.RequestType != "issue" {
+				return newRequestError(http.StatusBadRequest, "Request is not an issue request")
+			}
+
+			if !middleware.CanAccessLibrary(c, request.LibraryID) {
+				return newRequestError(http.StatusForbidden, "You can only check out books in your assigned library")
+			}
+
+			// Only a copy set aside on approval can be handed over
+			if request.Status != models.RequestReadyForPickup {
+				return &requests.TransitionError{From: request.Status, To: "checked out"}
+			}
+
+			book, err := inventory.LockBook(tx, request.BookID, request.LibraryID)
+			if err != nil {
+				return err
+			}
+			policy, err := policies.ForReader(tx, request.ReaderID, book)
+			if err != nil {
+				return err
+			}
+			// The copy set aside on approval already counts towards the loan
+			// limit; requests approved before copies were tracked have none
+			check := eligibility.Check
+			if request.ItemID != nil {
+				check = eligibility.CheckHandover
+			}
+			if err := check(tx, request.ReaderID, request.LibraryID, policy, time.Now()); err != nil {
+				return err
+			}
+
+			// Requests approved before copies were tracked have none set aside
+			var item models.Item
+			if request.ItemID != nil {
+				err = tx.First(&item, *request.ItemID).Error
+			} else {
+				book, item, err = inventory.Reserve(tx, request.BookID, request.LibraryID)
+			}
+			if err != nil {
+				return err
+			}
+
+			deskID := adminID.(uint)
+			if issueRecord, err = recordLoan(tx, book, item, request.ReaderID, deskID, policy); err != nil {
+				return err
+			}
 
-		if err := db.Save(&request).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not approve request"})
+			if err := holds.Collect(tx, request.ID); err != nil {
+				return err
+			}
+			request.IssueID = &issueRecord.ID
+			return requests.Transition(tx, &request, models.RequestFulfilled, &deskID, "")
+		})
+		if err != nil {
+			respondTxError(c, err, "Could not check out book")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Issue request approved"})
+		c.JSON(http.StatusOK, gin.H{"message": "Book checked out", "issue": issueRecord})
 	}
 }
 
-// DisapproveIssue allows an admin to reject an issue request
+// DisapproveIssue allows an admin to reject an issue request. The request is
+// kept with the rejection reason so the reader can see why it was refused.
 func DisapproveIssue(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		requestID := c.Param("id")
-		var request models.RequestEvent
 
-		if err := db.First(&request, requestID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Issue request not found"})
+		adminID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
 			return
 		}
 
-		if err := db.Delete(&request).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not disapprove request"})
+		// The reason is optional; an empty body rejects without one
+		var input struct {
+			Reason string `json:"reason"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+				return
+			}
+		}
+
+		var request models.RequestEvent
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, requestID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return newRequestError(http.StatusNotFound, "Issue request not found")
+				}
+				return err
+			}
+
+			if request.RequestType != "issue" {
+				return newRequestError(http.StatusBadRequest, "Request is not an issue request")
+			}
+
+			if !middleware.CanAccessLibrary(c, request.LibraryID) {
+				return newRequestError(http.StatusForbidden, "You can only disapprove requests for books in your assigned library")
+			}
+
+			now := time.Now().Unix()
+			rejectedBy := adminID.(uint)
+			reason := strings.TrimSpace(input.Reason)
+			request.RejectionReason = reason
+			request.RejectedByID = &rejectedBy
+			request.RejectedAt = &now
+			return requests.Transition(tx, &request, models.RequestRejected, &rejectedBy, reason)
+		})
+		if err != nil {
+			respondTxError(c, err, "Could not disapprove request")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Issue request disapproved successfully"})
+		c.JSON(http.StatusOK, gin.H{"message": "Issue request disapproved successfully", "request": request})
 	}
 }
 
 func IssueBookToUser(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		isbn := c.Param("isbn")
+		isbn, ok := normalizeISBN(c, c.Param("isbn"))
+		if !ok {
+			return
+		}
 
 		adminID, exists := c.Get("userID")
 		if !exists {
@@ -139,49 +300,130 @@ func IssueBookToUser(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		var input struct {
-			UserID    uint `json:"user_id"`
-			LibraryID uint `json:"library_id"`
+			UserID    uint `json:"user_id" binding:"required"`
+			LibraryID uint `json:"library_id" binding:"required"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
 			return
 		}
 
-		var book models.Book
-		if err := db.Where("isbn = ? AND library_id = ?", isbn, input.LibraryID).First(&book).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found in this library"})
+		if libraryID, scoped := middleware.ScopedLibrary(c); !scoped || libraryID != input.LibraryID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only issue books from libraries you manage"})
 			return
 		}
 
-		if book.AvailableCopies == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No available copies to issue"})
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := validateReader(tx, input.UserID, input.LibraryID); err != nil {
+				return err
+			}
+			_, err := issueBook(tx, isbn, input.LibraryID, input.UserID, adminID.(uint))
+			return err
+		})
+		if err != nil {
+			respondTxError(c, err, "Could not issue book")
 			return
 		}
 
-		book.AvailableCopies--
-		db.Save(&book)
-
-		issueDate := time.Now()
-		expectedReturnDate := issueDate.AddDate(0, 0, 14)
+		c.JSON(http.StatusOK, gin.H{"message": "Book issued successfully"})
+	}
+}
 
-		issueRecord := models.IssueRegistry{
-			ISBN:               isbn,
-			ReaderID:           input.UserID,
-			IssueApproverID:    adminID.(uint),
-			IssueStatus:        "issued",
-			IssueDate:          issueDate.Unix(),
-			ExpectedReturnDate: expectedReturnDate.Unix(),
-			ReturnDate:         0,
-			ReturnApproverID:   0,
+// validateReader checks that a user exists, is a reader and is registered at the library
+func validateReader(tx *gorm.DB, readerID, libraryID uint) error {
+	var reader models.User
+	if err := tx.First(&reader, readerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return newRequestError(http.StatusNotFound, "Reader not found")
 		}
+		return err
+	}
 
-		if err := db.Create(&issueRecord).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not issue book"})
-			return
-		}
+	if reader.Role != "user" {
+		return newRequestError(http.StatusBadRequest, "Books can only be issued to readers")
+	}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Book issued successfully"})
+	var count int64
+	if err := tx.Model(&models.UserLibrary{}).Where("user_id = ? AND library_id = ?", readerID, libraryID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return newRequestError(http.StatusBadRequest, "Reader is not registered in this library")
+	}
+	return nil
+}
+
+// issueBook takes one copy of a book out of stock and records the loan. Both
+// steps share the caller's transaction so a failed insert gives the copy back.
+func issueBook(tx *gorm.DB, isbn string, libraryID, readerID, approverID uint) (models.IssueRegistry, error) {
+	book, item, policy, err := reserveCopy(tx, isbn, libraryID, readerID)
+	if err != nil {
+		return models.IssueRegistry{}, err
+	}
+	return recordLoan(tx, book, item, readerID, approverID, policy)
+}
+
+// reserveCopy sets a copy of a book aside for a reader, using the one already
+// set aside by the reader's hold if there is one. The reader must be eligible
+// under the library's policy for the book, which is returned for the loan. The
+// hold is claimed first so its copy is not counted twice against the loan
+// limit; an ineligible reader rolls the claim back with the transaction.
+func reserveCopy(tx *gorm.DB, isbn string, libraryID, readerID uint) (models.Book, models.Item, policies.Policy, error) {
+	book, err := inventory.LockBook(tx, isbn, libraryID)
+	if err != nil {
+		return models.Book{}, models.Item{}, policies.Policy{}, err
+	}
+
+	policy, err := policies.ForReader(tx, readerID, book)
+	if err != nil {
+		return models.Book{}, models.Item{}, policies.Policy{}, err
+	}
+	setAside, err := holds.Claim(tx, readerID, isbn, libraryID)
+	if err != nil {
+		return models.Book{}, models.Item{}, policies.Policy{}, err
 	}
+	if err := eligibility.Check(tx, readerID, libraryID, policy, time.Now()); err != nil {
+		return models.Book{}, models.Item{}, policies.Policy{}, err
+	}
+	if setAside != nil {
+		return book, *setAside, policy, nil
+	}
+
+	book, item, err := inventory.Reserve(tx, isbn, libraryID)
+	if err != nil {
+		return models.Book{}, models.Item{}, policies.Policy{}, err
+	}
+	return book, item, policy, nil
+}
+
+// recordLoan lends a copy that was set aside and records the loan. The loan
+// period comes from the reader's policy and starts now.
+func recordLoan(tx *gorm.DB, book models.Book, item models.Item, readerID, approverID uint, policy policies.Policy) (models.IssueRegistry, error) {
+	if _, err := inventory.Lend(tx, item.ID); err != nil {
+		return models.IssueRegistry{}, err
+	}
+
+	issueDate := time.Now()
+	expectedReturnDate := issueDate.AddDate(0, 0, policy.LoanPeriodDays)
+
+	issueRecord := models.IssueRegistry{
+		ISBN:               book.ISBN,
+		ItemID:             &item.ID,
+		LibraryID:          book.LibraryID,
+		ReaderID:           readerID,
+		IssueApproverID:    approverID,
+		IssueStatus:        models.LoanIssued,
+		IssueDate:          issueDate.Unix(),
+		ExpectedReturnDate: expectedReturnDate.Unix(),
+		ReturnDate:         0,
+		ReturnApproverID:   0,
+	}
+
+	if err := tx.Create(&issueRecord).Error; err != nil {
+		return models.IssueRegistry{}, err
+	}
+
+	return issueRecord, nil
 }
 
 func formatUnixTime(timestamp *int64) string {
@@ -0,0 +1,137 @@
+// 🔁 Loan Renewals
+package controllers
+
+import (
+	"library-management/middleware"
+	"library-management/services/loans"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RenewLoan lets an admin extend a loan in one of their libraries
+func RenewLoan(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		loanID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+			return
+		}
+
+		adminID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		// Library access was checked against the loan by RequireLibraryRole
+		if _, scoped := middleware.ScopedLibrary(c); !scoped {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only renew loans in your assigned library"})
+			return
+		}
+
+		loan, remaining, err := loans.Renew(db, uint(loanID), nil, adminID.(uint))
+		if err != nil {
+			respondTxError(c, err, "Could not renew loan")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":            "Loan renewed",
+			"loan":               loan,
+			"renewals_remaining": remaining,
+		})
+	}
+}
+
+// ListLoanRenewals shows every extension of a loan
+func ListLoanRenewals(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		loanID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+			return
+		}
+
+		if _, scoped := middleware.ScopedLibrary(c); !scoped {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only view loans in your assigned library"})
+			return
+		}
+
+		renewals, err := loans.Renewals(db, uint(loanID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch renewals"})
+			return
+		}
+
+		response := make([]gin.H, len(renewals))
+		for i, renewal := range renewals {
+			response[i] = gin.H{
+				"id":                renewal.ID,
+				"renewed_by_id":     renewal.RenewedByID,
+				"previous_due_date": formatUnixTime(&renewal.PreviousDueDate),
+				"new_due_date":      formatUnixTime(&renewal.NewDueDate),
+				"renewed_at":        renewal.CreatedAt.Format("2006-01-02 15:04:05"),
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"renewal_count": len(renewals), "renewals": response})
+	}
+}
+
+// ListLoansNeedingRepair shows loans from before per-library loans whose library
+// is unknown, so their readers cannot return them. Admins see the loans of
+// books their libraries hold; owners see them all.
+func ListLoansNeedingRepair(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var libraryIDs []uint
+		if c.GetString("userRole") != "owner" {
+			libraryIDs = append([]uint{}, middleware.AuthorizedLibraries(c)...)
+		}
+
+		flagged, err := loans.NeedingRepair(db, libraryIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch loans"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"loans": flagged})
+	}
+}
+
+// RepairLoan assigns a flagged loan to a library and optionally the copy the
+// reader has, so the book can be returned there
+func RepairLoan(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		loanID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+			return
+		}
+
+		var input struct {
+			LibraryID uint   `json:"library_id" binding:"required"`
+			Barcode   string `json:"barcode"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Library access was checked against the body by RequireLibraryRole
+		if libraryID, scoped := middleware.ScopedLibrary(c); !scoped || libraryID != input.LibraryID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only assign loans to libraries you manage"})
+			return
+		}
+
+		loan, err := loans.Repair(db, uint(loanID), input.LibraryID, input.Barcode)
+		if err != nil {
+			respondTxError(c, err, "Could not repair loan")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Loan assigned to library", "loan": loan})
+	}
+}
@@ -0,0 +1,321 @@
+// 🙋 Reader Self-Service
+package controllers
+
+import (
+	"library-management/config"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/eligibility"
+	"library-management/services/fines"
+	"library-management/services/holds"
+	"library-management/services/loans"
+	"library-management/services/policies"
+	"library-management/services/requests"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ListMyRequests lists the caller's own issue and return requests, newest
+// first, optionally filtered with ?status=
+func ListMyRequests(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		status := c.Query("status")
+		if status != "" && !requests.IsStatus(status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status filter", "allowed": models.RequestStatuses})
+			return
+		}
+
+		query := db.Where("reader_id = ?", userID)
+		if status != "" {
+			query = query.Where("status = ?", status)
+		}
+
+		var myRequests []models.RequestEvent
+		if err := query.Order("id DESC").Find(&myRequests).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch your requests"})
+			return
+		}
+
+		response := make([]gin.H, len(myRequests))
+		for i, request := range myRequests {
+			response[i] = gin.H{
+				"id":            request.ID,
+				"isbn":          request.BookID,
+				"library_id":    request.LibraryID,
+				"request_type":  request.RequestType,
+				"status":        request.Status,
+				"request_date":  formatUnixTime(&request.RequestDate),
+				"approval_date": formatUnixTime(request.ApprovalDate),
+			}
+			switch request.Status {
+			case models.RequestRejected:
+				response[i]["rejection_reason"] = request.RejectionReason
+			case models.RequestExpired:
+				response[i]["expiry_reason"] = request.ExpiryReason
+				response[i]["expired_at"] = formatUnixTime(request.ExpiredAt)
+			default:
+				if deadline := requests.Deadline(request, config.AppConfig.RequestExpiry, config.AppConfig.PickupExpiry); deadline != nil {
+					response[i]["expires_at"] = formatUnixTime(deadline)
+				}
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"requests": response})
+	}
+}
+
+// CancelMyRequest lets a reader withdraw one of their own pending requests
+func CancelMyRequest(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.Param("id")
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+		readerID := userID.(uint)
+
+		var request models.RequestEvent
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Other readers' requests are reported as missing rather than forbidden
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("reader_id = ?", readerID).
+				First(&request, requestID).Error; err != nil {
+				return newRequestError(http.StatusNotFound, "Request not found")
+			}
+
+			return requests.Transition(tx, &request, models.RequestCancelled, &readerID, "Cancelled by reader")
+		})
+		if err != nil {
+			respondTxError(c, err, "Could not cancel request")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Request cancelled", "request": request})
+	}
+}
+
+// ListMyLoans lists the books the caller currently has on loan
+func ListMyLoans(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		var myLoans []struct {
+			ID                 uint
+			ISBN               string
+			Title              string
+			LibraryID          uint
+			LibraryName        string
+			IssueDate          int64
+			ExpectedReturnDate int64
+			RenewalCount       int
+		}
+		if err := db.Table("issue_registries").
+			Select("issue_registries.id, issue_registries.isbn, books.title, issue_registries.library_id, libraries.name AS library_name, issue_registries.issue_date, issue_registries.expected_return_date, issue_registries.renewal_count").
+			Joins("LEFT JOIN books ON books.isbn = issue_registries.isbn AND books.library_id = issue_registries.library_id AND books.deleted_at IS NULL").
+			Joins("LEFT JOIN libraries ON libraries.id = issue_registries.library_id").
+			Where("issue_registries.reader_id = ? AND issue_registries.issue_status IN ? AND issue_registries.deleted_at IS NULL", userID, models.ActiveLoanStatuses).
+			Order("issue_registries.expected_return_date ASC").
+			Scan(&myLoans).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch your loans"})
+			return
+		}
+
+		now := time.Now().Unix()
+		response := make([]gin.H, len(myLoans))
+		for i, loan := range myLoans {
+			policy, err := policies.ForLoan(db, models.IssueRegistry{ISBN: loan.ISBN, LibraryID: loan.LibraryID, ReaderID: userID.(uint)})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch your loans"})
+				return
+			}
+			response[i] = gin.H{
+				"id":                   loan.ID,
+				"isbn":                 loan.ISBN,
+				"title":                loan.Title,
+				"library_id":           loan.LibraryID,
+				"library":              loan.LibraryName,
+				"issue_date":           formatUnixTime(&loan.IssueDate),
+				"expected_return_date": formatUnixTime(&loan.ExpectedReturnDate),
+				"overdue":              loan.ExpectedReturnDate < now,
+				"renewal_count":        loan.RenewalCount,
+				"renewals_remaining":   loans.RenewalsRemaining(models.IssueRegistry{RenewalCount: loan.RenewalCount}, policy),
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"loans": response})
+	}
+}
+
+// RenewMyLoan lets a reader extend one of their own loans
+func RenewMyLoan(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		loanID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+			return
+		}
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+		readerID := userID.(uint)
+
+		loan, remaining, err := loans.Renew(db, uint(loanID), &readerID, readerID)
+		if err != nil {
+			respondTxError(c, err, "Could not renew loan")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":              "Loan renewed",
+			"expected_return_date": formatUnixTime(&loan.ExpectedReturnDate),
+			"renewal_count":        loan.RenewalCount,
+			"renewals_remaining":   remaining,
+		})
+	}
+}
+
+// ListMyHolds lists the caller's open holds with their queue positions
+func ListMyHolds(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		var myHolds []models.Hold
+		if err := db.Where("reader_id = ? AND status IN ?", userID, models.OpenHoldStatuses).Order("id").Find(&myHolds).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch your holds"})
+			return
+		}
+
+		response := make([]gin.H, len(myHolds))
+		for i, hold := range myHolds {
+			position, err := holds.Position(db, hold)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch your holds"})
+				return
+			}
+			response[i] = gin.H{
+				"id":         hold.ID,
+				"isbn":       hold.ISBN,
+				"library_id": hold.LibraryID,
+				"status":     hold.Status,
+				"position":   position,
+			}
+			if hold.Status == models.HoldReady {
+				response[i]["pickup_deadline"] = formatUnixTime(hold.PickupDeadline)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"holds": response})
+	}
+}
+
+// CancelMyHold lets a reader leave a holds queue
+func CancelMyHold(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		holdID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hold ID"})
+			return
+		}
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		hold, err := holds.Cancel(db, uint(holdID), userID.(uint))
+		if err != nil {
+			respondTxError(c, err, "Could not cancel hold")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Hold cancelled", "hold": hold})
+	}
+}
+
+// ListMyFines shows what the caller owes each library and every charge,
+// payment and waiver behind it
+func ListMyFines(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+		readerID := userID.(uint)
+
+		balances, err := fines.ReaderBalances(db, readerID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch your fines"})
+			return
+		}
+		entries, err := fines.Ledger(db, readerID, 0)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch your fines"})
+			return
+		}
+
+		var total int64
+		for _, balance := range balances {
+			total += balance.BalanceCents
+		}
+
+		c.JSON(http.StatusOK, gin.H{"balance_cents": total, "balances": balances, "entries": entries})
+	}
+}
+
+// MyEligibility tells the caller whether they may borrow from a library and,
+// if not, every reason why
+func MyEligibility(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryID, scoped := middleware.ScopedLibrary(c)
+		if !scoped {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only check libraries you are registered in"})
+			return
+		}
+		readerID := c.GetUint("userID")
+
+		tier, err := policies.Tier(db, readerID, libraryID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check your eligibility"})
+			return
+		}
+		policy, err := policies.Resolve(db, libraryID, "", tier)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check your eligibility"})
+			return
+		}
+
+		reasons, err := eligibility.Reasons(db, readerID, libraryID, policy, time.Now())
+		if err != nil {
+			respondTxError(c, err, "Could not check your eligibility")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"eligible": len(reasons) == 0, "reasons": reasons, "policy": policy})
+	}
+}
@@ -2,7 +2,9 @@ package controllers
 
 import (
 	"fmt"
+	"library-management/middleware"
 	"library-management/models"
+	"library-management/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -12,7 +14,13 @@ import (
 // RegisterOwnerNew allows an existing owner to create a new owner
 func RegisterOwnerNew(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var input models.User
+		var input struct {
+			Name     string `json:"name" binding:"required"`
+			Email    string `json:"email" binding:"required,email"`
+			Password string `json:"password" binding:"required"`
+			Contact  string `json:"contact"`
+			Role     string `json:"role"` // Must be "owner"; any other role is refused, not ignored
+		}
 
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -24,12 +32,26 @@ func RegisterOwnerNew(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		if err := db.Create(&input).Error; err != nil {
+		hashedPassword, err := utils.HashPassword(input.Password)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Password could not be hashed (maximum length is 72 bytes)"})
+			return
+		}
+
+		owner := models.User{
+			Name:     input.Name,
+			Email:    input.Email,
+			Password: hashedPassword,
+			Contact:  input.Contact,
+			Role:     "owner",
+		}
+
+		if err := db.Create(&owner).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create owner"})
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{"message": "New owner registered successfully", "owner": input})
+		c.JSON(http.StatusCreated, gin.H{"message": "New owner registered successfully", "owner": owner})
 	}
 }
 
@@ -56,10 +78,16 @@ func RegisterAdmin(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		hashedPassword, err := utils.HashPassword(input.Password)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Password could not be hashed (maximum length is 72 bytes)"})
+			return
+		}
+
 		admin := models.User{
 			Name:     input.Name,
 			Email:    input.Email,
-			Password: input.Password,
+			Password: hashedPassword,
 			Contact:  input.Contact,
 			Role:     "admin",
 		}
@@ -109,30 +137,23 @@ func RegisterUser(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var adminLibraries []uint
-		if err := db.Table("user_libraries").Where("user_id = ?", adminID).Pluck("library_id", &adminLibraries).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify admin libraries"})
-			return
-		}
-
 		for _, libID := range input.LibraryIDs {
-			found := false
-			for _, adminLibID := range adminLibraries {
-				if libID == adminLibID {
-					found = true
-					break
-				}
-			}
-			if !found {
+			if !middleware.CanAccessLibrary(c, libID) {
 				c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("You can only add users to libraries you manage (Library ID: %d)", libID)})
 				return
 			}
 		}
 
+		hashedPassword, err := utils.HashPassword(input.Password)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Password could not be hashed (maximum length is 72 bytes)"})
+			return
+		}
+
 		user := models.User{
 			Name:     input.Name,
 			Email:    input.Email,
-			Password: input.Password,
+			Password: hashedPassword,
 			Contact:  input.Contact,
 			Role:     "user",
 		}
@@ -153,3 +174,42 @@ func RegisterUser(db *gorm.DB) gin.HandlerFunc {
 		c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully", "user": user})
 	}
 }
+
+// SetAccountStatus allows an admin to suspend or reactivate a reader in one of
+// their libraries. Suspended readers cannot request or be issued books.
+func SetAccountStatus(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Status string `json:"status" binding:"required,oneof=active suspended"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be 'active' or 'suspended'"})
+			return
+		}
+
+		var reader models.User
+		if err := db.First(&reader, c.Param("id")).Error; err != nil || reader.Role != "user" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reader not found"})
+			return
+		}
+
+		var shared int64
+		if err := db.Model(&models.UserLibrary{}).
+			Where("user_id = ? AND library_id IN ?", reader.ID, middleware.AuthorizedLibraries(c)).
+			Count(&shared).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update account"})
+			return
+		}
+		if shared == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only manage readers registered in your libraries"})
+			return
+		}
+
+		if err := db.Model(&reader).Update("status", input.Status).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update account"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Account status updated", "status": input.Status})
+	}
+}
@@ -0,0 +1,170 @@
+// ⚖️ Circulation Policy
+package controllers
+
+import (
+	"errors"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/policies"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// policyLibrary returns the library checked by RequireLibraryRole after making
+// sure it exists, writing the error response when it does not
+func policyLibrary(c *gin.Context, db *gorm.DB) (uint, bool) {
+	libraryID, scoped := middleware.ScopedLibrary(c)
+	if !scoped {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only manage the policy of your assigned library"})
+		return 0, false
+	}
+
+	var library models.Library
+	if err := db.First(&library, libraryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+			return 0, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch library"})
+		return 0, false
+	}
+	return libraryID, true
+}
+
+// GetLibraryPolicy shows a library's policy rules and the policy they produce.
+// Pass ?category= and ?tier= to see the policy for a book category and
+// membership tier.
+func GetLibraryPolicy(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryID, ok := policyLibrary(c, db)
+		if !ok {
+			return
+		}
+
+		rules, err := policies.Rules(db, libraryID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch policy"})
+			return
+		}
+		effective, err := policies.Resolve(db, libraryID, c.Query("category"), c.Query("tier"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"effective": effective, "defaults": policies.Defaults(), "rules": rules})
+	}
+}
+
+// SaveLibraryPolicy creates or replaces the rule for a category and tier.
+// Leave both empty for the library's base rule; omitted fields inherit.
+func SaveLibraryPolicy(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Category       string `json:"category"`
+			Tier           string `json:"tier"`
+			LoanPeriodDays *int   `json:"loan_period_days"`
+			MaxLoans       *int   `json:"max_loans"`
+			MaxRenewals    *int   `json:"max_renewals"`
+			FinePerDay     *int64 `json:"fine_per_day"`
+			MaxFine        *int64 `json:"max_fine"`
+			MaxUnpaidFines *int64 `json:"max_unpaid_fines"`
+			HoldPickupDays *int   `json:"hold_pickup_days"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		libraryID, ok := policyLibrary(c, db)
+		if !ok {
+			return
+		}
+
+		editorID := c.GetUint("userID")
+		rule := models.LibraryPolicy{
+			LibraryID:      libraryID,
+			Category:       input.Category,
+			Tier:           input.Tier,
+			LoanPeriodDays: input.LoanPeriodDays,
+			MaxLoans:       input.MaxLoans,
+			MaxRenewals:    input.MaxRenewals,
+			FinePerDay:     input.FinePerDay,
+			MaxFine:        input.MaxFine,
+			MaxUnpaidFines: input.MaxUnpaidFines,
+			HoldPickupDays: input.HoldPickupDays,
+			UpdatedByID:    &editorID,
+		}
+		if problems := policies.Check(rule); len(problems) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid policy", "problems": problems})
+			return
+		}
+
+		rule, err := policies.Save(db, rule)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Policy saved", "rule": rule})
+	}
+}
+
+// DeleteLibraryPolicy removes a rule so its fields inherit again
+func DeleteLibraryPolicy(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ruleID, err := strconv.ParseUint(c.Param("rule_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+			return
+		}
+
+		libraryID, ok := policyLibrary(c, db)
+		if !ok {
+			return
+		}
+
+		if err := policies.Delete(db, libraryID, uint(ruleID)); err != nil {
+			respondTxError(c, err, "Could not delete policy rule")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Policy rule deleted"})
+	}
+}
+
+// SetMemberTier sets a reader's membership tier in a library, which selects
+// the tier rules of its policy
+func SetMemberTier(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Tier string `json:"tier"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		libraryID, ok := policyLibrary(c, db)
+		if !ok {
+			return
+		}
+
+		result := db.Model(&models.UserLibrary{}).
+			Where("user_id = ? AND library_id = ?", c.Param("user_id"), libraryID).
+			Update("tier", input.Tier)
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update membership"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of this library"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Membership tier updated", "tier": input.Tier})
+	}
+}
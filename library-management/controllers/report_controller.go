@@ -0,0 +1,60 @@
+// 📊 Reports
+package controllers
+
+import (
+	"library-management/middleware"
+	"library-management/services/requests"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PickupReport shows how many issue requests a library approved for pickup,
+// how many were collected and which were never collected. Pass
+// ?since=YYYY-MM-DD to count only requests approved from that day on.
+func PickupReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryID, scoped := middleware.ScopedLibrary(c)
+		if !scoped {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only view reports for your assigned library"})
+			return
+		}
+
+		var since time.Time
+		if value := c.Query("since"); value != "" {
+			parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a date like 2024-01-31"})
+				return
+			}
+			since = parsed
+		}
+
+		stats, err := requests.Pickups(db, libraryID, since)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not build pickup report"})
+			return
+		}
+		uncollected, err := requests.Uncollected(db, libraryID, since)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not build pickup report"})
+			return
+		}
+
+		list := make([]gin.H, len(uncollected))
+		for i, request := range uncollected {
+			list[i] = gin.H{
+				"id":            request.ID,
+				"isbn":          request.BookID,
+				"user_id":       request.ReaderID,
+				"approval_date": formatUnixTime(request.ApprovalDate),
+				"approver_id":   request.ApproverID,
+				"expired_at":    formatUnixTime(request.ExpiredAt),
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"library_id": libraryID, "pickups": stats, "uncollected": list})
+	}
+}
@@ -0,0 +1,152 @@
+// 📚 Book Returns
+package controllers
+
+import (
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/fines"
+	"library-management/services/holds"
+	"library-management/services/inventory"
+	"library-management/services/requests"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RequestReturn allows users to request the return of a book they have on loan
+func RequestReturn(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			BookID    string `json:"isbn" binding:"required"`
+			LibraryID uint   `json:"libraryid" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var ok bool
+		if input.BookID, ok = normalizeISBN(c, input.BookID); !ok {
+			return
+		}
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		var loan models.IssueRegistry
+		if err := db.Where("reader_id = ? AND isbn = ? AND library_id = ? AND issue_status IN ?", userID, input.BookID, input.LibraryID, models.ActiveLoanStatuses).
+			First(&loan).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No active loan found for this book in the specified library"})
+			return
+		}
+
+		var existingRequest models.RequestEvent
+		if err := db.Where("issue_id = ? AND request_type = ? AND status IN ?", loan.ID, "return", models.OpenRequestStatuses).First(&existingRequest).Error; err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "You already have a pending return request for this book"})
+			return
+		}
+
+		request := models.RequestEvent{
+			BookID:      input.BookID,
+			LibraryID:   input.LibraryID,
+			ReaderID:    userID.(uint),
+			RequestDate: time.Now().Unix(),
+			RequestType: "return",
+			IssueID:     &loan.ID,
+		}
+
+		readerID := userID.(uint)
+		if err := requests.Create(db, &request, &readerID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create return request"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Return request submitted", "request": request})
+	}
+}
+
+// ApproveReturn allows an admin to approve a return request, closing the loan
+// and putting the copy back into circulation in a single transaction
+func ApproveReturn(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.Param("id")
+
+		adminID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var request models.RequestEvent
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, requestID).Error; err != nil {
+				return newRequestError(http.StatusNotFound, "Return request not found")
+			}
+
+			if request.RequestType != "return" || request.IssueID == nil {
+				return newRequestError(http.StatusBadRequest, "Request is not a return request")
+			}
+
+			if !middleware.CanAccessLibrary(c, request.LibraryID) {
+				return newRequestError(http.StatusForbidden, "You can only approve returns for books in your assigned library")
+			}
+
+			now := time.Now().Unix()
+			approverID := adminID.(uint)
+			request.ApprovalDate = &now
+			request.ApproverID = &approverID
+			if err := requests.Transition(tx, &request, models.RequestApproved, &approverID, ""); err != nil {
+				return err
+			}
+
+			var loan models.IssueRegistry
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&loan, *request.IssueID).Error; err != nil {
+				return newRequestError(http.StatusNotFound, "Loan not found")
+			}
+
+			if loan.IssueStatus == models.LoanReturned {
+				return newRequestError(http.StatusBadRequest, "Book has already been returned")
+			}
+
+			// Settle the fine up to the day the book came back
+			if _, err := fines.Charge(tx, loan, time.Unix(now, 0)); err != nil {
+				return err
+			}
+
+			loan.ReturnDate = now
+			loan.ReturnApproverID = approverID
+			loan.IssueStatus = models.LoanReturned
+			if err := tx.Save(&loan).Error; err != nil {
+				return err
+			}
+
+			// Old loans the item backfill could not link to a copy have none
+			// counted for them, so nothing goes back on the shelf
+			if loan.ItemID != nil {
+				if _, err := inventory.Release(tx, *loan.ItemID); err != nil {
+					return err
+				}
+
+				// The returned copy goes to the next reader waiting for it, if any
+				if _, err := holds.Allocate(tx, loan.ISBN, loan.LibraryID); err != nil {
+					return err
+				}
+			}
+
+			// The copy is back on the shelf, which fulfils the request
+			return requests.Transition(tx, &request, models.RequestFulfilled, &approverID, "")
+		})
+		if err != nil {
+			respondTxError(c, err, "Could not approve return request")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Return request approved"})
+	}
+}
@@ -2,15 +2,29 @@
 package controllers
 
 import (
+	"errors"
+	"library-management/isbn"
+	"library-management/middleware"
 	"library-management/models"
+	"library-management/services/availability"
+	"library-management/services/eligibility"
+	"library-management/services/holds"
+	"library-management/services/policies"
+	"library-management/services/requests"
+	"library-management/services/search"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-// SearchBooks allows users to search for books in their registered libraries
+// SearchBooks allows users to search for books in their registered libraries.
+// ?q= is a free-text query over title, authors, category and publisher, ranked
+// by relevance; title, author, publisher and category filter on one field.
+// Results come a page at a time (limit, offset) in the order given by sort:
+// relevance (default), title, newest or available.
 func SearchBooks(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
@@ -18,39 +32,66 @@ func SearchBooks(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
 			return
 		}
+		readerID := userID.(uint)
 
-		var userLibraries []uint
-		if err := db.Table("user_libraries").Where("user_id = ?", userID).Pluck("library_id", &userLibraries).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch user libraries"})
-			return
-		}
-
+		userLibraries := middleware.AuthorizedLibraries(c)
 		if len(userLibraries) == 0 {
-			c.JSON(http.StatusOK, gin.H{"books": []gin.H{}})
+			c.JSON(http.StatusOK, gin.H{"books": []gin.H{}, "total": 0})
 			return
 		}
 
-		title := c.Query("title")
-		author := c.Query("author")
-		publisher := c.Query("publisher")
-
-		var books []models.Book
-		query := db.Where("library_id IN (?)", userLibraries)
-
-		if title != "" {
-			query = query.Where("title ILIKE ?", "%"+title+"%")
+		query := search.Query{
+			Text:      c.Query("q"),
+			Title:     c.Query("title"),
+			Author:    c.Query("author"),
+			Publisher: c.Query("publisher"),
+			Category:  c.Query("category"),
+			Libraries: userLibraries,
+			Sort:      c.Query("sort"),
+		}
+		for _, param := range []struct {
+			name   string
+			target *int
+		}{{"limit", &query.Limit}, {"offset", &query.Offset}} {
+			raw := c.Query(param.name)
+			if raw == "" {
+				continue
+			}
+			value, err := strconv.Atoi(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param.name})
+				return
+			}
+			*param.target = value
 		}
-		if author != "" {
-			query = query.Where("authors ILIKE ?", "%"+author+"%")
+
+		result, err := search.Books(db, query)
+		if errors.Is(err, search.ErrUnknownSort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort order", "allowed": search.Sorts})
+			return
 		}
-		if publisher != "" {
-			query = query.Where("publisher ILIKE ?", "%"+publisher+"%")
+		if errors.Is(err, search.ErrInvalidLimit) || errors.Is(err, search.ErrInvalidPage) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-
-		if err := query.Select("isbn, title, authors, publisher, available_copies, library_id").Find(&books).Error; err != nil {
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error searching books"})
 			return
 		}
+		books := result.Books
+
+		// Titles with no copies on the shelf get a forecast, computed for the
+		// whole page at once
+		var unavailable []models.Book
+		for _, book := range books {
+			if book.AvailableCopies == 0 {
+				unavailable = append(unavailable, book)
+			}
+		}
+		forecasts, err := availability.ForBooks(db, unavailable, readerID, time.Now())
+		if err != nil {
+			forecasts = nil // The search still answers without the dates
+		}
 
 		response := make([]gin.H, 0, len(books))
 		for _, book := range books {
@@ -64,32 +105,66 @@ func SearchBooks(db *gorm.DB) gin.HandlerFunc {
 				"title":            book.Title,
 				"author":           authors,
 				"publisher":        book.Publisher,
+				"category":         book.Category,
 				"available_copies": book.AvailableCopies,
 				"library_id":       book.LibraryID,
 			}
 
 			if book.AvailableCopies == 0 {
-				var nextAvailableDate time.Time
-				var issue models.IssueRegistry
-
-				if err := db.Where("isbn = ? AND return_date IS NULL", book.ISBN).
-					Order("expected_return_date ASC").
-					First(&issue).Error; err == nil {
-					nextAvailableDate = time.Unix(issue.ExpectedReturnDate, 0)
-					bookData["next_available_date"] = nextAvailableDate.Format("2006-01-02 15:04:05")
-				} else {
-					bookData["next_available_date"] = "Unknown"
+				bookData["next_available_date"] = "Unknown"
+				if forecast, ok := forecasts[book.ID]; ok && forecast.EstimatedDate != nil {
+					bookData["next_available_date"] = forecast.EstimatedDate.Format("2006-01-02 15:04:05")
 				}
 			}
 
 			response = append(response, bookData)
 		}
 
-		c.JSON(http.StatusOK, gin.H{"books": response})
+		page := gin.H{"books": response, "total": result.Total, "limit": result.Limit, "offset": query.Offset}
+		if next := query.Offset + len(books); int64(next) < result.Total {
+			page["next_offset"] = next
+		}
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+// normalizeISBN returns raw as a bare ISBN-13, answering 400 if it is not a valid ISBN
+func normalizeISBN(c *gin.Context, raw string) (string, bool) {
+	normalized, err := isbn.Normalize(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ISBN: use a 10 or 13 digit ISBN with a correct check digit"})
+		return "", false
+	}
+	return normalized, true
+}
+
+// BookAvailability forecasts when the caller can expect a copy of a title in
+// a library, counting outstanding loans and the holds queue ahead of them
+func BookAvailability(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryID, scoped := middleware.ScopedLibrary(c)
+		if !scoped {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only check libraries you are registered in"})
+			return
+		}
+
+		isbn, ok := normalizeISBN(c, c.Param("isbn"))
+		if !ok {
+			return
+		}
+
+		forecast, err := availability.For(db, isbn, libraryID, c.GetUint("userID"), time.Now())
+		if err != nil {
+			respondTxError(c, err, "Could not forecast availability")
+			return
+		}
+
+		c.JSON(http.StatusOK, forecast)
 	}
 }
 
-// RequestIssue allows users to request books from admins
+// RequestIssue allows users to request books from admins. A reader with a copy
+// already set aside, by an approval or a ready hold, gets that request back.
 func RequestIssue(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input struct {
@@ -101,6 +176,10 @@ func RequestIssue(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		var ok bool
+		if input.BookID, ok = normalizeISBN(c, input.BookID); !ok {
+			return
+		}
 
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -114,19 +193,42 @@ func RequestIssue(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		if book.AvailableCopies == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Book not available for issue"})
+		if libraryID, scoped := middleware.ScopedLibrary(c); !scoped || libraryID != input.LibraryID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only request books from libraries you are registered in"})
 			return
 		}
 
-		var userLibrary models.UserLibrary
-		if err := db.Where("user_id = ? AND library_id = ?", userID, input.LibraryID).First(&userLibrary).Error; err != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "You can only request books from libraries you are registered in"})
+		// A copy already set aside for the reader, by an approval or a ready
+		// hold, is collected through its request
+		var setAside models.RequestEvent
+		if err := db.Where("reader_id = ? AND book_id = ? AND library_id = ? AND request_type = ? AND status = ?", userID, input.BookID, input.LibraryID, "issue", models.RequestReadyForPickup).First(&setAside).Error; err == nil {
+			c.JSON(http.StatusOK, gin.H{"message": "A copy is already set aside for you; collect it at the desk", "request": setAside})
+			return
+		}
+
+		policy, err := policies.ForReader(db, userID.(uint), book)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check library policy"})
+			return
+		}
+		if err := eligibility.Check(db, userID.(uint), input.LibraryID, policy, time.Now()); err != nil {
+			respondTxError(c, err, "Could not check your eligibility")
+			return
+		}
+
+		// With no copies on the shelf the reader joins the holds queue instead
+		if book.AvailableCopies == 0 {
+			hold, position, err := holds.Place(db, userID.(uint), input.BookID, input.LibraryID)
+			if err != nil {
+				respondTxError(c, err, "Could not place hold")
+				return
+			}
+			c.JSON(http.StatusAccepted, gin.H{"message": "No copies are available; you have joined the holds queue", "hold": hold, "position": position})
 			return
 		}
 
 		var existingRequest models.RequestEvent
-		if err := db.Where("reader_id = ? AND book_id = ? AND library_id = ? AND approval_date IS NULL", userID, input.BookID, input.LibraryID).First(&existingRequest).Error; err == nil {
+		if err := db.Where("reader_id = ? AND book_id = ? AND library_id = ? AND request_type = ? AND status IN ?", userID, input.BookID, input.LibraryID, "issue", models.OpenRequestStatuses).First(&existingRequest).Error; err == nil {
 			c.JSON(http.StatusConflict, gin.H{"error": "You already have a pending request for this book in this library"})
 			return
 		}
@@ -142,7 +244,8 @@ func RequestIssue(db *gorm.DB) gin.HandlerFunc {
 			RequestType:  "issue",
 		}
 
-		if err := db.Create(&request).Error; err != nil {
+		readerID := userID.(uint)
+		if err := requests.Create(db, &request, &readerID); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create issue request"})
 			return
 		}
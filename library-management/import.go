@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"library-management/config"
+	"library-management/models"
+	"library-management/services/imports"
+	"os"
+	"path/filepath"
+)
+
+// runImport implements `import -library id [-format csv|marc|marcxml] file`.
+// The file is imported straight away rather than left for the server's job.
+func runImport(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	libraryID := flags.Uint("library", 0, "library to import the books into")
+	format := flags.String("format", "", "csv, marc or marcxml (default from the file extension)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *libraryID == 0 || flags.NArg() != 1 {
+		return errors.New("usage: import -library id [-format csv|marc|marcxml] file")
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = imports.DetectFormat(path)
+	}
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	db, err := config.ConnectDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	if err := db.First(&models.Library{}, *libraryID).Error; err != nil {
+		return fmt.Errorf("library %d: %w", *libraryID, err)
+	}
+
+	job, err := imports.Create(db, *libraryID, nil, *format, filepath.Base(path), payload)
+	if err != nil {
+		return err
+	}
+	if err := imports.Run(context.Background(), db, job.ID); err != nil {
+		return err
+	}
+
+	job, rowErrors, err := imports.Get(db, job.ID)
+	if err != nil {
+		return err
+	}
+	for _, rowError := range rowErrors {
+		fmt.Printf("❌ Row %d %s: %s\n", rowError.Row, rowError.ISBN, rowError.Message)
+	}
+	fmt.Printf("✅ Import %d %s: %d of %d row(s) imported, %d failed\n", job.ID, job.Status, job.ImportedRows, job.TotalRows, job.FailedRows)
+	if job.Error != "" {
+		return errors.New(job.Error)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Package isbn validates and normalizes ISBNs. Books, requests, loans and holds
+// store the canonical form: the 13 digits of the ISBN-13 with no separators,
+// so "0-13-468599-7", "978-0-13-468599-1" and "9780134685991" are one title.
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalid  = errors.New("invalid ISBN")
+	ErrNoISBN10 = errors.New("ISBN-13 has no ISBN-10 form")
+)
+
+// Normalize checks an ISBN-10 or ISBN-13, written with or without hyphens and
+// spaces, and returns it as a bare ISBN-13
+func Normalize(raw string) (string, error) {
+	s := strip(raw)
+	switch {
+	case len(s) == 10 && valid10(s):
+		return To13(s)
+	case len(s) == 13 && valid13(s):
+		return s, nil
+	}
+	return "", ErrInvalid
+}
+
+// Valid reports whether raw is an ISBN-10 or ISBN-13 with a correct check digit
+func Valid(raw string) bool {
+	_, err := Normalize(raw)
+	return err == nil
+}
+
+// To13 converts an ISBN-10 to its ISBN-13 form
+func To13(isbn10 string) (string, error) {
+	s := strip(isbn10)
+	if len(s) != 10 || !valid10(s) {
+		return "", ErrInvalid
+	}
+	body := "978" + s[:9]
+	return body + string(check13(body)), nil
+}
+
+// To10 converts an ISBN-13 to its ISBN-10 form. Only 978-prefixed ISBNs have one.
+func To10(isbn13 string) (string, error) {
+	s := strip(isbn13)
+	if len(s) != 13 || !valid13(s) {
+		return "", ErrInvalid
+	}
+	if !strings.HasPrefix(s, "978") {
+		return "", ErrNoISBN10
+	}
+	body := s[3:12]
+	return body + string(check10(body)), nil
+}
+
+// strip drops hyphens and spaces and upper-cases an ISBN-10 "x" check digit
+func strip(raw string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(raw)))
+}
+
+func valid10(s string) bool {
+	return digits(s[:9]) && check10(s[:9]) == s[9]
+}
+
+func valid13(s string) bool {
+	return digits(s) && check13(s[:12]) == s[12]
+}
+
+// check10 computes the ISBN-10 check digit of the first nine digits
+func check10(body string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	switch check := (11 - sum%11) % 11; check {
+	case 10:
+		return 'X'
+	default:
+		return byte('0' + check)
+	}
+}
+
+// check13 computes the ISBN-13 check digit of the first twelve digits
+func check13(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(body[i]-'0') * weight
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func digits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
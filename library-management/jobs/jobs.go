@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"context"
+	"library-management/config"
+	"library-management/services/fines"
+	"library-management/services/holds"
+	"library-management/services/imports"
+	"library-management/services/loans"
+	"library-management/services/requests"
+	"library-management/services/tokens"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Default returns a scheduler with the server's standard jobs registered
+func Default(db *gorm.DB, cfg *config.Config) *Scheduler {
+	s := NewScheduler(db)
+	s.Every("overdue-loans", cfg.JobInterval, OverdueLoans)
+	s.Every("expire-holds", cfg.JobInterval, ExpireHolds)
+	s.Every("expire-requests", cfg.JobInterval, ExpireRequests)
+	s.Every("import-catalog", cfg.JobInterval, ImportCatalog)
+	s.Every("purge-tokens", time.Hour, PurgeTokens)
+	return s
+}
+
+// OverdueLoans marks loans past their due date as overdue and brings their
+// fines up to date
+func OverdueLoans(ctx context.Context, db *gorm.DB, now time.Time) error {
+	marked, err := loans.MarkOverdue(db, now)
+	if err != nil {
+		return err
+	}
+	if marked > 0 {
+		log.Printf("Marked %d loan(s) overdue", marked)
+	}
+	_, err = fines.AccrueOverdue(db, now)
+	return err
+}
+
+// ExpireHolds expires uncollected holds and passes their copies to the next reader
+func ExpireHolds(ctx context.Context, db *gorm.DB, now time.Time) error {
+	expired, err := holds.ExpireOverdue(db, now)
+	if expired > 0 {
+		log.Printf("Expired %d uncollected hold(s)", expired)
+	}
+	return err
+}
+
+// ExpireRequests expires requests left unapproved or uncollected for too long
+func ExpireRequests(ctx context.Context, db *gorm.DB, now time.Time) error {
+	expired, err := requests.ExpireStale(db, now, config.AppConfig.RequestExpiry, config.AppConfig.PickupExpiry)
+	if expired > 0 {
+		log.Printf("Expired %d stale request(s)", expired)
+	}
+	return err
+}
+
+// ImportCatalog requeues imports whose runner died and works through queued
+// catalog imports
+func ImportCatalog(ctx context.Context, db *gorm.DB, now time.Time) error {
+	requeued, err := imports.RequeueStale(db, now, config.AppConfig.ImportTimeout)
+	if requeued > 0 {
+		log.Printf("Requeued %d interrupted catalog import(s)", requeued)
+	}
+	if err != nil {
+		return err
+	}
+	finished, err := imports.RunQueued(ctx, db)
+	if finished > 0 {
+		log.Printf("Finished %d catalog import(s)", finished)
+	}
+	return err
+}
+
+// PurgeTokens removes refresh tokens and revocation entries past their expiry
+func PurgeTokens(ctx context.Context, db *gorm.DB, now time.Time) error {
+	_, err := tokens.PurgeExpired(db, now)
+	return err
+}
@@ -0,0 +1,88 @@
+// Package jobs runs periodic background work such as expiring uncollected
+// holds. Each job runs on its own ticker; a failing run is logged and retried
+// on the next tick.
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Job is one piece of periodic work
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context, db *gorm.DB, now time.Time) error
+}
+
+// Scheduler runs registered jobs until its context is cancelled
+type Scheduler struct {
+	db   *gorm.DB
+	jobs []Job
+	wg   sync.WaitGroup
+}
+
+// NewScheduler returns a scheduler with no jobs
+func NewScheduler(db *gorm.DB) *Scheduler {
+	return &Scheduler{db: db}
+}
+
+// Every registers a job to run at the given interval
+func (s *Scheduler) Every(name string, interval time.Duration, run func(ctx context.Context, db *gorm.DB, now time.Time) error) {
+	s.jobs = append(s.jobs, Job{Name: name, Interval: interval, Run: run})
+}
+
+// Jobs returns the registered jobs
+func (s *Scheduler) Jobs() []Job {
+	return append([]Job(nil), s.jobs...)
+}
+
+// Start runs every job once and then on its interval in the background.
+// Call Wait after cancelling ctx to let running jobs finish.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job Job) {
+			defer s.wg.Done()
+			ticker := time.NewTicker(job.Interval)
+			defer ticker.Stop()
+
+			for {
+				s.run(ctx, job)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(job)
+	}
+}
+
+// Wait blocks until every job goroutine has stopped
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// RunOnce runs every job a single time, in registration order
+func (s *Scheduler) RunOnce(ctx context.Context) error {
+	for _, job := range s.jobs {
+		if err := job.Run(ctx, s.db.WithContext(ctx), time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	if ctx.Err() != nil {
+		return
+	}
+	if err := job.Run(ctx, s.db.WithContext(ctx), time.Now()); err != nil {
+		log.Printf("⚠️ Job %s failed: %v", job.Name, err)
+	}
+}
@@ -1,25 +1,104 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"library-management/config"
+	"library-management/jobs"
+	"library-management/migrations"
 	"library-management/routes"
+	"library-management/utils"
+	"os"
 
 	"log"
+
+	"github.com/gin-gonic/gin"
 )
 
+const usage = `Usage: library-management [-config file] [command]
+
+Commands:
+  serve                  Start the HTTP server (default)
+  migrate up             Apply all pending migrations
+  migrate down [steps]   Roll back the latest migrations (default 1)
+  migrate status         List migrations and whether they are applied
+  normalize-isbns        Rewrite stored ISBNs as bare ISBN-13s and merge duplicate books
+    [-dry-run]           Report what would change without saving it
+  import -library id     Import books from a CSV, MARC21 or MARCXML file
+    [-format f] file     Format is csv, marc or marcxml (default from the extension)
+`
+
 func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	flag.Usage = func() { fmt.Fprint(flag.CommandLine.Output(), usage) }
+	flag.Parse()
+
+	// Load and validate settings before touching the database
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+	config.AppConfig = cfg
+	utils.ConfigureJWT(cfg.JWTSecret, cfg.TokenTTL)
+
+	switch flag.Arg(0) {
+	case "", "serve":
+		serve(cfg)
+	case "migrate":
+		if err := runMigrate(cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	case "normalize-isbns":
+		if err := runNormalizeISBNs(cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("ISBN normalization failed: %v", err)
+		}
+	case "import":
+		if err := runImport(cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func serve(cfg *config.Config) {
+	if cfg.IsDevelopment() {
+		log.Println("⚠️ Running in development mode")
+	}
+	if cfg.LogLevel != "debug" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
 	// Initialize the database and handle errors
-	db, err := config.ConnectDatabase()
+	db, err := config.ConnectDatabase(cfg)
 	if err != nil {
 		log.Fatalf("Database initialization failed: %v", err)
 	}
 
+	// Refuse to serve against an outdated schema unless asked to migrate it
+	if cfg.MigrateOnStart {
+		ran, err := migrations.Up(db)
+		if err != nil {
+			log.Fatalf("Database migration failed: %v", err)
+		}
+		log.Printf("Applied %d migration(s)", len(ran))
+	} else if pending, err := migrations.Pending(db); err != nil {
+		log.Fatalf("Could not check migrations: %v", err)
+	} else if pending > 0 {
+		log.Fatalf("Database has %d pending migration(s); run `library-management migrate up` first", pending)
+	}
+
+	// Expire uncollected holds and purge old tokens in the background
+	jobs.Default(db, cfg).Start(context.Background())
+
 	// Set up the Gin router with the database instance
 	r := routes.SetupRouter(db)
 
-	// Start the server on port 8080
-	log.Println("Server is running on port 8080...")
-	if err := r.Run(":8080"); err != nil {
+	// Start the server on the configured address
+	log.Printf("Server is running on %s...", cfg.ListenAddr)
+	if err := r.Run(cfg.ListenAddr); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
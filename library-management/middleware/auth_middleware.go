@@ -1,16 +1,17 @@
 package middleware
 
 import (
-	"fmt"
+	"library-management/services/tokens"
 	"library-management/utils"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-// AuthMiddleware verifies JWT and checks user role
-func AuthMiddleware(requiredRole string) gin.HandlerFunc {
+// AuthMiddleware verifies JWT, rejects revoked tokens and checks user role
+func AuthMiddleware(db *gorm.DB, requiredRole string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := c.GetHeader("Authorization")
 
@@ -30,14 +31,27 @@ func AuthMiddleware(requiredRole string) gin.HandlerFunc {
 
 		tokenString = tokenParts[1] // Extract actual token
 
-		// Validate JWT using utils.ValidateJWT
-		userID, userRole, err := utils.ValidateJWT(tokenString)
-		if err != nil {
-			fmt.Println("JWT Validation Error:", err) // Log the error
+		// Validate JWT using utils.ParseJWT
+		claims, err := utils.ParseJWT(tokenString)
+		if err != nil || claims.JTI == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
+		userID, userRole := claims.UserID, claims.Role
+
+		// Reject tokens revoked by logout or refresh token reuse
+		revoked, err := tokens.IsRevoked(db, claims.JTI)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify token"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
 
 		// If a role is required, check access
 		if requiredRole != "" {
@@ -64,6 +78,8 @@ func AuthMiddleware(requiredRole string) gin.HandlerFunc {
 		// Store user details in context for later use
 		c.Set("userID", userID)
 		c.Set("userRole", userRole)
+		c.Set("libraryIDs", claims.LibraryIDs)
+		c.Set("tokenClaims", claims)
 		c.Next()
 	}
 }
@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"library-management/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LibrarySource resolves the library a request acts on. It returns 0 when the
+// request does not name a library.
+type LibrarySource func(c *gin.Context) (uint, error)
+
+// sourceError lets a LibrarySource choose the response status
+type sourceError struct {
+	status  int
+	message string
+}
+
+func (e *sourceError) Error() string { return e.message }
+
+var errInvalidLibraryID = &sourceError{status: http.StatusBadRequest, message: "Invalid library ID"}
+
+// LibraryFromParam reads the library ID from a path parameter
+func LibraryFromParam(name string) LibrarySource {
+	return func(c *gin.Context) (uint, error) {
+		return parseLibraryID(c.Param(name))
+	}
+}
+
+// LibraryFromQuery reads the library ID from a query string parameter
+func LibraryFromQuery(name string) LibrarySource {
+	return func(c *gin.Context) (uint, error) {
+		return parseLibraryID(c.Query(name))
+	}
+}
+
+// maxLibraryBodyBytes caps the JSON body LibraryFromBody reads into memory
+const maxLibraryBodyBytes = 1 << 20
+
+// LibraryFromBody reads the library ID from a field of the JSON body. Like
+// encoding/json the field name is matched case-insensitively; a body with more
+// than one matching key is refused so the handler cannot bind a different
+// library from the one checked here. The body is restored so the handler can
+// bind it again.
+func LibraryFromBody(field string) LibrarySource {
+	return func(c *gin.Context) (uint, error) {
+		if c.Request.Body == nil {
+			return 0, nil
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxLibraryBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return 0, &sourceError{status: http.StatusRequestEntityTooLarge, message: "Request body too large"}
+			}
+			return 0, err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		raw, found, err := bodyField(body, field)
+		if err != nil {
+			return 0, err
+		}
+		if !found {
+			return 0, nil
+		}
+
+		var id uint
+		if err := json.Unmarshal(raw, &id); err != nil {
+			return 0, errInvalidLibraryID
+		}
+		return id, nil
+	}
+}
+
+// bodyField returns the value of the single top-level key of a JSON object
+// that matches field case-insensitively
+func bodyField(body []byte, field string) (json.RawMessage, bool, error) {
+	invalid := &sourceError{status: http.StatusBadRequest, message: "Invalid JSON format"}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, false, invalid
+	}
+
+	var raw json.RawMessage
+	found := false
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, false, invalid
+		}
+		key, _ := token.(string)
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, false, invalid
+		}
+		if !strings.EqualFold(key, field) {
+			continue
+		}
+		if found {
+			return nil, false, &sourceError{status: http.StatusBadRequest, message: "Duplicate " + field + " field"}
+		}
+		raw, found = value, true
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, false, invalid
+	}
+	return raw, found, nil
+}
+
+// LibraryFromRequestEvent resolves the library of the issue/return request
+// whose ID is in the given path parameter
+func LibraryFromRequestEvent(db *gorm.DB, param string) LibrarySource {
+	return func(c *gin.Context) (uint, error) {
+		var request models.RequestEvent
+		if err := db.Select("id, library_id").First(&request, c.Param(param)).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, &sourceError{status: http.StatusNotFound, message: "Request not found"}
+			}
+			return 0, err
+		}
+		return request.LibraryID, nil
+	}
+}
+
+// LibraryFromLoan resolves the library of the loan whose ID is in the given
+// path parameter
+func LibraryFromLoan(db *gorm.DB, param string) LibrarySource {
+	return func(c *gin.Context) (uint, error) {
+		var loan models.IssueRegistry
+		if err := db.Select("id, library_id").First(&loan, c.Param(param)).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, &sourceError{status: http.StatusNotFound, message: "Loan not found"}
+			}
+			return 0, err
+		}
+		return loan.LibraryID, nil
+	}
+}
+
+// LibraryFromImport resolves the library of the catalog import whose ID is in
+// the given path parameter
+func LibraryFromImport(db *gorm.DB, param string) LibrarySource {
+	return func(c *gin.Context) (uint, error) {
+		var job models.CatalogImport
+		if err := db.Select("id, library_id").First(&job, c.Param(param)).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, &sourceError{status: http.StatusNotFound, message: "Import not found"}
+			}
+			return 0, err
+		}
+		return job.LibraryID, nil
+	}
+}
+
+func parseLibraryID(value string) (uint, error) {
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, errInvalidLibraryID
+	}
+	return uint(id), nil
+}
+
+// RequireLibraryRole lets the request through only when the caller has one of
+// the given roles ("admin" or "admin|owner") and belongs to the library named
+// by source. Owners run every library and skip the membership check. It must
+// run after AuthMiddleware; memberships come from the token, so changes apply
+// once the caller refreshes it.
+func RequireLibraryRole(role string, source LibrarySource) gin.HandlerFunc {
+	allowedRoles := strings.Split(role, "|")
+
+	return func(c *gin.Context) {
+		userRole := c.GetString("userRole")
+		roleAllowed := false
+		for _, allowed := range allowedRoles {
+			if userRole == allowed {
+				roleAllowed = true
+				break
+			}
+		}
+		if !roleAllowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied", "requiredRole": role, "yourRole": userRole})
+			return
+		}
+
+		libraryID, err := source(c)
+		if err != nil {
+			var srcErr *sourceError
+			if errors.As(err, &srcErr) {
+				c.AbortWithStatusJSON(srcErr.status, gin.H{"error": srcErr.message})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Could not resolve library"})
+			return
+		}
+		if libraryID == 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Library ID is required"})
+			return
+		}
+
+		if userRole != "owner" && !CanAccessLibrary(c, libraryID) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You are not assigned to this library"})
+			return
+		}
+
+		c.Set("libraryID", libraryID)
+		c.Next()
+	}
+}
+
+// AuthorizedLibraries returns the libraries the caller belongs to
+func AuthorizedLibraries(c *gin.Context) []uint {
+	if ids, ok := c.Get("libraryIDs"); ok {
+		if libraryIDs, ok := ids.([]uint); ok {
+			return libraryIDs
+		}
+	}
+	return nil
+}
+
+// CanAccessLibrary reports whether the caller belongs to a library
+func CanAccessLibrary(c *gin.Context, libraryID uint) bool {
+	for _, id := range AuthorizedLibraries(c) {
+		if id == libraryID {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopedLibrary returns the library checked by RequireLibraryRole
+func ScopedLibrary(c *gin.Context) (uint, bool) {
+	id, ok := c.Get("libraryID")
+	if !ok {
+		return 0, false
+	}
+	libraryID, ok := id.(uint)
+	return libraryID, ok
+}
@@ -0,0 +1,115 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// Snapshot of the models as they were created by AutoMigrate before versioned
+// migrations existed. AutoMigrate is used for Up so databases that were set up
+// the old way adopt this version without losing data.
+
+type v1Library struct {
+	ID   uint   `gorm:"primaryKey"`
+	Name string `gorm:"unique;not null"`
+}
+
+func (v1Library) TableName() string { return "libraries" }
+
+type v1User struct {
+	gorm.Model
+	ID       uint   `gorm:"primaryKey"`
+	Name     string `gorm:"not null"`
+	Email    string `gorm:"unique;not null"`
+	Contact  string
+	Role     string `gorm:"type:varchar(50);check:role IN ('owner', 'admin', 'user')"`
+	Password string `gorm:"not null"`
+}
+
+func (v1User) TableName() string { return "users" }
+
+type v1Book struct {
+	gorm.Model
+	ID              uint   `gorm:"primaryKey"`
+	ISBN            string `gorm:"not null"`
+	Title           string `gorm:"not null"`
+	Authors         string
+	Publisher       string
+	Version         string
+	TotalCopies     int
+	AvailableCopies int
+	LibraryID       uint `gorm:"index"`
+}
+
+func (v1Book) TableName() string { return "books" }
+
+type v1RequestEvent struct {
+	gorm.Model
+	ID           uint   `gorm:"primaryKey"`
+	BookID       string `gorm:"not null"`
+	LibraryID    uint   `gorm:"not null"`
+	ReaderID     uint   `gorm:"not null"`
+	RequestDate  int64  `gorm:"not null"`
+	ApprovalDate *int64 `gorm:"default:null"`
+	ApproverID   *uint  `gorm:"default:null"`
+	RequestType  string `gorm:"type:varchar(50);not null;check:request_type IN ('issue', 'return')"`
+	IssueID      *uint  `gorm:"default:null"`
+}
+
+func (v1RequestEvent) TableName() string { return "request_events" }
+
+type v1IssueRegistry struct {
+	gorm.Model
+	ISBN               string `gorm:"not null"`
+	LibraryID          uint   `gorm:"index"`
+	ReaderID           uint   `gorm:"not null"`
+	IssueApproverID    uint   `gorm:"not null"`
+	IssueStatus        string `gorm:"type:varchar(50);not null"`
+	IssueDate          int64  `gorm:"not null"`
+	ExpectedReturnDate int64  `gorm:"not null"`
+	ReturnDate         int64  `gorm:"default:0"`
+	ReturnApproverID   uint   `gorm:"default:0"`
+}
+
+func (v1IssueRegistry) TableName() string { return "issue_registries" }
+
+type v1UserLibrary struct {
+	UserID    uint `gorm:"primaryKey"`
+	LibraryID uint `gorm:"primaryKey"`
+}
+
+func (v1UserLibrary) TableName() string { return "user_libraries" }
+
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "initial_schema",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(
+				&v1Library{},
+				&v1User{},
+				&v1Book{},
+				&v1RequestEvent{},
+				&v1IssueRegistry{},
+				&v1UserLibrary{},
+			); err != nil {
+				return err
+			}
+
+			// Loans issued before loans recorded their library get the library
+			// of their ISBN when only one library holds it, so their readers can
+			// return them. Loans of ISBNs held by several libraries keep none.
+			return tx.Exec(`UPDATE issue_registries SET
+				library_id = (SELECT MIN(books.library_id) FROM books WHERE books.isbn = issue_registries.isbn)
+				WHERE (library_id IS NULL OR library_id = 0)
+				AND (SELECT COUNT(DISTINCT books.library_id) FROM books WHERE books.isbn = issue_registries.isbn) = 1`).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(
+				&v1UserLibrary{},
+				&v1IssueRegistry{},
+				&v1RequestEvent{},
+				&v1Book{},
+				&v1User{},
+				&v1Library{},
+			)
+		},
+	})
+}
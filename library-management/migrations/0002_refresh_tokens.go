@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type v2RefreshToken struct {
+	ID              uint   `gorm:"primaryKey"`
+	UserID          uint   `gorm:"not null;index"`
+	FamilyID        string `gorm:"type:varchar(64);not null;index"`
+	TokenHash       string `gorm:"type:varchar(64);not null;uniqueIndex"`
+	AccessJTI       string `gorm:"type:varchar(64)"`
+	AccessExpiresAt time.Time
+	ExpiresAt       time.Time `gorm:"not null"`
+	UsedAt          *time.Time
+	RevokedAt       *time.Time
+	CreatedAt       time.Time
+}
+
+func (v2RefreshToken) TableName() string { return "refresh_tokens" }
+
+type v2RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;type:varchar(64)"`
+	UserID    uint      `gorm:"index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+func (v2RevokedToken) TableName() string { return "revoked_tokens" }
+
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "refresh_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&v2RefreshToken{}, &v2RevokedToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v2RevokedToken{}, &v2RefreshToken{})
+		},
+	})
+}
@@ -0,0 +1,37 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// v3RequestEvent adds the fields recorded when an admin rejects a request
+type v3RequestEvent struct {
+	RejectionReason string `gorm:"type:text"`
+	RejectedByID    *uint  `gorm:"default:null"`
+	RejectedAt      *int64 `gorm:"default:null"`
+}
+
+func (v3RequestEvent) TableName() string { return "request_events" }
+
+var v3RequestEventColumns = []string{"RejectionReason", "RejectedByID", "RejectedAt"}
+
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "request_rejections",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range v3RequestEventColumns {
+				if err := tx.Migrator().AddColumn(&v3RequestEvent{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range v3RequestEventColumns {
+				if err := tx.Migrator().DropColumn(&v3RequestEvent{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,66 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// v4RequestEvent adds the explicit lifecycle status
+type v4RequestEvent struct {
+	Status string `gorm:"type:varchar(20);not null;default:'pending';index"`
+}
+
+func (v4RequestEvent) TableName() string { return "request_events" }
+
+type v4RequestTransition struct {
+	ID         uint   `gorm:"primaryKey"`
+	RequestID  uint   `gorm:"not null;index"`
+	FromStatus string `gorm:"type:varchar(20)"`
+	ToStatus   string `gorm:"type:varchar(20);not null"`
+	ActorID    *uint
+	Reason     string `gorm:"type:text"`
+	CreatedAt  time.Time
+}
+
+func (v4RequestTransition) TableName() string { return "request_transitions" }
+
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "request_status",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&v4RequestEvent{}, "Status"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateIndex(&v4RequestEvent{}, "Status"); err != nil {
+				return err
+			}
+
+			// Derive the status of existing rows. Before this version a
+			// disapproval soft-deleted the request; those rows come back as
+			// rejected. Approval already issued or returned the book.
+			backfill := []string{
+				"UPDATE request_events SET status = 'rejected', deleted_at = NULL WHERE deleted_at IS NOT NULL AND approval_date IS NULL AND request_type = 'issue'",
+				"UPDATE request_events SET status = 'rejected' WHERE rejected_at IS NOT NULL",
+				"UPDATE request_events SET status = 'fulfilled' WHERE approval_date IS NOT NULL",
+			}
+			for _, statement := range backfill {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+
+			return tx.Migrator().CreateTable(&v4RequestTransition{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&v4RequestTransition{}); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropIndex(&v4RequestEvent{}, "Status"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&v4RequestEvent{}, "Status")
+		},
+	})
+}
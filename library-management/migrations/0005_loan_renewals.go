@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// v5IssueRegistry adds the number of times a loan was renewed
+type v5IssueRegistry struct {
+	RenewalCount int `gorm:"not null;default:0"`
+}
+
+func (v5IssueRegistry) TableName() string { return "issue_registries" }
+
+type v5LoanRenewal struct {
+	ID              uint  `gorm:"primaryKey"`
+	IssueID         uint  `gorm:"not null;index"`
+	RenewedByID     uint  `gorm:"not null"`
+	PreviousDueDate int64 `gorm:"not null"`
+	NewDueDate      int64 `gorm:"not null"`
+	CreatedAt       time.Time
+}
+
+func (v5LoanRenewal) TableName() string { return "loan_renewals" }
+
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "loan_renewals",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&v5IssueRegistry{}, "RenewalCount"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateTable(&v5LoanRenewal{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&v5LoanRenewal{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&v5IssueRegistry{}, "RenewalCount")
+		},
+	})
+}
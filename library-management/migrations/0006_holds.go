@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type v6Hold struct {
+	ID             uint   `gorm:"primaryKey"`
+	ISBN           string `gorm:"not null;index:idx_holds_queue"`
+	LibraryID      uint   `gorm:"not null;index:idx_holds_queue"`
+	ReaderID       uint   `gorm:"not null;index"`
+	Status         string `gorm:"type:varchar(20);not null;default:'waiting';index:idx_holds_queue"`
+	ReadyAt        *int64 `gorm:"default:null"`
+	PickupDeadline *int64 `gorm:"default:null"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (v6Hold) TableName() string { return "holds" }
+
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "holds",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&v6Hold{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v6Hold{})
+		},
+	})
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type v7FineEntry struct {
+	ID          uint   `gorm:"primaryKey"`
+	ReaderID    uint   `gorm:"not null;index:idx_fine_entries_account"`
+	LibraryID   uint   `gorm:"not null;index:idx_fine_entries_account"`
+	IssueID     *uint  `gorm:"index"`
+	Kind        string `gorm:"type:varchar(20);not null"`
+	AmountCents int64  `gorm:"not null"`
+	ActorID     *uint
+	Reason      string `gorm:"type:text"`
+	CreatedAt   time.Time
+}
+
+func (v7FineEntry) TableName() string { return "fine_entries" }
+
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "fines",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&v7FineEntry{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v7FineEntry{})
+		},
+	})
+}
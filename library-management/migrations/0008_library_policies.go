@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// v8Book adds the category that selects policy overrides
+type v8Book struct {
+	Category string `gorm:"type:varchar(100);not null;default:''"`
+}
+
+func (v8Book) TableName() string { return "books" }
+
+// v8UserLibrary adds the reader's membership tier
+type v8UserLibrary struct {
+	Tier string `gorm:"type:varchar(50);not null;default:''"`
+}
+
+func (v8UserLibrary) TableName() string { return "user_libraries" }
+
+type v8LibraryPolicy struct {
+	ID             uint   `gorm:"primaryKey"`
+	LibraryID      uint   `gorm:"not null;uniqueIndex:idx_library_policies_scope"`
+	Category       string `gorm:"type:varchar(100);not null;default:'';uniqueIndex:idx_library_policies_scope"`
+	Tier           string `gorm:"type:varchar(50);not null;default:'';uniqueIndex:idx_library_policies_scope"`
+	LoanPeriodDays *int
+	MaxLoans       *int
+	MaxRenewals    *int
+	FinePerDay     *int64
+	MaxFine        *int64
+	HoldPickupDays *int
+	UpdatedByID    *uint
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (v8LibraryPolicy) TableName() string { return "library_policies" }
+
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "library_policies",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&v8Book{}, "Category"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().AddColumn(&v8UserLibrary{}, "Tier"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateTable(&v8LibraryPolicy{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&v8LibraryPolicy{}); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropColumn(&v8UserLibrary{}, "Tier"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&v8Book{}, "Category")
+		},
+	})
+}
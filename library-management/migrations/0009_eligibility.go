@@ -0,0 +1,36 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// v9User adds the account status checked before lending
+type v9User struct {
+	Status string `gorm:"type:varchar(20);not null;default:'active'"`
+}
+
+func (v9User) TableName() string { return "users" }
+
+// v9LibraryPolicy adds the unpaid fines a reader may carry and still borrow
+type v9LibraryPolicy struct {
+	MaxUnpaidFines *int64
+}
+
+func (v9LibraryPolicy) TableName() string { return "library_policies" }
+
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "eligibility",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&v9User{}, "Status"); err != nil {
+				return err
+			}
+			return tx.Migrator().AddColumn(&v9LibraryPolicy{}, "MaxUnpaidFines")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropColumn(&v9LibraryPolicy{}, "MaxUnpaidFines"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&v9User{}, "Status")
+		},
+	})
+}
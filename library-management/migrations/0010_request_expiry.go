@@ -0,0 +1,38 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// v10RequestEvent adds the fields recorded when a request expires
+type v10RequestEvent struct {
+	ExpiryReason string `gorm:"type:text"`
+	ExpiredAt    *int64 `gorm:"default:null"`
+}
+
+func (v10RequestEvent) TableName() string { return "request_events" }
+
+var v10RequestEventColumns = []string{"ExpiryReason", "ExpiredAt"}
+
+func init() {
+	register(Migration{
+		Version: 10,
+		Name:    "request_expiry",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range v10RequestEventColumns {
+				if err := tx.Migrator().AddColumn(&v10RequestEvent{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		// ALTER TABLE keeps the status index; the SQLite migrator would rebuild
+		// the table without it
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"expiry_reason", "expired_at"} {
+				if err := tx.Exec("ALTER TABLE request_events DROP COLUMN " + column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
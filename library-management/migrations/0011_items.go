@@ -0,0 +1,168 @@
+package migrations
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type v11Item struct {
+	ID         uint   `gorm:"primaryKey"`
+	Barcode    string `gorm:"type:varchar(64);not null;uniqueIndex"`
+	BookID     uint   `gorm:"not null;index"`
+	LibraryID  uint   `gorm:"not null;index"`
+	Condition  string `gorm:"type:varchar(20);not null;default:'good'"`
+	Status     string `gorm:"type:varchar(20);not null;default:'available'"`
+	AcquiredAt int64  `gorm:"not null"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (v11Item) TableName() string { return "items" }
+
+// v11IssueRegistry, v11Hold and v11RequestEvent record which copy a loan, a
+// ready hold or a request ready for pickup holds
+type v11IssueRegistry struct {
+	ItemID *uint `gorm:"index"`
+}
+
+func (v11IssueRegistry) TableName() string { return "issue_registries" }
+
+type v11Hold struct {
+	ItemID *uint
+}
+
+func (v11Hold) TableName() string { return "holds" }
+
+type v11RequestEvent struct {
+	ItemID *uint `gorm:"default:null"`
+}
+
+func (v11RequestEvent) TableName() string { return "request_events" }
+
+// v11Book is the part of a book the backfill reads
+type v11Book struct {
+	ID              uint
+	ISBN            string
+	LibraryID       uint
+	TotalCopies     int
+	AvailableCopies int
+	CreatedAt       time.Time
+}
+
+func (v11Book) TableName() string { return "books" }
+
+func init() {
+	register(Migration{
+		Version: 11,
+		Name:    "items",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&v11Item{}); err != nil {
+				return err
+			}
+			if err := tx.Migrator().AddColumn(&v11IssueRegistry{}, "ItemID"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateIndex(&v11IssueRegistry{}, "ItemID"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().AddColumn(&v11Hold{}, "ItemID"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().AddColumn(&v11RequestEvent{}, "ItemID"); err != nil {
+				return err
+			}
+			return backfillItems(tx)
+		},
+		// ALTER TABLE keeps the other indexes; the SQLite migrator would
+		// rebuild the tables without them
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropIndex(&v11IssueRegistry{}, "ItemID"); err != nil {
+				return err
+			}
+			for _, table := range []string{"issue_registries", "holds", "request_events"} {
+				if err := tx.Exec("ALTER TABLE " + table + " DROP COLUMN item_id").Error; err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().DropTable(&v11Item{})
+		},
+	})
+}
+
+// backfillItems gives every copy counted on a book an item. Copies out on loan,
+// set aside for a ready hold or for a request ready for pickup are linked to
+// it; the rest are on the shelf. Counters are then recounted from the items.
+func backfillItems(tx *gorm.DB) error {
+	var books []v11Book
+	if err := tx.Where("deleted_at IS NULL").Order("id").Find(&books).Error; err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		seq := 0
+		addItem := func(status string) (uint, error) {
+			seq++
+			item := v11Item{
+				Barcode:    fmt.Sprintf("%d-%s-%03d", book.LibraryID, book.ISBN, seq),
+				BookID:     book.ID,
+				LibraryID:  book.LibraryID,
+				Condition:  "good",
+				Status:     status,
+				AcquiredAt: book.CreatedAt.Unix(),
+			}
+			err := tx.Create(&item).Error
+			return item.ID, err
+		}
+		link := func(table, where string, args []interface{}, status string) error {
+			var ids []uint
+			if err := tx.Table(table).Where(where, args...).Order("id").Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			for _, id := range ids {
+				itemID, err := addItem(status)
+				if err != nil {
+					return err
+				}
+				if err := tx.Table(table).Where("id = ?", id).Update("item_id", itemID).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if err := link("issue_registries", "isbn = ? AND library_id = ? AND issue_status IN ? AND deleted_at IS NULL",
+			[]interface{}{book.ISBN, book.LibraryID, []string{"issued", "overdue"}}, "on_loan"); err != nil {
+			return err
+		}
+		if err := link("holds", "isbn = ? AND library_id = ? AND status = ?",
+			[]interface{}{book.ISBN, book.LibraryID, "ready"}, "reserved"); err != nil {
+			return err
+		}
+		if err := link("request_events", "book_id = ? AND library_id = ? AND request_type = ? AND status = ? AND deleted_at IS NULL",
+			[]interface{}{book.ISBN, book.LibraryID, "issue", "ready_for_pickup"}, "reserved"); err != nil {
+			return err
+		}
+
+		for i := 0; i < book.AvailableCopies; i++ {
+			if _, err := addItem("available"); err != nil {
+				return err
+			}
+		}
+		// Copies counted as out with nothing recorded against them stay out
+		for seq < book.TotalCopies {
+			if _, err := addItem("on_loan"); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec(`UPDATE books SET
+			total_copies = (SELECT COUNT(*) FROM items WHERE items.book_id = books.id AND items.status <> 'withdrawn'),
+			available_copies = (SELECT COUNT(*) FROM items WHERE items.book_id = books.id AND items.status = 'available')
+			WHERE id = ?`, book.ID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type v12CatalogImport struct {
+	ID            uint `gorm:"primaryKey"`
+	LibraryID     uint `gorm:"not null;index"`
+	UploadedBy    *uint
+	Format        string `gorm:"type:varchar(10);not null"`
+	Filename      string
+	Payload       []byte `gorm:"not null"`
+	Status        string `gorm:"type:varchar(20);not null;default:'queued';index"`
+	TotalRows     int
+	ProcessedRows int
+	ImportedRows  int
+	FailedRows    int
+	Error         string `gorm:"type:text"`
+	StartedAt     *int64 `gorm:"default:null"`
+	FinishedAt    *int64 `gorm:"default:null"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (v12CatalogImport) TableName() string { return "catalog_imports" }
+
+type v12CatalogImportError struct {
+	ID       uint `gorm:"primaryKey"`
+	ImportID uint `gorm:"not null;index"`
+	Row      int  `gorm:"not null"`
+	ISBN     string
+	Message  string `gorm:"type:text;not null"`
+}
+
+func (v12CatalogImportError) TableName() string { return "catalog_import_errors" }
+
+func init() {
+	register(Migration{
+		Version: 12,
+		Name:    "catalog_imports",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&v12CatalogImport{}, &v12CatalogImportError{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v12CatalogImportError{}, &v12CatalogImport{})
+		},
+	})
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// On Postgres books get a weighted tsvector of title (A), authors (B), category
+// (C) and publisher (D), kept up to date by the database and indexed with GIN.
+// Other databases have no full-text index and search falls back to LIKE.
+func init() {
+	register(Migration{
+		Version: 13,
+		Name:    "book_search",
+		Up: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() != "postgres" {
+				return nil
+			}
+			if err := tx.Exec(`ALTER TABLE books ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+				setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+				setweight(to_tsvector('english', coalesce(authors, '')), 'B') ||
+				setweight(to_tsvector('english', coalesce(category, '')), 'C') ||
+				setweight(to_tsvector('english', coalesce(publisher, '')), 'D')
+			) STORED`).Error; err != nil {
+				return err
+			}
+			return tx.Exec("CREATE INDEX idx_books_search_vector ON books USING GIN (search_vector)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() != "postgres" {
+				return nil
+			}
+			if err := tx.Exec("DROP INDEX IF EXISTS idx_books_search_vector").Error; err != nil {
+				return err
+			}
+			return tx.Exec("ALTER TABLE books DROP COLUMN IF EXISTS search_vector").Error
+		},
+	})
+}
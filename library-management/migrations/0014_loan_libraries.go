@@ -0,0 +1,76 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// v14IssueRegistry flags loans whose library could not be worked out
+type v14IssueRegistry struct {
+	NeedsRepair bool `gorm:"not null;default:false"`
+}
+
+func (v14IssueRegistry) TableName() string { return "issue_registries" }
+
+// v14Loan is the part of a loan the backfill reads
+type v14Loan struct {
+	ID        uint
+	ISBN      string
+	LibraryID uint
+}
+
+func (v14Loan) TableName() string { return "issue_registries" }
+
+// Migration 1 gave loans issued before loans recorded their library the library
+// of their ISBN when only one library held it, but the item backfill in
+// migration 11 did not link them to a copy. Each now gets the copy that backfill
+// left out on loan for it. Active loans still without a library, because their
+// ISBN is held by several libraries or by none, are flagged with needs_repair
+// to be fixed by hand.
+func init() {
+	register(Migration{
+		Version: 14,
+		Name:    "loan_libraries",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&v14IssueRegistry{}, "NeedsRepair"); err != nil {
+				return err
+			}
+
+			if err := tx.Exec(`UPDATE issue_registries SET needs_repair = ?
+				WHERE (library_id IS NULL OR library_id = 0) AND issue_status IN ? AND deleted_at IS NULL`,
+				true, []string{"issued", "overdue"}).Error; err != nil {
+				return err
+			}
+			return linkLoanItems(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE issue_registries DROP COLUMN needs_repair").Error
+		},
+	})
+}
+
+// linkLoanItems links active loans without a copy to a copy of their book that
+// is out on loan with no loan recording it. Loans left without one have no copy
+// counted for them; returning them puts nothing back on the shelf.
+func linkLoanItems(tx *gorm.DB) error {
+	var loans []v14Loan
+	if err := tx.Where("item_id IS NULL AND library_id > 0 AND issue_status IN ? AND deleted_at IS NULL", []string{"issued", "overdue"}).
+		Order("id").Find(&loans).Error; err != nil {
+		return err
+	}
+
+	for _, loan := range loans {
+		var itemIDs []uint
+		if err := tx.Table("items").
+			Joins("JOIN books ON books.id = items.book_id").
+			Where("books.isbn = ? AND books.library_id = ? AND books.deleted_at IS NULL AND items.status = ?", loan.ISBN, loan.LibraryID, "on_loan").
+			Where("items.id NOT IN (SELECT item_id FROM issue_registries WHERE item_id IS NOT NULL)").
+			Order("items.id").Limit(1).Pluck("items.id", &itemIDs).Error; err != nil {
+			return err
+		}
+		if len(itemIDs) == 0 {
+			continue
+		}
+		if err := tx.Table("issue_registries").Where("id = ?", loan.ID).Update("item_id", itemIDs[0]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
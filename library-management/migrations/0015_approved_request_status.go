@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// approvedNeverIssued is the expiry reason given to the requests corrected here.
+const approvedNeverIssued = "Approved but never issued"
+
+// Migration 4 marked every approved request fulfilled. Before approvals issued
+// the book, an approved issue request often never became a loan; such a request
+// holds no copy and is expired instead, with the reason shown to its reader. A
+// request counts as fulfilled when the reader has a loan of the book issued after
+// the request in the same library, or in no recorded library: loans migration 1
+// could not place may belong to it. Only rows migration 4 backfilled are
+// corrected: those have no transition history, while every request created
+// since records its own.
+func init() {
+	register(Migration{
+		Version: 15,
+		Name:    "approved_request_status",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec(`UPDATE request_events SET status = 'expired', expiry_reason = ?, expired_at = ?
+				WHERE status = 'fulfilled' AND request_type = 'issue' AND approval_date IS NOT NULL
+				AND NOT EXISTS (SELECT 1 FROM request_transitions WHERE request_transitions.request_id = request_events.id)
+				AND NOT EXISTS (SELECT 1 FROM issue_registries WHERE issue_registries.reader_id = request_events.reader_id
+					AND issue_registries.isbn = request_events.book_id
+					AND (issue_registries.library_id = request_events.library_id OR issue_registries.library_id IS NULL OR issue_registries.library_id = 0)
+					AND issue_registries.issue_date >= request_events.request_date)`,
+				approvedNeverIssued, time.Now().Unix()).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec(`UPDATE request_events SET status = 'fulfilled', expiry_reason = NULL, expired_at = NULL
+				WHERE status = 'expired' AND expiry_reason = ? AND request_type = 'issue' AND approval_date IS NOT NULL
+				AND NOT EXISTS (SELECT 1 FROM request_transitions WHERE request_transitions.request_id = request_events.id)`,
+				approvedNeverIssued).Error
+		},
+	})
+}
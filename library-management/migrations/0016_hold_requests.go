@@ -0,0 +1,115 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// v16Hold links a ready hold to the request the desk checks it out through
+type v16Hold struct {
+	ID             uint
+	ISBN           string
+	LibraryID      uint
+	ReaderID       uint
+	Status         string
+	ReadyAt        *int64
+	PickupDeadline *int64
+	ItemID         *uint
+	RequestID      *uint
+	CreatedAt      time.Time
+}
+
+func (v16Hold) TableName() string { return "holds" }
+
+// v16RequestEvent is a request set aside for a ready hold, which keeps the
+// hold's pickup deadline
+type v16RequestEvent struct {
+	ID             uint
+	BookID         string
+	LibraryID      uint
+	ReaderID       uint
+	RequestDate    int64
+	ApprovalDate   *int64
+	RequestType    string
+	ItemID         *uint
+	Status         string
+	PickupDeadline *int64 `gorm:"default:null"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (v16RequestEvent) TableName() string { return "request_events" }
+
+type v16RequestTransition struct {
+	ID         uint
+	RequestID  uint
+	FromStatus string
+	ToStatus   string
+	Reason     string
+	CreatedAt  time.Time
+}
+
+func (v16RequestTransition) TableName() string { return "request_transitions" }
+
+// Holds that are ready when this runs get their request ready for pickup, as
+// holds.Allocate gives the holds it readies from now on
+func init() {
+	register(Migration{
+		Version: 16,
+		Name:    "hold_requests",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&v16Hold{}, "RequestID"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().AddColumn(&v16RequestEvent{}, "PickupDeadline"); err != nil {
+				return err
+			}
+
+			var ready []v16Hold
+			if err := tx.Where("status = ? AND item_id IS NOT NULL", "ready").Order("id").Find(&ready).Error; err != nil {
+				return err
+			}
+			for _, hold := range ready {
+				request := v16RequestEvent{
+					BookID:         hold.ISBN,
+					LibraryID:      hold.LibraryID,
+					ReaderID:       hold.ReaderID,
+					RequestDate:    hold.CreatedAt.Unix(),
+					ApprovalDate:   hold.ReadyAt,
+					RequestType:    "issue",
+					ItemID:         hold.ItemID,
+					Status:         "ready_for_pickup",
+					PickupDeadline: hold.PickupDeadline,
+				}
+				if err := tx.Create(&request).Error; err != nil {
+					return err
+				}
+				if err := tx.Create(&v16RequestTransition{RequestID: request.ID, ToStatus: "ready_for_pickup", Reason: "Copy set aside for a hold"}).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&v16Hold{}).Where("id = ?", hold.ID).Update("request_id", request.ID).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		// Requests still waiting on a ready hold go with the link; the hold
+		// holds their copy again. Requests that ended are kept as history.
+		Down: func(tx *gorm.DB) error {
+			waiting := tx.Model(&v16Hold{}).Select("request_id").Where("status = ? AND request_id IS NOT NULL", "ready")
+			if err := tx.Where("request_id IN (?)", waiting).Delete(&v16RequestTransition{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id IN (?)", waiting).Delete(&v16RequestEvent{}).Error; err != nil {
+				return err
+			}
+			for _, drop := range []string{"ALTER TABLE holds DROP COLUMN request_id", "ALTER TABLE request_events DROP COLUMN pickup_deadline"} {
+				if err := tx.Exec(drop).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
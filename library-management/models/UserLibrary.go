@@ -1,6 +1,7 @@
 package models
 
 type UserLibrary struct {
-	UserID    uint `gorm:"primaryKey"`
-	LibraryID uint `gorm:"primaryKey"`
+	UserID    uint   `gorm:"primaryKey"`
+	LibraryID uint   `gorm:"primaryKey"`
+	Tier      string `gorm:"type:varchar(50);not null;default:''"` // Membership tier, selects tier overrides in the library policy
 }
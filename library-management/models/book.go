@@ -10,6 +10,7 @@ type Book struct {
 	Authors         string
 	Publisher       string
 	Version         string
+	Category        string `gorm:"type:varchar(100);not null;default:''"` // Selects category overrides in the library policy
 	TotalCopies     int
 	AvailableCopies int
 	LibraryID       uint `gorm:"index"`
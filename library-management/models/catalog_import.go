@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// CatalogImport is a bulk upload of books into a library. The uploaded file is
+// kept with the import so the background job can work through it, and a
+// restart can resume from the last processed row.
+type CatalogImport struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	LibraryID     uint      `gorm:"not null;index" json:"library_id"`
+	UploadedBy    *uint     `json:"uploaded_by"` // Nil when run from the command line
+	Format        string    `gorm:"type:varchar(10);not null" json:"format"`
+	Filename      string    `json:"filename"`
+	Payload       []byte    `gorm:"not null" json:"-"`
+	Status        string    `gorm:"type:varchar(20);not null;default:'queued';index" json:"status"`
+	TotalRows     int       `json:"total_rows"`
+	ProcessedRows int       `json:"processed_rows"`
+	ImportedRows  int       `json:"imported_rows"`
+	FailedRows    int       `json:"failed_rows"`
+	Error         string    `gorm:"type:text" json:"error,omitempty"` // Why the whole import failed
+	StartedAt     *int64    `gorm:"default:null" json:"started_at"`
+	FinishedAt    *int64    `gorm:"default:null" json:"finished_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Import formats
+const (
+	ImportCSV     = "csv"
+	ImportMARC    = "marc"    // MARC21 in ISO 2709 transmission format
+	ImportMARCXML = "marcxml" // MARC21 as MARCXML
+)
+
+// Import statuses
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportCompleted = "completed" // Every row was processed; some may have failed
+	ImportFailed    = "failed"    // The file could not be read
+)
@@ -0,0 +1,10 @@
+package models
+
+// CatalogImportError records why one row of a catalog import was skipped
+type CatalogImportError struct {
+	ID       uint   `gorm:"primaryKey" json:"-"`
+	ImportID uint   `gorm:"not null;index" json:"-"`
+	Row      int    `gorm:"not null" json:"row"` // Line of a CSV file or record number in a MARC file
+	ISBN     string `json:"isbn,omitempty"`
+	Message  string `gorm:"type:text;not null" json:"message"`
+}
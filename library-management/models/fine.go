@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// FineEntry is one line of a reader's fines ledger in a library. Charges add
+// to the balance; payments and waivers reduce it. Amounts are in cents and
+// always positive.
+type FineEntry struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ReaderID    uint      `gorm:"not null;index:idx_fine_entries_account" json:"reader_id"`
+	LibraryID   uint      `gorm:"not null;index:idx_fine_entries_account" json:"library_id"`
+	IssueID     *uint     `gorm:"index" json:"issue_id"` // Loan a charge was accrued on
+	Kind        string    `gorm:"type:varchar(20);not null" json:"kind"`
+	AmountCents int64     `gorm:"not null" json:"amount_cents"`
+	ActorID     *uint     `json:"actor_id"` // Admin who took a payment or granted a waiver
+	Reason      string    `gorm:"type:text" json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Fine entry kinds
+const (
+	FineCharge  = "charge"
+	FinePayment = "payment"
+	FineWaiver  = "waiver"
+)
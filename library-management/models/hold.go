@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Hold is a reader's place in the queue for a title with no free copies. When
+// a copy comes back it is set aside for the oldest waiting hold, which becomes
+// ready until its pickup deadline, with an issue request ready for pickup that
+// the desk checks the copy out through.
+type Hold struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ISBN           string    `gorm:"not null;index:idx_holds_queue" json:"isbn"`
+	LibraryID      uint      `gorm:"not null;index:idx_holds_queue" json:"library_id"`
+	ReaderID       uint      `gorm:"not null;index" json:"reader_id"`
+	Status         string    `gorm:"type:varchar(20);not null;default:'waiting';index:idx_holds_queue" json:"status"`
+	ReadyAt        *int64    `gorm:"default:null" json:"ready_at"`
+	PickupDeadline *int64    `gorm:"default:null" json:"pickup_deadline"`
+	ItemID         *uint     `json:"item_id"`    // Copy set aside once the hold is ready
+	RequestID      *uint     `json:"request_id"` // Request ready for pickup once the hold is ready
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Hold statuses
+const (
+	HoldWaiting   = "waiting"   // In the queue
+	HoldReady     = "ready"     // A copy is set aside for pickup
+	HoldFulfilled = "fulfilled" // The reader received the book
+	HoldCancelled = "cancelled"
+	HoldExpired   = "expired" // The pickup deadline passed
+)
+
+// OpenHoldStatuses are the statuses of holds still in the queue
+var OpenHoldStatuses = []string{HoldWaiting, HoldReady}
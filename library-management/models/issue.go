@@ -5,6 +5,8 @@ import "gorm.io/gorm"
 type IssueRegistry struct {
 	gorm.Model
 	ISBN               string `gorm:"not null" json:"isbn"`
+	ItemID             *uint  `gorm:"index" json:"item_id"` // Copy on loan; nil only for loans older than item tracking
+	LibraryID          uint   `gorm:"index" json:"library_id"`
 	ReaderID           uint   `gorm:"not null" json:"reader_id"`
 	IssueApproverID    uint   `gorm:"not null" json:"issue_approver_id"`
 	IssueStatus        string `gorm:"type:varchar(50);not null" json:"issue_status"`
@@ -12,4 +14,16 @@ type IssueRegistry struct {
 	ExpectedReturnDate int64  `gorm:"not null" json:"expected_return_date"`
 	ReturnDate         int64  `gorm:"default:0" json:"return_date"`
 	ReturnApproverID   uint   `gorm:"default:0" json:"return_approver_id"`
+	RenewalCount       int    `gorm:"not null;default:0" json:"renewal_count"`
+	NeedsRepair        bool   `gorm:"not null;default:false" json:"needs_repair"` // Library unknown for a loan older than per-library loans; cleared by loans.Repair
 }
+
+// Loan statuses
+const (
+	LoanIssued   = "issued"
+	LoanOverdue  = "overdue" // Set by the overdue job once the due date has passed
+	LoanReturned = "returned"
+)
+
+// ActiveLoanStatuses are the statuses of loans whose book is still out
+var ActiveLoanStatuses = []string{LoanIssued, LoanOverdue}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Item is one physical copy of a book in a library, identified by the barcode
+// on its label. The book's copy counters are derived from its items.
+type Item struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Barcode    string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"barcode"`
+	BookID     uint      `gorm:"not null;index" json:"book_id"`
+	LibraryID  uint      `gorm:"not null;index" json:"library_id"`
+	Condition  string    `gorm:"type:varchar(20);not null;default:'good'" json:"condition"`
+	Status     string    `gorm:"type:varchar(20);not null;default:'available'" json:"status"`
+	AcquiredAt int64     `gorm:"not null" json:"acquired_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Item statuses
+const (
+	ItemAvailable = "available" // On the shelf
+	ItemReserved  = "reserved"  // Set aside for a hold or an approved request
+	ItemOnLoan    = "on_loan"
+	ItemWithdrawn = "withdrawn" // Removed from the collection
+)
+
+// Item conditions
+var ItemConditions = []string{"new", "good", "fair", "poor", "damaged"}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// LibraryPolicy is one rule of a library's circulation policy. The rule with
+// an empty category and tier is the library's base policy; others override it
+// for books of one category, readers of one membership tier, or both. Nil
+// fields inherit from the less specific rule and finally from the server
+// configuration.
+type LibraryPolicy struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	LibraryID      uint      `gorm:"not null;uniqueIndex:idx_library_policies_scope" json:"library_id"`
+	Category       string    `gorm:"type:varchar(100);not null;default:'';uniqueIndex:idx_library_policies_scope" json:"category"`
+	Tier           string    `gorm:"type:varchar(50);not null;default:'';uniqueIndex:idx_library_policies_scope" json:"tier"`
+	LoanPeriodDays *int      `json:"loan_period_days"`
+	MaxLoans       *int      `json:"max_loans"` // Books a reader may have out at once, 0 for no limit
+	MaxRenewals    *int      `json:"max_renewals"`
+	FinePerDay     *int64    `json:"fine_per_day"`     // Cents
+	MaxFine        *int64    `json:"max_fine"`         // Cents per loan, 0 for no cap
+	MaxUnpaidFines *int64    `json:"max_unpaid_fines"` // Cents a reader may owe and still borrow
+	HoldPickupDays *int      `json:"hold_pickup_days"`
+	UpdatedByID    *uint     `json:"updated_by_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LoanRenewal records one extension of a loan's due date
+type LoanRenewal struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	IssueID         uint      `gorm:"not null;index" json:"issue_id"`
+	RenewedByID     uint      `gorm:"not null" json:"renewed_by_id"` // Reader or admin who renewed
+	PreviousDueDate int64     `gorm:"not null" json:"previous_due_date"`
+	NewDueDate      int64     `gorm:"not null" json:"new_due_date"`
+	CreatedAt       time.Time `json:"created_at"`
+}
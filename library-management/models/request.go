@@ -12,4 +12,40 @@ type RequestEvent struct {
 	ApprovalDate *int64 `gorm:"default:null"` // Default -1 (Not yet approved)
 	ApproverID   *uint  `gorm:"default:null"` // Default 0 (Not yet approved)
 	RequestType  string `gorm:"type:varchar(50);not null;check:request_type IN ('issue', 'return')"`
+	IssueID      *uint  `gorm:"default:null" json:"issue_id"` // Loan being returned, or the loan an issue request was fulfilled with
+	ItemID       *uint  `gorm:"default:null" json:"item_id"`  // Copy set aside for an issue request ready for pickup
+	Status       string `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+
+	// A request set aside for a ready hold keeps the hold's pickup deadline;
+	// other requests expire a fixed time after approval
+	PickupDeadline *int64 `gorm:"default:null" json:"pickup_deadline,omitempty"`
+
+	RejectionReason string `gorm:"type:text" json:"rejection_reason,omitempty"`
+	RejectedByID    *uint  `gorm:"default:null" json:"rejected_by_id,omitempty"` // Admin who disapproved the request
+	RejectedAt      *int64 `gorm:"default:null" json:"rejected_at,omitempty"`
+
+	ExpiryReason string `gorm:"type:text" json:"expiry_reason,omitempty"`
+	ExpiredAt    *int64 `gorm:"default:null" json:"expired_at,omitempty"`
 }
+
+// Request lifecycle statuses. A request starts pending and is approved,
+// rejected, cancelled or expired. An approved issue request has a copy set
+// aside and is ready for pickup until the reader collects it at the desk,
+// which fulfils it, or it expires. A request set aside for a ready hold starts
+// ready for pickup and is also cancelled when the reader cancels the hold. An
+// approved return is fulfilled at once.
+const (
+	RequestPending        = "pending"
+	RequestApproved       = "approved"
+	RequestReadyForPickup = "ready_for_pickup"
+	RequestRejected       = "rejected"
+	RequestCancelled      = "cancelled"
+	RequestExpired        = "expired"
+	RequestFulfilled      = "fulfilled"
+)
+
+// OpenRequestStatuses are the statuses of requests still waiting on the library
+var OpenRequestStatuses = []string{RequestPending, RequestApproved, RequestReadyForPickup}
+
+// RequestStatuses lists every valid request status
+var RequestStatuses = []string{RequestPending, RequestApproved, RequestReadyForPickup, RequestRejected, RequestCancelled, RequestExpired, RequestFulfilled}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// RequestTransition records one status change of a RequestEvent
+type RequestTransition struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	RequestID  uint      `gorm:"not null;index" json:"request_id"`
+	FromStatus string    `gorm:"type:varchar(20)" json:"from_status"` // Empty when the request was created
+	ToStatus   string    `gorm:"type:varchar(20);not null" json:"to_status"`
+	ActorID    *uint     `json:"actor_id"` // User who made the change; nil for system jobs
+	Reason     string    `gorm:"type:text" json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
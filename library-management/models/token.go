@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// RefreshToken is one link in a rotating chain of refresh tokens. Every token
+// issued from the same login shares a FamilyID; presenting a token that was
+// already used revokes the whole family.
+type RefreshToken struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          uint       `gorm:"not null;index" json:"user_id"`
+	FamilyID        string     `gorm:"type:varchar(64);not null;index" json:"family_id"`
+	TokenHash       string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	AccessJTI       string     `gorm:"type:varchar(64)" json:"-"` // Access token issued alongside this refresh token
+	AccessExpiresAt time.Time  `json:"-"`
+	ExpiresAt       time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt          *time.Time `json:"used_at"`
+	RevokedAt       *time.Time `json:"revoked_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// RevokedToken blocks an access token by its jti until the token expires
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;type:varchar(64)"`
+	UserID    uint      `gorm:"index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
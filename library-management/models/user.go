@@ -9,6 +9,13 @@ type User struct {
 	Email    string `gorm:"unique;not null"`
 	Contact  string
 	Role     string    `gorm:"type:varchar(50);check:role IN ('owner', 'admin', 'user')"`
-	Password string    `gorm:"not null"`
+	Password string    `gorm:"not null" json:"-"`
+	Status   string    `gorm:"type:varchar(20);not null;default:'active'"` // Suspended readers cannot borrow
 	Library  []Library `gorm:"many2many:UserLibrary;"`
 }
+
+// Account statuses
+const (
+	AccountActive    = "active"
+	AccountSuspended = "suspended"
+)
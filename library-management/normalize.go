@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"library-management/config"
+	"library-management/services/inventory"
+)
+
+// runNormalizeISBNs implements `normalize-isbns [-dry-run]`
+func runNormalizeISBNs(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("normalize-isbns", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would change without saving it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := config.ConnectDatabase(cfg)
+	if err != nil {
+		return err
+	}
+
+	report, err := inventory.NormalizeISBNs(db, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		fmt.Println("Dry run, nothing was saved")
+	}
+	fmt.Printf("Books rewritten:    %d\n", report.Books)
+	fmt.Printf("Books merged:       %d\n", report.Merged)
+	fmt.Printf("Requests rewritten: %d\n", report.Requests)
+	fmt.Printf("Loans rewritten:    %d\n", report.Loans)
+	fmt.Printf("Holds rewritten:    %d\n", report.Holds)
+	for _, raw := range report.Invalid {
+		fmt.Printf("⚠️ Left unchanged, not a valid ISBN: %q\n", raw)
+	}
+	return nil
+}
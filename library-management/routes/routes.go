@@ -15,6 +15,8 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 	auth := r.Group("/auth")
 	{
 		auth.POST("/login", controllers.Login(db))
+		auth.POST("/refresh", controllers.RefreshToken(db))
+		auth.POST("/logout", middleware.AuthMiddleware(db, ""), controllers.Logout(db))
 	}
 
 	// Protected API routes (Require authentication)
@@ -26,7 +28,7 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 		})
 
 		// Owner-Only Routes
-		ownerRoutes := api.Group("", middleware.AuthMiddleware("owner"))
+		ownerRoutes := api.Group("", middleware.AuthMiddleware(db, "owner"))
 		{
 			ownerRoutes.POST("/library", controllers.CreateLibrary(db))  // Owner can create a library
 			ownerRoutes.POST("/admin", controllers.RegisterAdmin(db))    // Owner can create Admins
@@ -34,32 +36,96 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 		}
 
 		// Admin-Only Routes
-		adminRoutes := api.Group("", middleware.AuthMiddleware("admin"))
+		adminRoutes := api.Group("", middleware.AuthMiddleware(db, "admin"))
 		{
+			// Library scoping: from the request body, or from the library the request was made in
+			bookScope := middleware.RequireLibraryRole("admin", middleware.LibraryFromBody("libraryid"))
+			requestScope := middleware.RequireLibraryRole("admin", middleware.LibraryFromRequestEvent(db, "id"))
+			loanScope := middleware.RequireLibraryRole("admin", middleware.LibraryFromLoan(db, "id"))
+
 			adminRoutes.POST("/user", controllers.RegisterUser(db))
+			adminRoutes.PUT("/users/:id/status", controllers.SetAccountStatus(db)) // Admin can suspend or reactivate a reader
 
 			// Book Management
-			adminRoutes.POST("/book", controllers.AddBook(db))            // Admin can add books
-			adminRoutes.PUT("/book/:isbn", controllers.UpdateBook(db))    // Admin can update book details (copies, title, etc.)
-			adminRoutes.DELETE("/book/:isbn", controllers.RemoveBook(db)) // Admin can remove books
+			adminRoutes.POST("/book", bookScope, controllers.AddBook(db))                                                                                          // Admin can add books
+			adminRoutes.PUT("/book/:isbn", bookScope, controllers.UpdateBook(db))                                                                                  // Admin can update book details (copies, title, etc.)
+			adminRoutes.DELETE("/book/:isbn", bookScope, controllers.RemoveBook(db))                                                                               // Admin can remove books
+			adminRoutes.GET("/book/:isbn/items", middleware.RequireLibraryRole("admin", middleware.LibraryFromQuery("library_id")), controllers.ListBookItems(db)) // Admin can see every copy and its barcode
+
+			// Catalog Import
+			adminRoutes.POST("/imports", middleware.RequireLibraryRole("admin", middleware.LibraryFromQuery("library_id")), controllers.ImportCatalog(db)) // Admin can upload a CSV or MARC file of books
+			adminRoutes.GET("/imports", middleware.RequireLibraryRole("admin", middleware.LibraryFromQuery("library_id")), controllers.ListImports(db))    // Admin can list the library's imports
+			adminRoutes.GET("/imports/:id", middleware.RequireLibraryRole("admin", middleware.LibraryFromImport(db, "id")), controllers.GetImport(db))     // Admin can follow an import's progress and row errors
 
 			// Issue Request Management
-			adminRoutes.GET("/issues", controllers.ListIssueRequests(db))             // Admin can list issue requests
-			adminRoutes.PUT("/issue/approve/:id", controllers.ApproveIssue(db))       // Admin can approve issue requests
-			adminRoutes.PUT("/issue/disapprove/:id", controllers.DisapproveIssue(db)) // Admin can disapprove issue requests
+			adminRoutes.GET("/issues", controllers.ListIssueRequests(db))                           // Admin can list issue requests
+			adminRoutes.PUT("/issue/approve/:id", requestScope, controllers.ApproveIssue(db))       // Admin can approve issue requests
+			adminRoutes.PUT("/issue/disapprove/:id", requestScope, controllers.DisapproveIssue(db)) // Admin can disapprove issue requests
+			adminRoutes.PUT("/issue/checkout/:id", requestScope, controllers.CheckoutIssue(db))     // Admin hands an approved book to the reader at the desk
 
 			// Issue Books to Users
-			adminRoutes.POST("/issue/book/:isbn", controllers.IssueBookToUser(db)) // Admin can issue books to a reader
+			adminRoutes.POST("/issue/book/:isbn", middleware.RequireLibraryRole("admin", middleware.LibraryFromBody("library_id")), controllers.IssueBookToUser(db)) // Admin can issue books to a reader
+
+			// Return Management
+			adminRoutes.PUT("/return/approve/:id", requestScope, controllers.ApproveReturn(db)) // Admin can approve return requests
+
+			// Loan Renewals
+			adminRoutes.POST("/loans/:id/renew", loanScope, controllers.RenewLoan(db))          // Admin can renew a loan directly
+			adminRoutes.GET("/loans/:id/renewals", loanScope, controllers.ListLoanRenewals(db)) // Admin can see how often a loan was renewed
+
+			// Holds Queue
+			adminRoutes.GET("/holds", middleware.RequireLibraryRole("admin", middleware.LibraryFromQuery("library_id")), controllers.ListHolds(db)) // Admin can see who is waiting for which book
+
+			// Fines
+			fineScope := middleware.RequireLibraryRole("admin", middleware.LibraryFromBody("library_id"))
+			adminRoutes.GET("/fines", middleware.RequireLibraryRole("admin", middleware.LibraryFromQuery("library_id")), controllers.ListFines(db)) // Admin can see who owes the library
+			adminRoutes.POST("/fines/payments", fineScope, controllers.RecordFinePayment(db))                                                       // Admin can record a payment
+			adminRoutes.POST("/fines/waivers", fineScope, controllers.WaiveFine(db))                                                                // Admin can waive a fine with a reason
+
+			// Reports
+			adminRoutes.GET("/reports/pickups", middleware.RequireLibraryRole("admin", middleware.LibraryFromQuery("library_id")), controllers.PickupReport(db)) // Admin can see how many approved books were never collected
+		}
+
+		// Library Policy (Owners and the library's admins)
+		policyRoutes := api.Group("/libraries/:id", middleware.AuthMiddleware(db, "admin|owner"),
+			middleware.RequireLibraryRole("admin|owner", middleware.LibraryFromParam("id")))
+		{
+			policyRoutes.GET("/policies", controllers.GetLibraryPolicy(db))                // See the policy rules and the policy they produce
+			policyRoutes.PUT("/policies", controllers.SaveLibraryPolicy(db))               // Create or replace a base, category or tier rule
+			policyRoutes.DELETE("/policies/:rule_id", controllers.DeleteLibraryPolicy(db)) // Remove a rule
+			policyRoutes.PUT("/members/:user_id/tier", controllers.SetMemberTier(db))      // Set a reader's membership tier
+			policyRoutes.GET("/catalog/export", controllers.ExportCatalog(db))             // Download every book with copy counts as CSV, JSON Lines or MARCXML
+		}
+
+		// Loan Repair (Owners and admins): loans from before per-library loans with no library
+		repairRoutes := api.Group("/loans", middleware.AuthMiddleware(db, "admin|owner"))
+		{
+			repairRoutes.GET("/repairs", controllers.ListLoansNeedingRepair(db))                                                                                // See loans readers cannot return until a library is assigned
+			repairRoutes.PUT("/:id/repair", middleware.RequireLibraryRole("admin|owner", middleware.LibraryFromBody("library_id")), controllers.RepairLoan(db)) // Assign a loan its library and copy
 		}
 
 		// User-Only Routes
-		userRoutes := api.Group("", middleware.AuthMiddleware("user"))
+		userRoutes := api.Group("", middleware.AuthMiddleware(db, "user"))
 		{
 			// Book Search
-			userRoutes.GET("/books/search", controllers.SearchBooks(db)) // Users can search books by title, author, publisher
+			userRoutes.GET("/books/search", controllers.SearchBooks(db))                                                                                                    // Users can search books by relevance or by title, author, publisher, category
+			userRoutes.GET("/books/:isbn/availability", middleware.RequireLibraryRole("user", middleware.LibraryFromQuery("library_id")), controllers.BookAvailability(db)) // Users can see when a copy should reach them
 
 			// Request a Book
-			userRoutes.POST("/issue", controllers.RequestIssue(db)) // Users can request book issues
+			userRoutes.POST("/issue", middleware.RequireLibraryRole("user", middleware.LibraryFromBody("libraryid")), controllers.RequestIssue(db)) // Users can request book issues
+
+			// Return a Book
+			userRoutes.POST("/return", controllers.RequestReturn(db)) // Users can request to return an issued book
+
+			// Self-Service
+			userRoutes.GET("/me/requests", controllers.ListMyRequests(db))                                                                                     // Users can list their own requests
+			userRoutes.DELETE("/me/requests/:id", controllers.CancelMyRequest(db))                                                                             // Users can cancel their pending requests
+			userRoutes.GET("/me/loans", controllers.ListMyLoans(db))                                                                                           // Users can list the books they hold
+			userRoutes.POST("/me/loans/:id/renew", controllers.RenewMyLoan(db))                                                                                // Users can renew their own loans
+			userRoutes.GET("/me/holds", controllers.ListMyHolds(db))                                                                                           // Users can see their place in holds queues
+			userRoutes.DELETE("/me/holds/:id", controllers.CancelMyHold(db))                                                                                   // Users can leave a holds queue
+			userRoutes.GET("/me/fines", controllers.ListMyFines(db))                                                                                           // Users can see what they owe
+			userRoutes.GET("/me/eligibility", middleware.RequireLibraryRole("user", middleware.LibraryFromQuery("library_id")), controllers.MyEligibility(db)) // Users can see whether they may borrow
 		}
 	}
 
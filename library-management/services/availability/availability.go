@@ -0,0 +1,244 @@
+// Package availability estimates when a reader can expect a copy of a title.
+// Each copy is followed from the moment it is next free: copies on the shelf
+// are free now, copies on loan when they are due back, and every copy then
+// goes to the next reader in the holds queue for one loan period.
+//
+// Renewals already granted are part of the due dates. Further renewals are
+// refused while anyone is waiting, so once a reader joins the queue those due
+// dates are final.
+package availability
+
+import (
+	"container/heap"
+	"errors"
+	"library-management/config"
+	"library-management/models"
+	"library-management/services/inventory"
+	"library-management/services/policies"
+	"library-management/services/requests"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Forecast statuses
+const (
+	Available = "available" // A copy can be requested now
+	Ready     = "ready"     // A copy is set aside for the reader
+	Waiting   = "waiting"   // The reader has to wait for a copy
+)
+
+// Forecast describes a title's availability for one reader
+type Forecast struct {
+	ISBN            string     `json:"isbn"`
+	LibraryID       uint       `json:"library_id"`
+	TotalCopies     int        `json:"total_copies"`
+	AvailableCopies int        `json:"available_copies"`
+	OnLoan          int        `json:"on_loan"`
+	QueueLength     int        `json:"queue_length"` // Readers waiting, not counting ready holds
+	Status          string     `json:"status"`
+	Position        int        `json:"position,omitempty"` // The reader's place in the queue, or the place they would take
+	EstimatedDate   *time.Time `json:"estimated_date"`     // When a copy should reach the reader
+	PickupDeadline  *time.Time `json:"pickup_deadline,omitempty"`
+}
+
+// For forecasts a title's availability in a library for a reader
+func For(db *gorm.DB, isbn string, libraryID, readerID uint, now time.Time) (Forecast, error) {
+	var book models.Book
+	if err := db.Where("isbn = ? AND library_id = ?", isbn, libraryID).First(&book).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Forecast{}, inventory.ErrBookNotFound
+		}
+		return Forecast{}, err
+	}
+
+	forecasts, err := ForBooks(db, []models.Book{book}, readerID, now)
+	if err != nil {
+		return Forecast{}, err
+	}
+	return forecasts[book.ID], nil
+}
+
+// title identifies a book's queue: its ISBN in one library
+type title struct {
+	isbn      string
+	libraryID uint
+}
+
+// ForBooks forecasts the availability of several books for a reader, keyed by
+// book ID. It reads the holds, pickups, loans and policies of all of them at
+// once, so a page of search results costs the same few queries as one book.
+func ForBooks(db *gorm.DB, books []models.Book, readerID uint, now time.Time) (map[uint]Forecast, error) {
+	forecasts := make(map[uint]Forecast, len(books))
+	if len(books) == 0 {
+		return forecasts, nil
+	}
+	var isbns []string
+	var libraryIDs []uint
+	for _, book := range books {
+		isbns = append(isbns, book.ISBN)
+		libraryIDs = append(libraryIDs, book.LibraryID)
+	}
+
+	var holds []models.Hold
+	if err := db.Where("isbn IN ? AND library_id IN ? AND status IN ?", isbns, libraryIDs, models.OpenHoldStatuses).
+		Order("id").Find(&holds).Error; err != nil {
+		return nil, err
+	}
+	queues := make(map[title][]models.Hold)
+	for _, hold := range holds {
+		key := title{hold.ISBN, hold.LibraryID}
+		queues[key] = append(queues[key], hold)
+	}
+
+	// Approved requests have a copy set aside until the reader collects it
+	var ready []models.RequestEvent
+	if err := db.Where("book_id IN ? AND library_id IN ? AND status = ?", isbns, libraryIDs, models.RequestReadyForPickup).
+		Find(&ready).Error; err != nil {
+		return nil, err
+	}
+	pickups := make(map[title][]models.RequestEvent)
+	for _, request := range ready {
+		key := title{request.BookID, request.LibraryID}
+		pickups[key] = append(pickups[key], request)
+	}
+
+	var loans []models.IssueRegistry
+	if err := db.Select("isbn, library_id, expected_return_date").
+		Where("isbn IN ? AND library_id IN ? AND issue_status IN ?", isbns, libraryIDs, models.ActiveLoanStatuses).
+		Order("expected_return_date").Find(&loans).Error; err != nil {
+		return nil, err
+	}
+	dueDates := make(map[title][]int64)
+	for _, loan := range loans {
+		key := title{loan.ISBN, loan.LibraryID}
+		dueDates[key] = append(dueDates[key], loan.ExpectedReturnDate)
+	}
+
+	var rules []models.LibraryPolicy
+	if err := db.Where("library_id IN ?", libraryIDs).Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	libraryRules := make(map[uint][]models.LibraryPolicy)
+	for _, rule := range rules {
+		libraryRules[rule.LibraryID] = append(libraryRules[rule.LibraryID], rule)
+	}
+
+	for _, book := range books {
+		key := title{book.ISBN, book.LibraryID}
+		policy := policies.Match(libraryRules[book.LibraryID], book.Category, "")
+		forecasts[book.ID] = forecast(book, queues[key], pickups[key], dueDates[key], policy, readerID, now)
+	}
+	return forecasts, nil
+}
+
+// forecast works out one book's availability from its open holds, requests
+// ready for pickup and the due dates of its active loans
+func forecast(book models.Book, queue []models.Hold, pickups []models.RequestEvent, dueDates []int64, policy policies.Policy, readerID uint, now time.Time) Forecast {
+	forecast := Forecast{
+		ISBN:            book.ISBN,
+		LibraryID:       book.LibraryID,
+		TotalCopies:     book.TotalCopies,
+		AvailableCopies: book.AvailableCopies,
+	}
+
+	var waiting []models.Hold
+	ready := 0
+	for _, hold := range queue {
+		if hold.Status == models.HoldReady {
+			if hold.ReaderID == readerID {
+				forecast.Status = Ready
+				forecast.EstimatedDate = &now
+				deadline := time.Unix(*hold.PickupDeadline, 0)
+				forecast.PickupDeadline = &deadline
+			}
+			// The request linked to the hold is counted with the pickups
+			if hold.RequestID == nil {
+				ready++
+			}
+			continue
+		}
+		waiting = append(waiting, hold)
+	}
+	forecast.QueueLength = len(waiting)
+
+	for _, request := range pickups {
+		if request.ReaderID == readerID {
+			forecast.Status = Ready
+			forecast.EstimatedDate = &now
+			if deadline := requests.Deadline(request, config.AppConfig.RequestExpiry, config.AppConfig.PickupExpiry); deadline != nil {
+				pickupDeadline := time.Unix(*deadline, 0)
+				forecast.PickupDeadline = &pickupDeadline
+			}
+		}
+		ready++
+	}
+	forecast.OnLoan = len(dueDates)
+
+	if forecast.Status == Ready {
+		return forecast
+	}
+	if book.AvailableCopies > 0 && len(waiting) == 0 {
+		forecast.Status = Available
+		forecast.EstimatedDate = &now
+		return forecast
+	}
+
+	forecast.Status = Waiting
+	forecast.Position = len(waiting) + 1
+	for i, hold := range waiting {
+		if hold.ReaderID == readerID {
+			forecast.Position = i + 1
+			break
+		}
+	}
+
+	// When each copy is next free. Copies set aside for ready holds and
+	// requests go out on loan when collected.
+	loanPeriod := time.Duration(policy.LoanPeriodDays) * 24 * time.Hour
+	copies := &freeTimes{}
+	for i := 0; i < book.AvailableCopies; i++ {
+		copies.Push(now)
+	}
+	for _, due := range dueDates {
+		copies.Push(latest(time.Unix(due, 0), now))
+	}
+	for i := 0; i < ready; i++ {
+		copies.Push(now.Add(loanPeriod))
+	}
+	if copies.Len() == 0 {
+		return forecast // No copies left in circulation
+	}
+	heap.Init(copies)
+
+	// Hand copies to the queue in order until the reader's turn comes
+	for turn := 1; ; turn++ {
+		free := heap.Pop(copies).(time.Time)
+		if turn == forecast.Position {
+			forecast.EstimatedDate = &free
+			return forecast
+		}
+		heap.Push(copies, free.Add(loanPeriod))
+	}
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// freeTimes is a min-heap of the times copies become free
+type freeTimes []time.Time
+
+func (h freeTimes) Len() int            { return len(h) }
+func (h freeTimes) Less(i, j int) bool  { return h[i].Before(h[j]) }
+func (h freeTimes) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *freeTimes) Push(x interface{}) { *h = append(*h, x.(time.Time)) }
+func (h *freeTimes) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
@@ -0,0 +1,243 @@
+// Package catalog writes a library's holdings out for union catalogs and
+// offline audits. Books are read from a database cursor and written as they
+// arrive, so an export never holds the whole catalog in memory.
+package catalog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"library-management/models"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Export formats
+const (
+	CSV       = "csv"
+	JSONLines = "jsonl"
+	MARCXML   = "marcxml"
+)
+
+// flushEvery is how many books are written between flushes to the client
+const flushEvery = 100
+
+// Formats lists the export formats
+var Formats = []string{CSV, JSONLines, MARCXML}
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Entry is one book in an export with its copy counts
+type Entry struct {
+	ISBN            string `json:"isbn"`
+	Title           string `json:"title"`
+	Authors         string `json:"authors"`
+	Publisher       string `json:"publisher"`
+	Version         string `json:"version"`
+	Category        string `json:"category"`
+	TotalCopies     int    `json:"total_copies"`
+	AvailableCopies int    `json:"available_copies"`
+	OnLoan          int    `json:"on_loan"` // Copies out on active loans
+}
+
+// ContentType returns the media type and file extension of an export format
+func ContentType(format string) (string, string, error) {
+	switch format {
+	case CSV:
+		return "text/csv; charset=utf-8", "csv", nil
+	case JSONLines:
+		return "application/x-ndjson", "jsonl", nil
+	case MARCXML:
+		return "application/marcxml+xml", "xml", nil
+	}
+	return "", "", ErrUnknownFormat
+}
+
+// Export writes every book a library holds to w in the given format, in the
+// order the books were added. flush, when not nil, is called every
+// flushEvery books so the client sees a long export progress.
+func Export(db *gorm.DB, libraryID uint, format string, w io.Writer, flush func()) error {
+	var writer entryWriter
+	switch format {
+	case CSV:
+		writer = newCSVWriter(w)
+	case JSONLines:
+		writer = &jsonLinesWriter{encoder: json.NewEncoder(w)}
+	case MARCXML:
+		writer = &marcXMLWriter{encoder: xml.NewEncoder(w)}
+	default:
+		return ErrUnknownFormat
+	}
+
+	// Active loans are counted per title in one pass rather than per book
+	onLoan := db.Table("issue_registries").
+		Select("isbn, COUNT(*) AS on_loan").
+		Where("library_id = ? AND issue_status IN ? AND deleted_at IS NULL", libraryID, models.ActiveLoanStatuses).
+		Group("isbn")
+	rows, err := db.Table("books").
+		Select("books.isbn, books.title, books.authors, books.publisher, books.version, books.category, "+
+			"books.total_copies, books.available_copies, COALESCE(loans.on_loan, 0) AS on_loan").
+		Joins("LEFT JOIN (?) AS loans ON loans.isbn = books.isbn", onLoan).
+		Where("books.library_id = ? AND books.deleted_at IS NULL", libraryID).
+		Order("books.id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if err := writer.begin(); err != nil {
+		return err
+	}
+	for n := 1; rows.Next(); n++ {
+		var entry Entry
+		if err := db.ScanRows(rows, &entry); err != nil {
+			return err
+		}
+		if err := writer.write(entry); err != nil {
+			return err
+		}
+		if n%flushEvery == 0 {
+			if err := writer.flush(); err != nil {
+				return err
+			}
+			if flush != nil {
+				flush()
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := writer.end(); err != nil {
+		return err
+	}
+	if flush != nil {
+		flush()
+	}
+	return nil
+}
+
+// entryWriter writes entries in one format
+type entryWriter interface {
+	begin() error
+	write(Entry) error
+	flush() error
+	end() error
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{writer: csv.NewWriter(w)}
+}
+
+// The header uses the column names the catalog import accepts, so importing an
+// export adds each book's total copies; the import ignores the available_copies
+// and on_loan columns.
+func (c *csvWriter) begin() error {
+	return c.writer.Write([]string{"isbn", "title", "authors", "publisher", "version", "category",
+		"copies", "available_copies", "on_loan"})
+}
+
+func (c *csvWriter) write(e Entry) error {
+	return c.writer.Write([]string{e.ISBN, e.Title, e.Authors, e.Publisher, e.Version, e.Category,
+		strconv.Itoa(e.TotalCopies), strconv.Itoa(e.AvailableCopies), strconv.Itoa(e.OnLoan)})
+}
+
+func (c *csvWriter) flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvWriter) end() error {
+	return c.flush()
+}
+
+type jsonLinesWriter struct {
+	encoder *json.Encoder
+}
+
+func (j *jsonLinesWriter) begin() error        { return nil }
+func (j *jsonLinesWriter) write(e Entry) error { return j.encoder.Encode(e) }
+func (j *jsonLinesWriter) flush() error        { return nil }
+func (j *jsonLinesWriter) end() error          { return nil }
+
+// marcXMLWriter writes MARC21 slim records. Copy counts go in the local 999
+// field: $t total, $a available and $l on loan.
+type marcXMLWriter struct {
+	encoder *xml.Encoder
+}
+
+var collection = xml.StartElement{Name: xml.Name{Local: "collection"},
+	Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: "http://www.loc.gov/MARC21/slim"}}}
+
+type marcXMLRecord struct {
+	XMLName    xml.Name           `xml:"record"`
+	Leader     string             `xml:"leader"`
+	DataFields []marcXMLDataField `xml:"datafield"`
+}
+
+type marcXMLDataField struct {
+	Tag       string            `xml:"tag,attr"`
+	Ind1      string            `xml:"ind1,attr"`
+	Ind2      string            `xml:"ind2,attr"`
+	Subfields []marcXMLSubfield `xml:"subfield"`
+}
+
+type marcXMLSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (m *marcXMLWriter) begin() error {
+	if err := m.encoder.EncodeToken(xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8"`)}); err != nil {
+		return err
+	}
+	return m.encoder.EncodeToken(collection)
+}
+
+func (m *marcXMLWriter) write(e Entry) error {
+	record := marcXMLRecord{Leader: "     nam a22     2i 4500"}
+	add := func(tag string, subfields ...string) {
+		field := marcXMLDataField{Tag: tag, Ind1: " ", Ind2: " "}
+		for i := 0; i+1 < len(subfields); i += 2 {
+			if subfields[i+1] != "" {
+				field.Subfields = append(field.Subfields, marcXMLSubfield{Code: subfields[i], Value: subfields[i+1]})
+			}
+		}
+		if len(field.Subfields) > 0 {
+			record.DataFields = append(record.DataFields, field)
+		}
+	}
+
+	authors := strings.Split(e.Authors, ";")
+	add("020", "a", e.ISBN)
+	add("100", "a", strings.TrimSpace(authors[0]))
+	add("245", "a", e.Title)
+	add("250", "a", e.Version)
+	add("264", "b", e.Publisher)
+	add("650", "a", e.Category)
+	for _, author := range authors[1:] {
+		add("700", "a", strings.TrimSpace(author))
+	}
+	add("999", "t", strconv.Itoa(e.TotalCopies), "a", strconv.Itoa(e.AvailableCopies), "l", strconv.Itoa(e.OnLoan))
+	return m.encoder.Encode(record)
+}
+
+func (m *marcXMLWriter) flush() error {
+	return m.encoder.Flush()
+}
+
+func (m *marcXMLWriter) end() error {
+	if err := m.encoder.EncodeToken(collection.End()); err != nil {
+		return err
+	}
+	return m.encoder.Flush()
+}
@@ -0,0 +1,149 @@
+// Package eligibility decides whether a reader may borrow from a library. It
+// checks every rule rather than stopping at the first failure so the reader
+// can be told everything that stands in their way.
+package eligibility
+
+import (
+	"errors"
+	"fmt"
+	"library-management/models"
+	"library-management/services/fines"
+	"library-management/services/policies"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrReaderNotFound = errors.New("reader not found")
+
+// Reason codes
+const (
+	AccountSuspended = "account_suspended"
+	LoanLimit        = "loan_limit"
+	OverdueLoans     = "overdue_loans"
+	UnpaidFines      = "unpaid_fines"
+)
+
+// Reason is one thing that stops a reader from borrowing
+type Reason struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// IneligibleError is returned when a reader may not borrow. It lists every
+// reason.
+type IneligibleError struct {
+	Reasons []Reason
+}
+
+func (e *IneligibleError) Error() string {
+	return fmt.Sprintf("reader is not eligible to borrow (%d reason(s))", len(e.Reasons))
+}
+
+// Reasons returns everything that stops a reader from borrowing another book
+// from a library under the given policy. An empty list means they may borrow.
+// Copies set aside for the reader count towards the loan limit like loans do.
+func Reasons(db *gorm.DB, readerID, libraryID uint, policy policies.Policy, now time.Time) ([]Reason, error) {
+	return reasons(db, readerID, libraryID, policy, now, false)
+}
+
+func reasons(db *gorm.DB, readerID, libraryID uint, policy policies.Policy, now time.Time, handover bool) ([]Reason, error) {
+	var reader models.User
+	if err := db.Select("id, status").First(&reader, readerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReaderNotFound
+		}
+		return nil, err
+	}
+
+	reasons := []Reason{}
+	if reader.Status == models.AccountSuspended {
+		reasons = append(reasons, Reason{AccountSuspended, "Reader account is suspended"})
+	}
+
+	var loans []models.IssueRegistry
+	if err := db.Select("id, issue_status, expected_return_date").
+		Where("reader_id = ? AND library_id = ? AND issue_status IN ?", readerID, libraryID, models.ActiveLoanStatuses).
+		Find(&loans).Error; err != nil {
+		return nil, err
+	}
+
+	if policy.MaxLoans > 0 {
+		reserved, err := reservedCopies(db, readerID, libraryID)
+		if err != nil {
+			return nil, err
+		}
+		// A handover turns one of the reserved copies into a loan
+		held := len(loans) + int(reserved)
+		if handover {
+			held--
+		}
+		if held >= policy.MaxLoans {
+			reasons = append(reasons, Reason{LoanLimit,
+				fmt.Sprintf("Reader already has %d of %d books allowed on loan or set aside", held, policy.MaxLoans)})
+		}
+	}
+
+	// The overdue job may not have run yet, so the due date is checked too
+	overdue := 0
+	for _, loan := range loans {
+		if loan.IssueStatus == models.LoanOverdue || loan.ExpectedReturnDate < now.Unix() {
+			overdue++
+		}
+	}
+	if overdue > 0 {
+		reasons = append(reasons, Reason{OverdueLoans, fmt.Sprintf("Reader has %d overdue book(s) to return", overdue)})
+	}
+
+	balance, err := fines.ReaderBalance(db, readerID, libraryID)
+	if err != nil {
+		return nil, err
+	}
+	if balance > policy.MaxUnpaidFines {
+		reasons = append(reasons, Reason{UnpaidFines,
+			fmt.Sprintf("Reader owes %d cents in fines; borrowing is blocked above %d", balance, policy.MaxUnpaidFines)})
+	}
+
+	return reasons, nil
+}
+
+// reservedCopies counts the copies set aside for a reader in a library, by an
+// approved request or a ready hold, that they have not collected yet. A ready
+// hold linked to a request shares its copy and is counted through the request.
+func reservedCopies(db *gorm.DB, readerID, libraryID uint) (int64, error) {
+	var requests int64
+	if err := db.Model(&models.RequestEvent{}).
+		Where("reader_id = ? AND library_id = ? AND request_type = ? AND status IN ? AND item_id IS NOT NULL",
+			readerID, libraryID, "issue", []string{models.RequestApproved, models.RequestReadyForPickup}).
+		Count(&requests).Error; err != nil {
+		return 0, err
+	}
+
+	var holds int64
+	err := db.Model(&models.Hold{}).
+		Where("reader_id = ? AND library_id = ? AND status = ? AND item_id IS NOT NULL AND request_id IS NULL", readerID, libraryID, models.HoldReady).
+		Count(&holds).Error
+	return requests + holds, err
+}
+
+// Check returns an IneligibleError when the reader may not borrow another book
+func Check(db *gorm.DB, readerID, libraryID uint, policy policies.Policy, now time.Time) error {
+	return check(db, readerID, libraryID, policy, now, false)
+}
+
+// CheckHandover is Check for handing over a copy that is already set aside for
+// the reader, which counts towards their loan limit already
+func CheckHandover(db *gorm.DB, readerID, libraryID uint, policy policies.Policy, now time.Time) error {
+	return check(db, readerID, libraryID, policy, now, true)
+}
+
+func check(db *gorm.DB, readerID, libraryID uint, policy policies.Policy, now time.Time, handover bool) error {
+	found, err := reasons(db, readerID, libraryID, policy, now, handover)
+	if err != nil {
+		return err
+	}
+	if len(found) > 0 {
+		return &IneligibleError{Reasons: found}
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+// Package fines keeps each reader's fines ledger per library. Overdue loans
+// accrue charges for every full day they are late; admins record payments and
+// waivers against the balance.
+package fines
+
+import (
+	"errors"
+	"library-management/models"
+	"library-management/services/policies"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidAmount  = errors.New("amount must be greater than zero")
+	ErrExceedsBalance = errors.New("amount is more than the outstanding balance")
+	ErrReasonRequired = errors.New("a reason is required")
+	ErrReaderNotFound = errors.New("reader not found")
+)
+
+const day = 24 * time.Hour
+
+// Balance is what a reader owes one library, in cents
+type Balance struct {
+	ReaderID     uint  `json:"reader_id"`
+	LibraryID    uint  `json:"library_id"`
+	BalanceCents int64 `json:"balance_cents"`
+}
+
+// balanceExpr sums a ledger with charges counting up and everything else down
+const balanceExpr = "COALESCE(SUM(CASE WHEN kind = 'charge' THEN amount_cents ELSE -amount_cents END), 0)"
+
+// Owed returns the fine a loan has earned by the given time: the policy's
+// daily rate for each full day it was past a due date, up to its cap. Every
+// due date the loan has had counts: renewals lists the loan's renewals, each
+// of which ended the period of its PreviousDueDate, and the current due date
+// runs until at.
+func Owed(loan models.IssueRegistry, renewals []models.LoanRenewal, policy policies.Policy, at time.Time) int64 {
+	days := daysLate(loan.ExpectedReturnDate, at)
+	for _, renewal := range renewals {
+		days += daysLate(renewal.PreviousDueDate, renewal.CreatedAt)
+	}
+
+	owed := days * policy.FinePerDay
+	if limit := policy.MaxFine; limit > 0 && owed > limit {
+		owed = limit
+	}
+	return owed
+}
+
+// daysLate counts the full days between a due date and the given time
+func daysLate(due int64, at time.Time) int64 {
+	late := at.Sub(time.Unix(due, 0))
+	if late < day {
+		return 0
+	}
+	return int64(late / day)
+}
+
+// Charge brings the charges recorded for a loan up to what it owes at the
+// given time. It is safe to call repeatedly; only the difference is charged.
+// The caller should hold the loan lock.
+func Charge(tx *gorm.DB, loan models.IssueRegistry, at time.Time) (int64, error) {
+	// Never renewed and not a full day late yet, so there is no need to look
+	// up the policy. A renewed loan may still owe for an earlier due date.
+	if loan.RenewalCount == 0 && daysLate(loan.ExpectedReturnDate, at) == 0 {
+		return 0, nil
+	}
+
+	policy, err := policies.ForLoan(tx, loan)
+	if err != nil {
+		return 0, err
+	}
+	var renewals []models.LoanRenewal
+	if loan.RenewalCount > 0 {
+		if err := tx.Where("issue_id = ?", loan.ID).Order("id").Find(&renewals).Error; err != nil {
+			return 0, err
+		}
+	}
+	owed := Owed(loan, renewals, policy, at)
+	if owed == 0 {
+		return 0, nil
+	}
+
+	var charged int64
+	if err := tx.Model(&models.FineEntry{}).
+		Where("issue_id = ? AND kind = ?", loan.ID, models.FineCharge).
+		Select("COALESCE(SUM(amount_cents), 0)").
+		Scan(&charged).Error; err != nil {
+		return 0, err
+	}
+	if owed <= charged {
+		return 0, nil
+	}
+
+	amount := owed - charged
+	entry := models.FineEntry{
+		ReaderID:    loan.ReaderID,
+		LibraryID:   loan.LibraryID,
+		IssueID:     &loan.ID,
+		Kind:        models.FineCharge,
+		AmountCents: amount,
+		Reason:      "Overdue " + loan.ISBN,
+	}
+	return amount, tx.Create(&entry).Error
+}
+
+// AccrueOverdue charges every overdue loan what it has earned by now and
+// returns the number of loans charged
+func AccrueOverdue(db *gorm.DB, now time.Time) (int, error) {
+	var ids []uint
+	if err := db.Model(&models.IssueRegistry{}).
+		Where("issue_status = ?", models.LoanOverdue).
+		Order("id").Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	charged := 0
+	for _, id := range ids {
+		var amount int64
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var loan models.IssueRegistry
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&loan, id).Error; err != nil {
+				return err
+			}
+			// Returned or renewed since it was listed
+			if loan.IssueStatus != models.LoanOverdue {
+				return nil
+			}
+			var err error
+			amount, err = Charge(tx, loan, now)
+			return err
+		})
+		if err != nil {
+			return charged, err
+		}
+		if amount > 0 {
+			charged++
+		}
+	}
+	return charged, nil
+}
+
+// ReaderBalance returns what a reader owes a library
+func ReaderBalance(db *gorm.DB, readerID, libraryID uint) (int64, error) {
+	var balance int64
+	err := db.Model(&models.FineEntry{}).
+		Where("reader_id = ? AND library_id = ?", readerID, libraryID).
+		Select(balanceExpr).
+		Scan(&balance).Error
+	return balance, err
+}
+
+// ReaderBalances returns a reader's balance in every library they have a
+// ledger in
+func ReaderBalances(db *gorm.DB, readerID uint) ([]Balance, error) {
+	var balances []Balance
+	err := db.Model(&models.FineEntry{}).
+		Where("reader_id = ?", readerID).
+		Select("reader_id, library_id, " + balanceExpr + " AS balance_cents").
+		Group("reader_id, library_id").
+		Order("library_id").
+		Scan(&balances).Error
+	return balances, err
+}
+
+// LibraryBalances returns the readers who owe a library money, largest
+// balance first
+func LibraryBalances(db *gorm.DB, libraryID uint) ([]Balance, error) {
+	var balances []Balance
+	err := db.Model(&models.FineEntry{}).
+		Where("library_id = ?", libraryID).
+		Select("reader_id, library_id, " + balanceExpr + " AS balance_cents").
+		Group("reader_id, library_id").
+		Having(balanceExpr + " > 0").
+		Order("balance_cents DESC, reader_id").
+		Scan(&balances).Error
+	return balances, err
+}
+
+// Ledger returns a reader's entries, oldest first. A libraryID of 0 returns
+// the entries of every library.
+func Ledger(db *gorm.DB, readerID, libraryID uint) ([]models.FineEntry, error) {
+	query := db.Where("reader_id = ?", readerID)
+	if libraryID != 0 {
+		query = query.Where("library_id = ?", libraryID)
+	}
+	var entries []models.FineEntry
+	err := query.Order("id").Find(&entries).Error
+	return entries, err
+}
+
+// Pay records a payment taken by an admin
+func Pay(db *gorm.DB, readerID, libraryID uint, amount int64, actorID uint, reason string) (models.FineEntry, error) {
+	return credit(db, models.FinePayment, readerID, libraryID, amount, actorID, reason)
+}
+
+// Waive writes off part or all of a balance. A reason is required so waivers
+// can be audited.
+func Waive(db *gorm.DB, readerID, libraryID uint, amount int64, actorID uint, reason string) (models.FineEntry, error) {
+	if reason == "" {
+		return models.FineEntry{}, ErrReasonRequired
+	}
+	return credit(db, models.FineWaiver, readerID, libraryID, amount, actorID, reason)
+}
+
+// credit records an entry that reduces the balance, refusing to take it below
+// zero
+func credit(db *gorm.DB, kind string, readerID, libraryID uint, amount int64, actorID uint, reason string) (models.FineEntry, error) {
+	entry := models.FineEntry{
+		ReaderID:    readerID,
+		LibraryID:   libraryID,
+		Kind:        kind,
+		AmountCents: amount,
+		ActorID:     &actorID,
+		Reason:      reason,
+	}
+	if amount <= 0 {
+		return entry, ErrInvalidAmount
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Serialise credits to the same reader so two payments cannot both
+		// pass the balance check
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.User{}, readerID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrReaderNotFound
+			}
+			return err
+		}
+
+		balance, err := ReaderBalance(tx, readerID, libraryID)
+		if err != nil {
+			return err
+		}
+		if amount > balance {
+			return ErrExceedsBalance
+		}
+		return tx.Create(&entry).Error
+	})
+	return entry, err
+}
@@ -0,0 +1,325 @@
+// Package holds keeps a first-in, first-out reservation queue per title and
+// library. Copies that come back are set aside for the head of the queue;
+// holds that are not collected in time expire and the copy moves on. A ready
+// hold is linked to an issue request ready for pickup, so the desk checks it out
+// and pickup reports count it like an approved request; the hold still decides
+// when the copy moves on and closes the request with it.
+//
+// Operations that touch both a book and its holds lock the book row first so
+// they cannot deadlock with each other.
+package holds
+
+import (
+	"errors"
+	"library-management/models"
+	"library-management/services/inventory"
+	"library-management/services/policies"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrCopiesAvailable = errors.New("copies are available; request the book instead")
+	ErrAlreadyQueued   = errors.New("reader already has a hold on this title")
+	ErrHoldNotFound    = errors.New("hold not found")
+	ErrHoldClosed      = errors.New("hold is no longer open")
+)
+
+// Place adds a reader to the end of the queue for a title with no free copies.
+// It returns the hold and its 1-based position in the queue.
+func Place(db *gorm.DB, readerID uint, isbn string, libraryID uint) (models.Hold, int, error) {
+	var hold models.Hold
+	var position int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		book, err := inventory.LockBook(tx, isbn, libraryID)
+		if err != nil {
+			return err
+		}
+		if book.AvailableCopies > 0 {
+			return ErrCopiesAvailable
+		}
+
+		var open int64
+		if err := tx.Model(&models.Hold{}).
+			Where("reader_id = ? AND isbn = ? AND library_id = ? AND status IN ?", readerID, isbn, libraryID, models.OpenHoldStatuses).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return ErrAlreadyQueued
+		}
+
+		hold = models.Hold{ISBN: isbn, LibraryID: libraryID, ReaderID: readerID, Status: models.HoldWaiting}
+		if err := tx.Create(&hold).Error; err != nil {
+			return err
+		}
+
+		position, err = Position(tx, hold)
+		return err
+	})
+	return hold, position, err
+}
+
+// Position returns where a waiting hold stands in its queue, starting at 1.
+// Holds that are not waiting have position 0.
+func Position(db *gorm.DB, hold models.Hold) (int, error) {
+	if hold.Status != models.HoldWaiting {
+		return 0, nil
+	}
+	var ahead int64
+	err := db.Model(&models.Hold{}).
+		Where("isbn = ? AND library_id = ? AND status = ? AND id < ?", hold.ISBN, hold.LibraryID, models.HoldWaiting, hold.ID).
+		Count(&ahead).Error
+	return int(ahead) + 1, err
+}
+
+// Allocate sets free copies of a title aside for the oldest waiting holds and
+// returns the holds that became ready. Call it whenever copies come back into
+// stock.
+func Allocate(db *gorm.DB, isbn string, libraryID uint) ([]models.Hold, error) {
+	var ready []models.Hold
+	err := db.Transaction(func(tx *gorm.DB) error {
+		book, err := inventory.LockBook(tx, isbn, libraryID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for book.AvailableCopies > 0 {
+			var hold models.Hold
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("isbn = ? AND library_id = ? AND status = ?", isbn, libraryID, models.HoldWaiting).
+				Order("id").
+				First(&hold).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			// The pickup window depends on the reader's membership tier
+			policy, err := policies.ForReader(tx, hold.ReaderID, book)
+			if err != nil {
+				return err
+			}
+
+			var item models.Item
+			if book, item, err = inventory.Reserve(tx, isbn, libraryID); err != nil {
+				return err
+			}
+
+			readyAt := now.Unix()
+			pickupDeadline := now.AddDate(0, 0, policy.HoldPickupDays).Unix()
+			hold.Status = models.HoldReady
+			hold.ReadyAt = &readyAt
+			hold.PickupDeadline = &pickupDeadline
+			hold.ItemID = &item.ID
+			if err := linkRequest(tx, &hold); err != nil {
+				return err
+			}
+			if err := tx.Save(&hold).Error; err != nil {
+				return err
+			}
+			ready = append(ready, hold)
+		}
+		return nil
+	})
+	return ready, err
+}
+
+// Claim marks a reader's open hold on a title as fulfilled when the reader is
+// issued the book. It returns the copy that was set aside for the hold, if
+// any, in which case the caller must not take another copy out of stock.
+// The caller must hold the book lock.
+func Claim(tx *gorm.DB, readerID uint, isbn string, libraryID uint) (*models.Item, error) {
+	var hold models.Hold
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("reader_id = ? AND isbn = ? AND library_id = ? AND status IN ?", readerID, isbn, libraryID, models.OpenHoldStatuses).
+		Order("id").
+		First(&hold).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	setAside := hold.Status == models.HoldReady && hold.ItemID != nil
+	if err := tx.Model(&hold).Update("status", models.HoldFulfilled).Error; err != nil {
+		return nil, err
+	}
+	if err := closeRequest(tx, hold, models.HoldFulfilled); err != nil {
+		return nil, err
+	}
+	if !setAside {
+		return nil, nil
+	}
+
+	var item models.Item
+	if err := tx.First(&item, *hold.ItemID).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Collect marks the ready hold linked to a request fulfilled when the desk
+// checks the request out. The request's copy is the hold's, so nothing is left
+// to release.
+func Collect(tx *gorm.DB, requestID uint) error {
+	return tx.Model(&models.Hold{}).
+		Where("request_id = ? AND status = ?", requestID, models.HoldReady).
+		Update("status", models.HoldFulfilled).Error
+}
+
+// Cancel withdraws a reader's open hold. A copy that was set aside for it goes
+// to the next reader in the queue.
+func Cancel(db *gorm.DB, holdID, readerID uint) (models.Hold, error) {
+	var hold models.Hold
+	if err := db.Where("reader_id = ?", readerID).First(&hold, holdID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return hold, ErrHoldNotFound
+		}
+		return hold, err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return closeHold(tx, &hold, models.OpenHoldStatuses, models.HoldCancelled)
+	})
+	return hold, err
+}
+
+// ExpireOverdue expires ready holds whose pickup deadline has passed and
+// passes their copies on. It returns the number of holds expired.
+func ExpireOverdue(db *gorm.DB, now time.Time) (int, error) {
+	var overdue []models.Hold
+	if err := db.Where("status = ? AND pickup_deadline < ?", models.HoldReady, now.Unix()).
+		Order("id").Find(&overdue).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range overdue {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return closeHold(tx, &overdue[i], []string{models.HoldReady}, models.HoldExpired)
+		})
+		if errors.Is(err, ErrHoldClosed) {
+			continue // Collected or cancelled since it was listed
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// closeHold moves a hold from one of the given statuses to a closed status. If a
+// copy was set aside it is returned to stock and offered to the next hold.
+func closeHold(tx *gorm.DB, hold *models.Hold, from []string, to string) error {
+	// A title withdrawn from the library has no stock left to return to
+	_, err := inventory.LockBook(tx, hold.ISBN, hold.LibraryID)
+	bookGone := errors.Is(err, inventory.ErrBookNotFound)
+	if err != nil && !bookGone {
+		return err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(hold, hold.ID).Error; err != nil {
+		return err
+	}
+
+	allowed := false
+	for _, status := range from {
+		if hold.Status == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return ErrHoldClosed
+	}
+
+	setAside := hold.Status == models.HoldReady && hold.ItemID != nil
+	hold.Status = to
+	if err := tx.Save(hold).Error; err != nil {
+		return err
+	}
+	if err := closeRequest(tx, *hold, to); err != nil {
+		return err
+	}
+	if !setAside || bookGone {
+		return nil
+	}
+
+	if _, err := inventory.Release(tx, *hold.ItemID); err != nil {
+		return err
+	}
+	_, err = Allocate(tx, hold.ISBN, hold.LibraryID)
+	return err
+}
+
+// requestStatuses maps how a ready hold closed to how its request ends
+var requestStatuses = map[string]struct{ status, reason string }{
+	models.HoldFulfilled: {models.RequestFulfilled, ""},
+	models.HoldCancelled: {models.RequestCancelled, "Hold cancelled by the reader"},
+	models.HoldExpired:   {models.RequestExpired, "Not collected by the hold's pickup deadline"},
+}
+
+// linkRequest records a hold that became ready as an issue request ready for
+// pickup holding the same copy. The requests package allocates copies through
+// this one, so the request and its history are written here.
+func linkRequest(tx *gorm.DB, hold *models.Hold) error {
+	request := models.RequestEvent{
+		BookID:         hold.ISBN,
+		LibraryID:      hold.LibraryID,
+		ReaderID:       hold.ReaderID,
+		RequestDate:    hold.CreatedAt.Unix(),
+		ApprovalDate:   hold.ReadyAt,
+		RequestType:    "issue",
+		ItemID:         hold.ItemID,
+		PickupDeadline: hold.PickupDeadline,
+		Status:         models.RequestReadyForPickup,
+	}
+	if err := tx.Create(&request).Error; err != nil {
+		return err
+	}
+	hold.RequestID = &request.ID
+	return tx.Create(&models.RequestTransition{
+		RequestID: request.ID,
+		ToStatus:  models.RequestReadyForPickup,
+		Reason:    "Copy set aside for a hold",
+	}).Error
+}
+
+// closeRequest ends the request linked to a hold that closed. A request the
+// desk already checked out is left as it is.
+func closeRequest(tx *gorm.DB, hold models.Hold, to string) error {
+	if hold.RequestID == nil {
+		return nil
+	}
+	var request models.RequestEvent
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, *hold.RequestID).Error; err != nil {
+		return err
+	}
+	if request.Status != models.RequestReadyForPickup {
+		return nil
+	}
+
+	end := requestStatuses[to]
+	request.Status = end.status
+	if end.status == models.RequestExpired {
+		at := time.Now().Unix()
+		request.ExpiryReason = end.reason
+		request.ExpiredAt = &at
+	}
+	if err := tx.Save(&request).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.RequestTransition{
+		RequestID:  request.ID,
+		FromStatus: models.RequestReadyForPickup,
+		ToStatus:   end.status,
+		Reason:     end.reason,
+	}).Error
+}
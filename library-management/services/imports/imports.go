@@ -0,0 +1,234 @@
+// Package imports loads books into a library's catalog from CSV, MARC21 and
+// MARCXML files. An upload is stored as a queued import and worked through
+// by a background job, one row per transaction, so progress survives a
+// restart and a bad row only skips itself. Copies are added exactly as
+// AddBook adds them, merging into a book the library already holds.
+package imports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"library-management/isbn"
+	"library-management/models"
+	"library-management/services/holds"
+	"library-management/services/inventory"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown import format")
+	ErrUnreadable    = errors.New("import file could not be read")
+	ErrEmptyFile     = errors.New("import file is empty")
+	ErrNotFound      = errors.New("import not found")
+)
+
+// DetectFormat guesses the format of an upload from its file name, returning
+// "" when the extension is not one of .csv, .mrc, .marc or .xml
+func DetectFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return models.ImportCSV
+	case ".mrc", ".marc":
+		return models.ImportMARC
+	case ".xml":
+		return models.ImportMARCXML
+	}
+	return ""
+}
+
+// Create checks an upload can be read and queues it for import
+func Create(db *gorm.DB, libraryID uint, uploadedBy *uint, format, filename string, payload []byte) (models.CatalogImport, error) {
+	if len(payload) == 0 {
+		return models.CatalogImport{}, ErrEmptyFile
+	}
+	records, err := Parse(format, payload)
+	if err != nil {
+		return models.CatalogImport{}, err
+	}
+
+	job := models.CatalogImport{
+		LibraryID:  libraryID,
+		UploadedBy: uploadedBy,
+		Format:     format,
+		Filename:   filename,
+		Payload:    payload,
+		Status:     models.ImportQueued,
+		TotalRows:  len(records),
+	}
+	return job, db.Create(&job).Error
+}
+
+// Get loads an import with its per-row errors, in file order
+func Get(db *gorm.DB, importID uint) (models.CatalogImport, []models.CatalogImportError, error) {
+	var job models.CatalogImport
+	if err := db.Omit("payload").First(&job, importID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return job, nil, ErrNotFound
+		}
+		return job, nil, err
+	}
+	var rowErrors []models.CatalogImportError
+	err := db.Where("import_id = ?", importID).Order("row, id").Find(&rowErrors).Error
+	return job, rowErrors, err
+}
+
+// RequeueStale puts running imports that have saved no progress for longer
+// than timeout back in the queue. Their runner was stopped without requeueing
+// them, by a crash or a lost database connection, and a later run carries on
+// after the last row saved. It returns how many imports were requeued.
+func RequeueStale(db *gorm.DB, now time.Time, timeout time.Duration) (int64, error) {
+	result := db.Model(&models.CatalogImport{}).
+		Where("status = ? AND updated_at < ?", models.ImportRunning, now.Add(-timeout)).
+		Update("status", models.ImportQueued)
+	return result.RowsAffected, result.Error
+}
+
+// RunQueued works through queued imports, oldest first, until none are left or
+// ctx is cancelled. It returns how many imports it finished.
+func RunQueued(ctx context.Context, db *gorm.DB) (int, error) {
+	finished := 0
+	for ctx.Err() == nil {
+		var next models.CatalogImport
+		err := db.Select("id").Where("status = ?", models.ImportQueued).Order("id").First(&next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return finished, nil
+		}
+		if err != nil {
+			return finished, err
+		}
+		if err := Run(ctx, db, next.ID); err != nil {
+			return finished, err
+		}
+		finished++
+	}
+	return finished, nil
+}
+
+// Run claims a queued import and imports its remaining rows. When ctx is
+// cancelled part way the import goes back to the queue and a later run
+// carries on after the last row saved.
+func Run(ctx context.Context, db *gorm.DB, importID uint) error {
+	now := time.Now().Unix()
+	claim := db.Model(&models.CatalogImport{}).
+		Where("id = ? AND status = ?", importID, models.ImportQueued).
+		Updates(map[string]interface{}{"status": models.ImportRunning, "started_at": gorm.Expr("COALESCE(started_at, ?)", now)})
+	if claim.Error != nil {
+		return claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return nil // Another runner took it
+	}
+
+	var job models.CatalogImport
+	if err := db.First(&job, importID).Error; err != nil {
+		return err
+	}
+
+	records, err := Parse(job.Format, job.Payload)
+	if err != nil {
+		return finish(db, &job, models.ImportFailed, err.Error())
+	}
+
+	for _, record := range records[job.ProcessedRows:] {
+		if ctx.Err() != nil {
+			return db.Model(&job).Update("status", models.ImportQueued).Error
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return importRecord(tx, &job, record)
+		})
+		if errors.Is(err, errClaimLost) {
+			return nil // Requeued as stale and taken over by another runner
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return finish(db, &job, models.ImportCompleted, "")
+}
+
+// importRecord adds one record's copies and saves the import's progress in the
+// same transaction. A row that cannot be imported is rolled back on its own
+// savepoint and recorded as an error. Saving the progress also marks the
+// import as alive; if another runner has saved this row first the whole
+// transaction is rolled back with errClaimLost.
+func importRecord(tx *gorm.DB, job *models.CatalogImport, record Record) error {
+	problem := record.Problem
+	if problem == "" {
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			if strings.TrimSpace(record.Book.Title) == "" {
+				return errTitleRequired
+			}
+			details := record.Book
+			details.LibraryID = job.LibraryID
+			book, _, err := inventory.AddCopies(tx, details, record.Items)
+			if err != nil {
+				return err
+			}
+			_, err = holds.Allocate(tx, book.ISBN, book.LibraryID)
+			return err
+		})
+		if err != nil {
+			if problem = rowProblem(err, record); problem == "" {
+				return err
+			}
+		}
+	}
+
+	job.ProcessedRows++
+	if problem == "" {
+		job.ImportedRows++
+	} else {
+		job.FailedRows++
+		rowError := models.CatalogImportError{ImportID: job.ID, Row: record.Row, ISBN: record.Book.ISBN, Message: problem}
+		if err := tx.Create(&rowError).Error; err != nil {
+			return err
+		}
+	}
+	progress := tx.Model(job).Where("processed_rows = ?", job.ProcessedRows-1).Updates(map[string]interface{}{
+		"processed_rows": job.ProcessedRows,
+		"imported_rows":  job.ImportedRows,
+		"failed_rows":    job.FailedRows,
+	})
+	if progress.Error == nil && progress.RowsAffected == 0 {
+		return errClaimLost
+	}
+	return progress.Error
+}
+
+var (
+	errTitleRequired = errors.New("title is required")
+	errClaimLost     = errors.New("import was taken over by another runner")
+)
+
+// rowProblem describes why a row was rejected, or returns "" when the error
+// is not the row's fault and the import should stop
+func rowProblem(err error, record Record) string {
+	switch {
+	case errors.Is(err, errTitleRequired):
+		return "Title is required"
+	case errors.Is(err, isbn.ErrInvalid):
+		return fmt.Sprintf("Invalid ISBN %q", record.Book.ISBN)
+	case errors.Is(err, inventory.ErrBarcodeTaken):
+		return "Barcode is already in use"
+	case errors.Is(err, inventory.ErrInvalidCondition):
+		return "Condition must be one of " + strings.Join(models.ItemConditions, ", ")
+	case errors.Is(err, inventory.ErrInvalidCopyCount):
+		return "Number of copies must be greater than zero"
+	case errors.Is(err, inventory.ErrTooManyCopies):
+		return "Too many copies in one row"
+	}
+	return ""
+}
+
+func finish(db *gorm.DB, job *models.CatalogImport, status, message string) error {
+	now := time.Now().Unix()
+	job.Status = status
+	job.Error = message
+	job.FinishedAt = &now
+	return db.Model(job).Updates(map[string]interface{}{"status": status, "error": message, "finished_at": now}).Error
+}
@@ -0,0 +1,336 @@
+package imports
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"library-management/config"
+	"library-management/models"
+	"library-management/services/inventory"
+	"strconv"
+	"strings"
+)
+
+// Record is one book read from an import file with the copies to add
+type Record struct {
+	Row     int // Line of a CSV file or record number in a MARC file
+	Book    models.Book
+	Items   []inventory.NewItem
+	Problem string // Why the row could not be read; such rows are reported, not imported
+}
+
+// Parse reads every record of an import file. An error means the file as a
+// whole could not be read; problems with single rows are left on the records.
+func Parse(format string, payload []byte) ([]Record, error) {
+	switch format {
+	case models.ImportCSV:
+		return parseCSV(payload)
+	case models.ImportMARC:
+		return parseMARC(payload)
+	case models.ImportMARCXML:
+		return parseMARCXML(payload)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// csvColumns maps accepted CSV headers to the field they fill
+var csvColumns = map[string]string{
+	"isbn":      "isbn",
+	"title":     "title",
+	"author":    "authors",
+	"authors":   "authors",
+	"publisher": "publisher",
+	"version":   "version",
+	"edition":   "version",
+	"category":  "category",
+	"copies":    "copies",
+	"barcode":   "barcode",
+	"condition": "condition",
+}
+
+// parseCSV reads a CSV file with a header row. A row with a barcode is one
+// copy; otherwise "copies" copies (default 1) get generated barcodes.
+func parseCSV(payload []byte) ([]Record, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(payload, []byte("\ufeff"))))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading CSV header: %v", ErrUnreadable, err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		if field, ok := csvColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			columns[field] = i
+		}
+	}
+	if _, ok := columns["isbn"]; !ok {
+		return nil, fmt.Errorf("%w: the CSV header needs an isbn column", ErrUnreadable)
+	}
+
+	var records []Record
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if !errors.Is(err, csv.ErrFieldCount) {
+				return records, fmt.Errorf("%w: %v", ErrUnreadable, err)
+			}
+			records = append(records, Record{Row: parseErr.StartLine, Problem: "Wrong number of columns"})
+			continue
+		}
+		if err != nil {
+			return records, fmt.Errorf("%w: %v", ErrUnreadable, err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		value := func(field string) string {
+			if i, ok := columns[field]; ok {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		record := Record{Row: line, Book: models.Book{
+			ISBN:      value("isbn"),
+			Title:     value("title"),
+			Authors:   value("authors"),
+			Publisher: value("publisher"),
+			Version:   value("version"),
+			Category:  value("category"),
+		}}
+
+		copies := 1
+		if raw := value("copies"); raw != "" {
+			maxCopies := config.AppConfig.MaxNewCopies
+			if copies, err = strconv.Atoi(raw); err != nil || copies < 1 {
+				record.Problem = fmt.Sprintf("Copies must be a whole number of at least 1, got %q", raw)
+			} else if copies > maxCopies {
+				record.Problem = fmt.Sprintf("At most %d copies can be added in one row, got %d", maxCopies, copies)
+			}
+		}
+		switch barcode := value("barcode"); {
+		case record.Problem != "":
+		case barcode != "" && copies != 1:
+			record.Problem = "A row with a barcode describes a single copy"
+		case barcode != "":
+			record.Items = []inventory.NewItem{{Barcode: barcode, Condition: value("condition")}}
+		default:
+			record.Items = make([]inventory.NewItem, copies)
+			for i := range record.Items {
+				record.Items[i].Condition = value("condition")
+			}
+		}
+		records = append(records, record)
+	}
+}
+
+// marcRecord holds the data fields of a MARC21 record by tag
+type marcRecord map[string][]marcField
+
+type marcField []marcSubfield
+
+type marcSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// first returns the first subfield with the given code in the first field with the tag
+func (r marcRecord) first(tag, code string) string {
+	for _, field := range r[tag] {
+		for _, subfield := range field {
+			if subfield.Code == code {
+				return strings.TrimSpace(subfield.Value)
+			}
+		}
+	}
+	return ""
+}
+
+// all returns every subfield with the given code in fields with the tag
+func (r marcRecord) all(tag, code string) []string {
+	var values []string
+	for _, field := range r[tag] {
+		for _, subfield := range field {
+			if subfield.Code == code {
+				values = append(values, strings.TrimSpace(subfield.Value))
+			}
+		}
+	}
+	return values
+}
+
+// record maps the bibliographic fields this catalog keeps. Each 852 holdings
+// field with a barcode ($p) is one copy; without any the record adds one copy.
+func (r marcRecord) record(row int) Record {
+	// 020 $a may carry a qualifier, e.g. "9780134685991 (paperback)"
+	isbn := r.first("020", "a")
+	if fields := strings.Fields(isbn); len(fields) > 0 {
+		isbn = fields[0]
+	}
+
+	title := trimPunctuation(r.first("245", "a"))
+	if subtitle := trimPunctuation(r.first("245", "b")); subtitle != "" {
+		title += ": " + subtitle
+	}
+
+	var authors []string
+	for _, name := range append(r.all("100", "a"), r.all("700", "a")...) {
+		if name = trimPunctuation(name); name != "" {
+			authors = append(authors, name)
+		}
+	}
+
+	publisher := r.first("264", "b")
+	if publisher == "" {
+		publisher = r.first("260", "b")
+	}
+
+	record := Record{Row: row, Book: models.Book{
+		ISBN:      isbn,
+		Title:     title,
+		Authors:   strings.Join(authors, "; "),
+		Publisher: trimPunctuation(publisher),
+		Version:   trimPunctuation(r.first("250", "a")),
+		Category:  trimPunctuation(r.first("650", "a")),
+	}}
+	for _, barcode := range r.all("852", "p") {
+		record.Items = append(record.Items, inventory.NewItem{Barcode: barcode})
+	}
+	if len(record.Items) == 0 {
+		record.Items = make([]inventory.NewItem, 1)
+	}
+	if maxCopies := config.AppConfig.MaxNewCopies; len(record.Items) > maxCopies {
+		record.Problem = fmt.Sprintf("At most %d copies can be added in one record, got %d", maxCopies, len(record.Items))
+		record.Items = nil
+	}
+	return record
+}
+
+// trimPunctuation drops the ISBD punctuation MARC leaves at the end of a subfield
+func trimPunctuation(value string) string {
+	return strings.TrimRight(strings.TrimSpace(value), " /:;,.=")
+}
+
+// MARC21 transmission format delimiters
+const (
+	marcSubfieldDelimiter = 0x1F
+	marcFieldTerminator   = 0x1E
+	marcRecordTerminator  = 0x1D
+)
+
+// parseMARC reads MARC21 records in ISO 2709 format
+func parseMARC(payload []byte) ([]Record, error) {
+	var records []Record
+	for n, raw := range bytes.Split(payload, []byte{marcRecordTerminator}) {
+		if len(bytes.TrimSpace(raw)) == 0 {
+			continue
+		}
+		fields, ok := decodeISO2709(raw)
+		if !ok {
+			records = append(records, Record{Row: n + 1, Problem: "Malformed MARC record"})
+			continue
+		}
+		records = append(records, fields.record(n+1))
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: no MARC records found", ErrUnreadable)
+	}
+	return records, nil
+}
+
+// decodeISO2709 reads the directory of one record (without its terminator)
+// and the data fields it points to
+func decodeISO2709(raw []byte) (marcRecord, bool) {
+	if len(raw) < 25 {
+		return nil, false
+	}
+	base, ok := marcNumber(raw[12:17])
+	if !ok || base < 25 || base > len(raw) {
+		return nil, false
+	}
+
+	record := marcRecord{}
+	directory := raw[24 : base-1]
+	for len(directory) >= 12 {
+		entry := directory[:12]
+		directory = directory[12:]
+
+		tag := string(entry[:3])
+		length, ok1 := marcNumber(entry[3:7])
+		start, ok2 := marcNumber(entry[7:12])
+		if !ok1 || !ok2 || base+start+length > len(raw) {
+			return nil, false
+		}
+		data := bytes.TrimSuffix(raw[base+start:base+start+length], []byte{marcFieldTerminator})
+		if tag < "010" || len(data) < 2 {
+			continue // Control fields have no subfields
+		}
+
+		var field marcField
+		for _, part := range bytes.Split(data[2:], []byte{marcSubfieldDelimiter}) {
+			if len(part) > 0 {
+				field = append(field, marcSubfield{Code: string(part[:1]), Value: string(part[1:])})
+			}
+		}
+		record[tag] = append(record[tag], field)
+	}
+	return record, true
+}
+
+// marcNumber reads a fixed-width number of the leader or directory, which
+// is all digits: no sign, so it can never be negative
+func marcNumber(digits []byte) (int, bool) {
+	n := 0
+	for _, d := range digits {
+		if d < '0' || d > '9' {
+			return 0, false
+		}
+		n = n*10 + int(d-'0')
+	}
+	return n, true
+}
+
+// parseMARCXML reads the <record> elements of a MARCXML document, with or
+// without the MARC21 slim namespace
+func parseMARCXML(payload []byte) ([]Record, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(payload))
+	var records []Record
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return records, fmt.Errorf("%w: %v", ErrUnreadable, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		var element struct {
+			DataFields []struct {
+				Tag       string         `xml:"tag,attr"`
+				Subfields []marcSubfield `xml:"subfield"`
+			} `xml:"datafield"`
+		}
+		if err := decoder.DecodeElement(&element, &start); err != nil {
+			return records, fmt.Errorf("%w: %v", ErrUnreadable, err)
+		}
+		record := marcRecord{}
+		for _, field := range element.DataFields {
+			record[field.Tag] = append(record[field.Tag], field.Subfields)
+		}
+		records = append(records, record.record(len(records)+1))
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: no MARCXML records found", ErrUnreadable)
+	}
+	return records, nil
+}
@@ -0,0 +1,414 @@
+// Package inventory owns every change to a book's copies. Each physical copy is
+// an item with its own barcode and status; the book's TotalCopies and
+// AvailableCopies are recounted from its items whenever one changes. Each
+// operation runs in a transaction and locks the book row (SELECT ... FOR UPDATE)
+// before it touches the items, so concurrent handlers cannot hand out the same
+// copy twice.
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"library-management/config"
+	"library-management/isbn"
+	"library-management/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrBookNotFound       = errors.New("book not found in the specified library")
+	ErrInvalidCopyCount   = errors.New("number of copies must be greater than zero")
+	ErrTooManyCopies      = errors.New("too many copies added at once")
+	ErrNoCopiesAvailable  = errors.New("no available copies")
+	ErrCopiesOnLoan       = errors.New("copies are currently on loan")
+	ErrAllCopiesAvailable = errors.New("all copies are already available")
+	ErrItemNotFound       = errors.New("item not found")
+	ErrItemNotAvailable   = errors.New("item is not on the shelf")
+	ErrBarcodeTaken       = errors.New("barcode is already in use")
+	ErrInvalidCondition   = errors.New("unknown item condition")
+)
+
+// NewItem describes a copy being added. An empty barcode is generated from
+// the library and ISBN; an empty condition means "good".
+type NewItem struct {
+	Barcode   string `json:"barcode"`
+	Condition string `json:"condition"`
+}
+
+// LockBook loads a book row and holds a write lock on it until the transaction
+// ends. Callers that also lock other rows take the book lock first.
+func LockBook(tx *gorm.DB, isbn string, libraryID uint) (models.Book, error) {
+	var book models.Book
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("isbn = ? AND library_id = ?", isbn, libraryID).
+		First(&book).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return book, ErrBookNotFound
+	}
+	return book, err
+}
+
+// Reserve sets one copy of a book on the shelf aside and returns it
+func Reserve(db *gorm.DB, isbn string, libraryID uint) (models.Book, models.Item, error) {
+	var book models.Book
+	var item models.Item
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if book, err = LockBook(tx, isbn, libraryID); err != nil {
+			return err
+		}
+
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("book_id = ? AND status = ?", book.ID, models.ItemAvailable).
+			Order("id").
+			First(&item).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNoCopiesAvailable
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&item).Update("status", models.ItemReserved).Error; err != nil {
+			return err
+		}
+		return recount(tx, &book)
+	})
+	return book, item, err
+}
+
+// Lend marks a copy that was set aside as on loan
+func Lend(db *gorm.DB, itemID uint) (models.Item, error) {
+	var item models.Item
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, itemID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return item, ErrItemNotFound
+		}
+		return item, err
+	}
+	if item.Status != models.ItemReserved {
+		return item, ErrItemNotAvailable
+	}
+	return item, db.Model(&item).Update("status", models.ItemOnLoan).Error
+}
+
+// Release puts a copy that was on loan or set aside back on the shelf
+func Release(db *gorm.DB, itemID uint) (models.Book, error) {
+	var book models.Book
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var item models.Item
+		if err := tx.First(&item, itemID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrItemNotFound
+			}
+			return err
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, item.BookID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBookNotFound
+			}
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, itemID).Error; err != nil {
+			return err
+		}
+		switch item.Status {
+		case models.ItemAvailable:
+			return ErrAllCopiesAvailable
+		case models.ItemWithdrawn:
+			return ErrItemNotFound
+		}
+
+		if err := tx.Model(&item).Update("status", models.ItemAvailable).Error; err != nil {
+			return err
+		}
+		return recount(tx, &book)
+	})
+	return book, err
+}
+
+// AddCopies adds copies of a book to a library, creating the book from the given
+// details if the library does not hold it yet. The ISBN is stored in its
+// normalized form. The returned flag reports creation.
+func AddCopies(db *gorm.DB, details models.Book, items []NewItem) (models.Book, bool, error) {
+	if len(items) == 0 {
+		return models.Book{}, false, ErrInvalidCopyCount
+	}
+	if len(items) > config.AppConfig.MaxNewCopies {
+		return models.Book{}, false, ErrTooManyCopies
+	}
+	normalized, err := isbn.Normalize(details.ISBN)
+	if err != nil {
+		return models.Book{}, false, err
+	}
+	details.ISBN = normalized
+
+	var book models.Book
+	created := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		book, err = LockBook(tx, details.ISBN, details.LibraryID)
+		if errors.Is(err, ErrBookNotFound) {
+			book = details
+			book.TotalCopies = 0
+			book.AvailableCopies = 0
+			created = true
+			err = tx.Create(&book).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := addItems(tx, book, items); err != nil {
+			return err
+		}
+		return recount(tx, &book)
+	})
+	return book, created, err
+}
+
+// Withdraw removes a copy that is on the shelf from the collection: the copy
+// with the given barcode, or the newest one when barcode is empty. Withdrawing
+// the last copy deletes the book; the returned flag reports deletion.
+func Withdraw(db *gorm.DB, isbn string, libraryID uint, barcode string) (models.Book, bool, error) {
+	var book models.Book
+	removed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if book, err = LockBook(tx, isbn, libraryID); err != nil {
+			return err
+		}
+
+		var item models.Item
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("book_id = ?", book.ID)
+		if barcode != "" {
+			err = query.Where("barcode = ?", barcode).First(&item).Error
+		} else {
+			err = query.Where("status = ?", models.ItemAvailable).Order("id DESC").First(&item).Error
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if barcode != "" {
+				return ErrItemNotFound
+			}
+			return ErrCopiesOnLoan
+		}
+		if err != nil {
+			return err
+		}
+		if item.Status != models.ItemAvailable {
+			return ErrItemNotAvailable
+		}
+
+		if err := tx.Model(&item).Update("status", models.ItemWithdrawn).Error; err != nil {
+			return err
+		}
+		if err := recount(tx, &book); err != nil {
+			return err
+		}
+
+		if book.TotalCopies == 0 {
+			removed = true
+			return tx.Delete(&book).Error
+		}
+		return nil
+	})
+	return book, removed, err
+}
+
+// BookChanges lists the details Update changes; nil fields are left as they are
+type BookChanges struct {
+	Title       *string
+	Authors     *string
+	Publisher   *string
+	Version     *string
+	Category    *string
+	TotalCopies *int
+}
+
+// Update changes a book's descriptive details. A different total copy count
+// adds new copies or withdraws copies on the shelf, newest first; copies on
+// loan or set aside are never withdrawn. As with Withdraw, going down to no
+// copies deletes the book; the returned flag reports deletion.
+func Update(db *gorm.DB, isbn string, libraryID uint, changes BookChanges) (models.Book, bool, error) {
+	var book models.Book
+	removed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if book, err = LockBook(tx, isbn, libraryID); err != nil {
+			return err
+		}
+
+		for _, field := range []struct {
+			value  *string
+			target *string
+		}{
+			{changes.Title, &book.Title},
+			{changes.Authors, &book.Authors},
+			{changes.Publisher, &book.Publisher},
+			{changes.Version, &book.Version},
+			{changes.Category, &book.Category},
+		} {
+			if field.value != nil {
+				*field.target = *field.value
+			}
+		}
+		if err := tx.Save(&book).Error; err != nil {
+			return err
+		}
+		if changes.TotalCopies == nil {
+			return nil
+		}
+
+		total := *changes.TotalCopies
+		if total < 0 {
+			return ErrInvalidCopyCount
+		}
+		if total < book.TotalCopies-book.AvailableCopies {
+			return ErrCopiesOnLoan
+		}
+		if extra := total - book.TotalCopies; extra > config.AppConfig.MaxNewCopies {
+			return ErrTooManyCopies
+		} else if extra > 0 {
+			if err := addItems(tx, book, make([]NewItem, extra)); err != nil {
+				return err
+			}
+		} else if extra < 0 {
+			var surplus []uint
+			if err := tx.Model(&models.Item{}).
+				Where("book_id = ? AND status = ?", book.ID, models.ItemAvailable).
+				Order("id DESC").Limit(-extra).
+				Pluck("id", &surplus).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Item{}).Where("id IN ?", surplus).
+				Update("status", models.ItemWithdrawn).Error; err != nil {
+				return err
+			}
+		}
+		if err := recount(tx, &book); err != nil {
+			return err
+		}
+
+		if book.TotalCopies == 0 {
+			removed = true
+			return tx.Delete(&book).Error
+		}
+		return nil
+	})
+	return book, removed, err
+}
+
+// Items lists a book's copies in a library, oldest first. Withdrawn copies are
+// included so their history can still be traced.
+func Items(db *gorm.DB, isbn string, libraryID uint) ([]models.Item, error) {
+	var items []models.Item
+	err := db.Joins("JOIN books ON books.id = items.book_id").
+		Where("books.isbn = ? AND items.library_id = ?", isbn, libraryID).
+		Order("items.id").
+		Find(&items).Error
+	return items, err
+}
+
+// ValidCondition reports whether condition is a known item condition
+func ValidCondition(condition string) bool {
+	for _, known := range models.ItemConditions {
+		if known == condition {
+			return true
+		}
+	}
+	return false
+}
+
+// addItems creates copies of a book on the shelf. The caller holds the book
+// lock and recounts afterwards.
+func addItems(tx *gorm.DB, book models.Book, items []NewItem) error {
+	// Generated barcodes continue the library's numbering for the ISBN after
+	// its highest number, so gaps and hand-entered barcodes in the same
+	// pattern are never reused
+	prefix := fmt.Sprintf("%d-%s-", book.LibraryID, book.ISBN)
+	var barcodes []string
+	if err := tx.Model(&models.Item{}).Where("barcode LIKE ?", prefix+"%").Pluck("barcode", &barcodes).Error; err != nil {
+		return err
+	}
+	last := 0
+	follow := func(barcode string) {
+		if suffix, ok := strings.CutPrefix(barcode, prefix); ok {
+			if n, err := strconv.Atoi(suffix); err == nil && n > last {
+				last = n
+			}
+		}
+	}
+	for _, barcode := range barcodes {
+		follow(barcode)
+	}
+
+	acquiredAt := time.Now().Unix()
+	for _, details := range items {
+		item := models.Item{
+			Barcode:    details.Barcode,
+			BookID:     book.ID,
+			LibraryID:  book.LibraryID,
+			Condition:  details.Condition,
+			Status:     models.ItemAvailable,
+			AcquiredAt: acquiredAt,
+		}
+		if item.Condition == "" {
+			item.Condition = "good"
+		}
+		if !ValidCondition(item.Condition) {
+			return ErrInvalidCondition
+		}
+		if item.Barcode == "" {
+			item.Barcode = fmt.Sprintf("%s%03d", prefix, last+1)
+		}
+
+		var taken int64
+		if err := tx.Model(&models.Item{}).Where("barcode = ?", item.Barcode).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrBarcodeTaken
+		}
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+		follow(item.Barcode)
+	}
+	return nil
+}
+
+// recount derives a book's copy counters from the status of its items
+func recount(tx *gorm.DB, book *models.Book) error {
+	var counts []struct {
+		Status string
+		Count  int
+	}
+	if err := tx.Model(&models.Item{}).
+		Select("status, COUNT(*) AS count").
+		Where("book_id = ?", book.ID).
+		Group("status").
+		Scan(&counts).Error; err != nil {
+		return err
+	}
+
+	book.TotalCopies = 0
+	book.AvailableCopies = 0
+	for _, count := range counts {
+		if count.Status != models.ItemWithdrawn {
+			book.TotalCopies += count.Count
+		}
+		if count.Status == models.ItemAvailable {
+			book.AvailableCopies = count.Count
+		}
+	}
+	return tx.Model(book).Updates(map[string]interface{}{
+		"total_copies":     book.TotalCopies,
+		"available_copies": book.AvailableCopies,
+	}).Error
+}
@@ -0,0 +1,116 @@
+package inventory
+
+import (
+	"errors"
+	"library-management/isbn"
+	"library-management/models"
+
+	"gorm.io/gorm"
+)
+
+// NormalizeReport counts the rows NormalizeISBNs rewrote
+type NormalizeReport struct {
+	Books    int      // Books whose ISBN was rewritten
+	Merged   int      // Books folded into another book with the same normalized ISBN
+	Requests int      // Request rows rewritten
+	Loans    int      // Loan rows rewritten
+	Holds    int      // Hold rows rewritten
+	Invalid  []string // ISBNs that could not be normalized and were left as they are
+}
+
+// errDryRun rolls back a dry run once the report is complete
+var errDryRun = errors.New("dry run")
+
+// NormalizeISBNs rewrites the ISBNs stored on books, requests, loans and holds
+// in their normalized form. Two books in a library that turn out to be the same
+// title are merged: the copies of the later one move to the earlier one, which
+// keeps its details. With dryRun set nothing is saved, but the report still
+// says what would change.
+func NormalizeISBNs(db *gorm.DB, dryRun bool) (NormalizeReport, error) {
+	var report NormalizeReport
+	invalid := map[string]bool{}
+	normalize := func(raw string) (string, bool) {
+		normalized, err := isbn.Normalize(raw)
+		if err != nil {
+			if !invalid[raw] {
+				invalid[raw] = true
+				report.Invalid = append(report.Invalid, raw)
+			}
+			return "", false
+		}
+		return normalized, normalized != raw
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var books []models.Book
+		if err := tx.Order("id").Find(&books).Error; err != nil {
+			return err
+		}
+		for _, book := range books {
+			normalized, changed := normalize(book.ISBN)
+			if !changed {
+				continue
+			}
+			if err := normalizeBook(tx, book, normalized, &report); err != nil {
+				return err
+			}
+		}
+
+		for _, column := range []struct {
+			table, name string
+			count       *int
+		}{
+			{"request_events", "book_id", &report.Requests},
+			{"issue_registries", "isbn", &report.Loans},
+			{"holds", "isbn", &report.Holds},
+		} {
+			var values []string
+			if err := tx.Table(column.table).Distinct(column.name).Pluck(column.name, &values).Error; err != nil {
+				return err
+			}
+			for _, raw := range values {
+				normalized, changed := normalize(raw)
+				if !changed {
+					continue
+				}
+				result := tx.Table(column.table).Where(column.name+" = ?", raw).Update(column.name, normalized)
+				if result.Error != nil {
+					return result.Error
+				}
+				*column.count += int(result.RowsAffected)
+			}
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+	return report, err
+}
+
+// normalizeBook gives a book its normalized ISBN, merging it into the book that
+// already has that ISBN in the library if there is one
+func normalizeBook(tx *gorm.DB, book models.Book, normalized string, report *NormalizeReport) error {
+	var existing models.Book
+	err := tx.Where("isbn = ? AND library_id = ? AND id <> ?", normalized, book.LibraryID, book.ID).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		report.Books++
+		return tx.Model(&book).Update("isbn", normalized).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.Item{}).Where("book_id = ?", book.ID).Update("book_id", existing.ID).Error; err != nil {
+		return err
+	}
+	if err := recount(tx, &existing); err != nil {
+		return err
+	}
+	report.Merged++
+	return tx.Delete(&book).Error
+}
@@ -0,0 +1,137 @@
+// Package loans changes loans that are already out: renewing them extends the
+// due date within the library's policy and records every extension, and loans
+// past their due date are marked overdue.
+package loans
+
+import (
+	"errors"
+	"library-management/models"
+	"library-management/services/fines"
+	"library-management/services/policies"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrLoanNotFound   = errors.New("loan not found")
+	ErrLoanNotActive  = errors.New("loan has already been returned")
+	ErrRenewalLimit   = errors.New("loan has reached the maximum number of renewals")
+	ErrTitleRequested = errors.New("another reader is waiting for this title")
+)
+
+// RenewalsRemaining returns how many more times a loan may be renewed under
+// a policy
+func RenewalsRemaining(loan models.IssueRegistry, policy policies.Policy) int {
+	if remaining := policy.MaxRenewals - loan.RenewalCount; remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Renew extends a loan by one loan period and returns it with the number of
+// renewals left. When readerID is non-nil the loan must belong to that reader;
+// admins pass nil after checking library access. The new due date counts from
+// the later of today and the current due date, so renewing an overdue loan
+// still gives a full period; the fine it earned up to now is charged first.
+func Renew(db *gorm.DB, loanID uint, readerID *uint, renewedByID uint) (models.IssueRegistry, int, error) {
+	var loan models.IssueRegistry
+	var policy policies.Policy
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+		if readerID != nil {
+			query = query.Where("reader_id = ?", *readerID)
+		}
+		if err := query.First(&loan, loanID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrLoanNotFound
+			}
+			return err
+		}
+
+		if loan.IssueStatus == models.LoanReturned {
+			return ErrLoanNotActive
+		}
+		var err error
+		if policy, err = policies.ForLoan(tx, loan); err != nil {
+			return err
+		}
+		if RenewalsRemaining(loan, policy) == 0 {
+			return ErrRenewalLimit
+		}
+
+		waiting, err := othersWaiting(tx, loan)
+		if err != nil {
+			return err
+		}
+		if waiting {
+			return ErrTitleRequested
+		}
+
+		// Settle the fine earned so far; the renewal below closes this due
+		// date's period
+		now := time.Now()
+		if _, err := fines.Charge(tx, loan, now); err != nil {
+			return err
+		}
+
+		previousDue := loan.ExpectedReturnDate
+		from := time.Unix(previousDue, 0)
+		if now.After(from) {
+			from = now
+		}
+
+		loan.ExpectedReturnDate = from.AddDate(0, 0, policy.LoanPeriodDays).Unix()
+		loan.RenewalCount++
+		loan.IssueStatus = models.LoanIssued // No longer overdue
+		if err := tx.Save(&loan).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.LoanRenewal{
+			IssueID:         loan.ID,
+			RenewedByID:     renewedByID,
+			PreviousDueDate: previousDue,
+			NewDueDate:      loan.ExpectedReturnDate,
+			CreatedAt:       now,
+		}).Error
+	})
+	return loan, RenewalsRemaining(loan, policy), err
+}
+
+// MarkOverdue marks issued loans whose due date has passed as overdue and
+// returns how many were marked
+func MarkOverdue(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Model(&models.IssueRegistry{}).
+		Where("issue_status = ? AND expected_return_date < ?", models.LoanIssued, now.Unix()).
+		Update("issue_status", models.LoanOverdue)
+	return result.RowsAffected, result.Error
+}
+
+// othersWaiting reports whether another reader has a pending issue request or
+// hold for the same title in the loan's library. Requests ready for pickup
+// already have their copy.
+func othersWaiting(tx *gorm.DB, loan models.IssueRegistry) (bool, error) {
+	var requests int64
+	if err := tx.Model(&models.RequestEvent{}).
+		Where("book_id = ? AND library_id = ? AND reader_id <> ? AND request_type = ? AND status = ?",
+			loan.ISBN, loan.LibraryID, loan.ReaderID, "issue", models.RequestPending).
+		Count(&requests).Error; err != nil {
+		return false, err
+	}
+
+	var holds int64
+	err := tx.Model(&models.Hold{}).
+		Where("isbn = ? AND library_id = ? AND reader_id <> ? AND status IN ?",
+			loan.ISBN, loan.LibraryID, loan.ReaderID, models.OpenHoldStatuses).
+		Count(&holds).Error
+	return requests > 0 || holds > 0, err
+}
+
+// Renewals returns the renewal history of a loan, oldest first
+func Renewals(db *gorm.DB, loanID uint) ([]models.LoanRenewal, error) {
+	var renewals []models.LoanRenewal
+	err := db.Where("issue_id = ?", loanID).Order("id").Find(&renewals).Error
+	return renewals, err
+}
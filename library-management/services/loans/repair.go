@@ -0,0 +1,87 @@
+package loans
+
+import (
+	"errors"
+	"library-management/models"
+	"library-management/services/inventory"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrLoanNotFlagged = errors.New("loan does not need repair")
+	ErrItemNotOnLoan  = errors.New("item is not out on loan or belongs to another loan")
+)
+
+// NeedingRepair returns the active loans migration 14 could not give a library,
+// oldest first. With libraryIDs it returns only loans of ISBNs those libraries
+// hold or have held; owners pass nil to see them all.
+func NeedingRepair(db *gorm.DB, libraryIDs []uint) ([]models.IssueRegistry, error) {
+	query := db.Where("needs_repair = ? AND issue_status IN ?", true, models.ActiveLoanStatuses)
+	if libraryIDs != nil {
+		query = query.Where("isbn IN (?)", db.Unscoped().Model(&models.Book{}).Select("isbn").Where("library_id IN ?", libraryIDs))
+	}
+
+	var loans []models.IssueRegistry
+	err := query.Order("id").Find(&loans).Error
+	return loans, err
+}
+
+// Repair assigns a flagged loan to a library so its reader can return it. The
+// copy with the given barcode is linked to the loan; without a barcode the
+// first copy of the book there that is out on loan with no loan recording it
+// is linked, if there is one. The copy must already be out on loan, so the
+// library's counters do not change.
+func Repair(db *gorm.DB, loanID, libraryID uint, barcode string) (models.IssueRegistry, error) {
+	var loan models.IssueRegistry
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&loan, loanID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrLoanNotFound
+			}
+			return err
+		}
+		if !loan.NeedsRepair {
+			return ErrLoanNotFlagged
+		}
+		if loan.IssueStatus == models.LoanReturned {
+			return ErrLoanNotActive
+		}
+
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Joins("JOIN books ON books.id = items.book_id").
+			Where("books.isbn = ? AND items.library_id = ?", loan.ISBN, libraryID)
+
+		var item models.Item
+		if barcode != "" {
+			if err := query.Where("items.barcode = ?", barcode).First(&item).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return inventory.ErrItemNotFound
+				}
+				return err
+			}
+			var linked int64
+			if err := tx.Model(&models.IssueRegistry{}).Where("item_id = ?", item.ID).Count(&linked).Error; err != nil {
+				return err
+			}
+			if item.Status != models.ItemOnLoan || linked > 0 {
+				return ErrItemNotOnLoan
+			}
+		} else {
+			linked := tx.Model(&models.IssueRegistry{}).Select("item_id").Where("item_id IS NOT NULL")
+			err := query.Where("items.status = ? AND items.id NOT IN (?)", models.ItemOnLoan, linked).Order("items.id").First(&item).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+
+		loan.LibraryID = libraryID
+		loan.NeedsRepair = false
+		if item.ID != 0 {
+			loan.ItemID = &item.ID
+		}
+		return tx.Save(&loan).Error
+	})
+	return loan, err
+}
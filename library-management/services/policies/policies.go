@@ -0,0 +1,190 @@
+// Package policies resolves the circulation rules that apply to a loan. The
+// server configuration supplies defaults; each library may override them with
+// a base rule and with rules for a book category, a membership tier, or both.
+// More specific rules win field by field: base, then tier, then category,
+// then category and tier together.
+package policies
+
+import (
+	"errors"
+	"library-management/config"
+	"library-management/models"
+
+	"gorm.io/gorm"
+)
+
+var ErrPolicyNotFound = errors.New("policy rule not found")
+
+// Policy is the set of rules in force for one reader borrowing one book
+type Policy struct {
+	LoanPeriodDays int   `json:"loan_period_days"`
+	MaxLoans       int   `json:"max_loans"`
+	MaxRenewals    int   `json:"max_renewals"`
+	FinePerDay     int64 `json:"fine_per_day"`
+	MaxFine        int64 `json:"max_fine"`
+	MaxUnpaidFines int64 `json:"max_unpaid_fines"`
+	HoldPickupDays int   `json:"hold_pickup_days"`
+}
+
+// Defaults returns the policy from the server configuration
+func Defaults() Policy {
+	cfg := config.AppConfig
+	return Policy{
+		LoanPeriodDays: cfg.LoanPeriodDays,
+		MaxLoans:       cfg.MaxLoans,
+		MaxRenewals:    cfg.MaxRenewals,
+		FinePerDay:     cfg.FinePerDay,
+		MaxFine:        cfg.MaxFine,
+		MaxUnpaidFines: cfg.MaxUnpaidFines,
+		HoldPickupDays: cfg.HoldPickupDays,
+	}
+}
+
+// Resolve returns the policy for a book category and membership tier in a
+// library. Empty strings select the library's base rule only.
+func Resolve(db *gorm.DB, libraryID uint, category, tier string) (Policy, error) {
+	var rules []models.LibraryPolicy
+	if err := db.Where("library_id = ? AND category IN ? AND tier IN ?", libraryID, []string{"", category}, []string{"", tier}).
+		Find(&rules).Error; err != nil {
+		return Policy{}, err
+	}
+	return Match(rules, category, tier), nil
+}
+
+// Match resolves a policy from rules already loaded for one library, as
+// Resolve does. Rules for other categories and tiers are ignored.
+func Match(rules []models.LibraryPolicy, category, tier string) Policy {
+	policy := Defaults()
+	for _, rank := range []func(models.LibraryPolicy) bool{
+		func(r models.LibraryPolicy) bool { return r.Category == "" && r.Tier == "" },
+		func(r models.LibraryPolicy) bool { return r.Category == "" && r.Tier != "" },
+		func(r models.LibraryPolicy) bool { return r.Category != "" && r.Tier == "" },
+		func(r models.LibraryPolicy) bool { return r.Category != "" && r.Tier != "" },
+	} {
+		for _, rule := range rules {
+			if rank(rule) && (rule.Category == "" || rule.Category == category) && (rule.Tier == "" || rule.Tier == tier) {
+				policy.apply(rule)
+			}
+		}
+	}
+	return policy
+}
+
+// ForReader returns the policy for a reader borrowing a book
+func ForReader(db *gorm.DB, readerID uint, book models.Book) (Policy, error) {
+	tier, err := Tier(db, readerID, book.LibraryID)
+	if err != nil {
+		return Policy{}, err
+	}
+	return Resolve(db, book.LibraryID, book.Category, tier)
+}
+
+// ForLoan returns the policy for an existing loan. A book that has since been
+// withdrawn is treated as uncategorised.
+func ForLoan(db *gorm.DB, loan models.IssueRegistry) (Policy, error) {
+	var book models.Book
+	err := db.Unscoped().Where("isbn = ? AND library_id = ?", loan.ISBN, loan.LibraryID).Order("id DESC").
+		Limit(1).Find(&book).Error
+	if err != nil {
+		return Policy{}, err
+	}
+	book.LibraryID = loan.LibraryID
+	return ForReader(db, loan.ReaderID, book)
+}
+
+// Tier returns a reader's membership tier in a library, empty if they have none
+func Tier(db *gorm.DB, readerID, libraryID uint) (string, error) {
+	var tiers []string
+	err := db.Model(&models.UserLibrary{}).
+		Where("user_id = ? AND library_id = ?", readerID, libraryID).
+		Pluck("tier", &tiers).Error
+	if err != nil || len(tiers) == 0 {
+		return "", err
+	}
+	return tiers[0], nil
+}
+
+// Rules returns every rule of a library's policy, base rule first
+func Rules(db *gorm.DB, libraryID uint) ([]models.LibraryPolicy, error) {
+	var rules []models.LibraryPolicy
+	err := db.Where("library_id = ?", libraryID).Order("category, tier").Find(&rules).Error
+	return rules, err
+}
+
+// Save creates or replaces the rule for the given library, category and tier
+func Save(db *gorm.DB, rule models.LibraryPolicy) (models.LibraryPolicy, error) {
+	var existing models.LibraryPolicy
+	err := db.Where("library_id = ? AND category = ? AND tier = ?", rule.LibraryID, rule.Category, rule.Tier).
+		First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return rule, err
+	}
+
+	rule.ID = existing.ID
+	rule.CreatedAt = existing.CreatedAt
+	return rule, db.Save(&rule).Error
+}
+
+// Delete removes one rule from a library's policy
+func Delete(db *gorm.DB, libraryID, ruleID uint) error {
+	result := db.Where("library_id = ?", libraryID).Delete(&models.LibraryPolicy{}, ruleID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPolicyNotFound
+	}
+	return nil
+}
+
+// Check returns a description of every field of a rule that is out of range
+func Check(rule models.LibraryPolicy) []string {
+	var problems []string
+	if rule.LoanPeriodDays != nil && *rule.LoanPeriodDays <= 0 {
+		problems = append(problems, "loan_period_days must be positive")
+	}
+	if rule.MaxLoans != nil && *rule.MaxLoans < 0 {
+		problems = append(problems, "max_loans cannot be negative")
+	}
+	if rule.MaxRenewals != nil && *rule.MaxRenewals < 0 {
+		problems = append(problems, "max_renewals cannot be negative")
+	}
+	if rule.FinePerDay != nil && *rule.FinePerDay < 0 {
+		problems = append(problems, "fine_per_day cannot be negative")
+	}
+	if rule.MaxFine != nil && *rule.MaxFine < 0 {
+		problems = append(problems, "max_fine cannot be negative")
+	}
+	if rule.MaxUnpaidFines != nil && *rule.MaxUnpaidFines < 0 {
+		problems = append(problems, "max_unpaid_fines cannot be negative")
+	}
+	if rule.HoldPickupDays != nil && *rule.HoldPickupDays <= 0 {
+		problems = append(problems, "hold_pickup_days must be positive")
+	}
+	return problems
+}
+
+// apply copies the fields a rule sets over the policy
+func (p *Policy) apply(rule models.LibraryPolicy) {
+	if rule.LoanPeriodDays != nil {
+		p.LoanPeriodDays = *rule.LoanPeriodDays
+	}
+	if rule.MaxLoans != nil {
+		p.MaxLoans = *rule.MaxLoans
+	}
+	if rule.MaxRenewals != nil {
+		p.MaxRenewals = *rule.MaxRenewals
+	}
+	if rule.FinePerDay != nil {
+		p.FinePerDay = *rule.FinePerDay
+	}
+	if rule.MaxFine != nil {
+		p.MaxFine = *rule.MaxFine
+	}
+	if rule.MaxUnpaidFines != nil {
+		p.MaxUnpaidFines = *rule.MaxUnpaidFines
+	}
+	if rule.HoldPickupDays != nil {
+		p.HoldPickupDays = *rule.HoldPickupDays
+	}
+}
@@ -0,0 +1,119 @@
+package requests
+
+import (
+	"errors"
+	"fmt"
+	"library-management/models"
+	"library-management/services/holds"
+	"library-management/services/inventory"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Deadline returns when an open request expires, or nil if it never does.
+// Pending requests wait for approval and requests ready for pickup for the
+// reader to collect the book; a zero duration disables expiry for that stage.
+// A request set aside for a hold has the hold's pickup deadline.
+func Deadline(request models.RequestEvent, pendingFor, pickupFor time.Duration) *int64 {
+	var deadline int64
+	switch {
+	case request.Status == models.RequestReadyForPickup && request.PickupDeadline != nil:
+		return request.PickupDeadline
+	case request.Status == models.RequestPending && pendingFor > 0:
+		deadline = request.RequestDate + int64(pendingFor/time.Second)
+	case request.Status == models.RequestReadyForPickup && pickupFor > 0 && request.ApprovalDate != nil:
+		deadline = *request.ApprovalDate + int64(pickupFor/time.Second)
+	default:
+		return nil
+	}
+	return &deadline
+}
+
+// ExpireStale expires requests past their deadline and returns how many it
+// expired. A request ready for pickup that was never collected gives its copy
+// back to stock, where it goes to the next reader in the holds queue. Requests
+// set aside for a hold expire with the hold instead.
+func ExpireStale(db *gorm.DB, now time.Time, pendingFor, pickupFor time.Duration) (int, error) {
+	if pendingFor <= 0 && pickupFor <= 0 {
+		return 0, nil
+	}
+
+	var stale []models.RequestEvent
+	query := db.Where("1 = 0")
+	if pendingFor > 0 {
+		query = query.Or("status = ? AND request_date <= ?", models.RequestPending, now.Add(-pendingFor).Unix())
+	}
+	if pickupFor > 0 {
+		query = query.Or("status = ? AND approval_date <= ? AND pickup_deadline IS NULL", models.RequestReadyForPickup, now.Add(-pickupFor).Unix())
+	}
+	if err := db.Where(query).Order("id").Find(&stale).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range stale {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return expire(tx, &stale[i], now, pendingFor, pickupFor)
+		})
+		if errors.Is(err, errNotStale) {
+			continue // Approved, collected or cancelled since it was listed
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+var errNotStale = errors.New("request is no longer stale")
+
+func expire(tx *gorm.DB, request *models.RequestEvent, now time.Time, pendingFor, pickupFor time.Duration) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(request, request.ID).Error; err != nil {
+		return err
+	}
+	deadline := Deadline(*request, pendingFor, pickupFor)
+	if deadline == nil || *deadline > now.Unix() {
+		return errNotStale
+	}
+
+	reason := fmt.Sprintf("Not approved within %s", formatWait(pendingFor))
+	reserved := request.Status == models.RequestReadyForPickup
+	if reserved {
+		reason = fmt.Sprintf("Not collected within %s of approval", formatWait(pickupFor))
+	}
+
+	at := now.Unix()
+	request.ExpiryReason = reason
+	request.ExpiredAt = &at
+	if err := Transition(tx, request, models.RequestExpired, nil, reason); err != nil {
+		return err
+	}
+	if !reserved || request.ItemID == nil {
+		return nil
+	}
+
+	// A title withdrawn from the library has no stock left to return to
+	if _, err := inventory.Release(tx, *request.ItemID); err != nil {
+		if errors.Is(err, inventory.ErrBookNotFound) {
+			return nil
+		}
+		return err
+	}
+	_, err := holds.Allocate(tx, request.BookID, request.LibraryID)
+	return err
+}
+
+// formatWait describes a duration in days when it is a whole number of them
+func formatWait(d time.Duration) string {
+	day := 24 * time.Hour
+	if d%day == 0 {
+		if d == day {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", d/day)
+	}
+	return d.String()
+}
@@ -0,0 +1,67 @@
+package requests
+
+import (
+	"library-management/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PickupStats counts what became of issue requests approved for pickup in a
+// library
+type PickupStats struct {
+	Approved    int64 `json:"approved"`    // Requests set aside for pickup
+	Collected   int64 `json:"collected"`   // Checked out at the desk
+	Uncollected int64 `json:"uncollected"` // Expired before the reader came
+	Awaiting    int64 `json:"awaiting"`    // Still ready for pickup
+}
+
+// Pickups counts requests approved for pickup in a library since the given
+// time (all time when zero) and how they ended
+func Pickups(db *gorm.DB, libraryID uint, since time.Time) (PickupStats, error) {
+	var rows []struct {
+		FromStatus string
+		ToStatus   string
+		Count      int64
+	}
+	query := db.Model(&models.RequestTransition{}).
+		Select("request_transitions.from_status, request_transitions.to_status, COUNT(*) AS count").
+		Joins("JOIN request_events ON request_events.id = request_transitions.request_id").
+		Where("request_events.library_id = ?", libraryID).
+		Where("request_transitions.to_status = ? OR request_transitions.from_status = ?", models.RequestReadyForPickup, models.RequestReadyForPickup)
+	if !since.IsZero() {
+		query = query.Where("request_events.approval_date >= ?", since.Unix())
+	}
+	if err := query.Group("request_transitions.from_status, request_transitions.to_status").Scan(&rows).Error; err != nil {
+		return PickupStats{}, err
+	}
+
+	var stats PickupStats
+	for _, row := range rows {
+		switch {
+		case row.ToStatus == models.RequestReadyForPickup:
+			stats.Approved += row.Count
+		case row.ToStatus == models.RequestFulfilled:
+			stats.Collected += row.Count
+		case row.ToStatus == models.RequestExpired:
+			stats.Uncollected += row.Count
+		}
+	}
+	stats.Awaiting = stats.Approved - stats.Collected - stats.Uncollected
+	return stats, nil
+}
+
+// Uncollected lists a library's requests that expired while ready for pickup,
+// most recent first. Like Pickups it goes by the transition history, so
+// approvals the status backfill expired are left out.
+func Uncollected(db *gorm.DB, libraryID uint, since time.Time) ([]models.RequestEvent, error) {
+	var expired []models.RequestEvent
+	expiredFromPickup := db.Model(&models.RequestTransition{}).Select("request_id").
+		Where("from_status = ? AND to_status = ?", models.RequestReadyForPickup, models.RequestExpired)
+	query := db.Where("library_id = ? AND status = ? AND id IN (?)", libraryID, models.RequestExpired, expiredFromPickup)
+	if !since.IsZero() {
+		query = query.Where("approval_date >= ?", since.Unix())
+	}
+	err := query.Order("expired_at DESC").Find(&expired).Error
+	return expired, err
+}
@@ -0,0 +1,100 @@
+// Package requests owns the lifecycle of issue and return requests. Every
+// status change goes through Transition, which enforces the allowed moves and
+// records who made them in request_transitions.
+package requests
+
+import (
+	"errors"
+	"fmt"
+	"library-management/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTransition is matched by every TransitionError
+var ErrInvalidTransition = errors.New("invalid request status transition")
+
+// TransitionError reports a status change the state machine does not allow
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("request is %s and cannot be %s", e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+// transitions lists the statuses each status may move to
+var transitions = map[string][]string{
+	models.RequestPending:        {models.RequestApproved, models.RequestRejected, models.RequestCancelled, models.RequestExpired},
+	models.RequestApproved:       {models.RequestReadyForPickup, models.RequestFulfilled},
+	models.RequestReadyForPickup: {models.RequestFulfilled, models.RequestExpired, models.RequestCancelled},
+}
+
+// CanTransition reports whether a request may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStatus reports whether status is a known request status
+func IsStatus(status string) bool {
+	for _, known := range models.RequestStatuses {
+		if known == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Create stores a new pending request and the first entry of its history
+func Create(db *gorm.DB, request *models.RequestEvent, actorID *uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		request.Status = models.RequestPending
+		if err := tx.Create(request).Error; err != nil {
+			return err
+		}
+		return record(tx, request.ID, "", models.RequestPending, actorID, "")
+	})
+}
+
+// Transition moves a request to a new status, saving any other changes the
+// caller made to it. actorID is nil when a background job makes the change.
+func Transition(tx *gorm.DB, request *models.RequestEvent, to string, actorID *uint, reason string) error {
+	from := request.Status
+	if !CanTransition(from, to) {
+		return &TransitionError{From: from, To: to}
+	}
+
+	request.Status = to
+	if err := tx.Save(request).Error; err != nil {
+		request.Status = from
+		return err
+	}
+	return record(tx, request.ID, from, to, actorID, reason)
+}
+
+// History returns a request's transitions, oldest first
+func History(db *gorm.DB, requestID uint) ([]models.RequestTransition, error) {
+	var history []models.RequestTransition
+	err := db.Where("request_id = ?", requestID).Order("id").Find(&history).Error
+	return history, err
+}
+
+func record(tx *gorm.DB, requestID uint, from, to string, actorID *uint, reason string) error {
+	return tx.Create(&models.RequestTransition{
+		RequestID:  requestID,
+		FromStatus: from,
+		ToStatus:   to,
+		ActorID:    actorID,
+		Reason:     reason,
+	}).Error
+}
@@ -0,0 +1,176 @@
+// Package search finds books across a reader's libraries. The free-text query
+// is matched against title, authors, category (the subject) and publisher and
+// ranked by relevance, title matches first. On Postgres this uses the weighted
+// search_vector column and its GIN index; other databases fall back to LIKE
+// matching with the same weights, which is slower but ranks alike.
+package search
+
+import (
+	"errors"
+	"library-management/models"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Sort orders
+const (
+	Relevance = "relevance" // Best match first; by title when there is no free-text query
+	Title     = "title"
+	Newest    = "newest"    // Most recently added first
+	Available = "available" // Most copies on the shelf first
+)
+
+// Sorts lists the sort orders
+var Sorts = []string{Relevance, Title, Newest, Available}
+
+// Page size limits
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	ErrUnknownSort  = errors.New("unknown sort order")
+	ErrInvalidLimit = errors.New("limit must be between 1 and 100")
+	ErrInvalidPage  = errors.New("offset must not be negative")
+)
+
+// textSearchConfig is the Postgres text search configuration search_vector is built with
+const textSearchConfig = "english"
+
+// Query describes one search. Text is the free-text query; the field filters
+// each narrow the result to books whose field contains the value.
+type Query struct {
+	Text      string
+	Title     string
+	Author    string
+	Publisher string
+	Category  string
+	Libraries []uint
+	Sort      string // Relevance when empty
+	Limit     int    // DefaultLimit when zero
+	Offset    int
+}
+
+// Result is one page of matches and how many books match in all
+type Result struct {
+	Books []models.Book
+	Total int64
+	Limit int // The page size applied
+}
+
+// Books runs a search and returns the requested page
+func Books(db *gorm.DB, q Query) (Result, error) {
+	if q.Sort == "" {
+		q.Sort = Relevance
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultLimit
+	}
+	if !validSort(q.Sort) {
+		return Result{}, ErrUnknownSort
+	}
+	if q.Limit < 1 || q.Limit > MaxLimit {
+		return Result{}, ErrInvalidLimit
+	}
+	if q.Offset < 0 {
+		return Result{}, ErrInvalidPage
+	}
+
+	query := db.Model(&models.Book{}).Where("library_id IN ?", q.Libraries)
+
+	// LOWER(...) LIKE keeps the field filters case-insensitive on every supported database
+	for _, filter := range []struct{ column, value string }{
+		{"title", q.Title}, {"authors", q.Author}, {"publisher", q.Publisher}, {"category", q.Category},
+	} {
+		if value := strings.TrimSpace(filter.value); value != "" {
+			query = query.Where("LOWER("+filter.column+")"+likeContains, containsPattern(value))
+		}
+	}
+
+	var rank clause.Expr
+	text := strings.TrimSpace(q.Text)
+	if text != "" {
+		if db.Dialector.Name() == "postgres" {
+			tsquery := clause.Expr{SQL: "websearch_to_tsquery(?, ?)", Vars: []interface{}{textSearchConfig, text}}
+			query = query.Where("search_vector @@ ?", tsquery)
+			rank = clause.Expr{SQL: "ts_rank(search_vector, ?)", Vars: []interface{}{tsquery}}
+		} else {
+			query, rank = likeMatch(query, text)
+		}
+	}
+	query = query.Session(&gorm.Session{})
+
+	result := Result{Limit: q.Limit}
+	if err := query.Count(&result.Total).Error; err != nil {
+		return result, err
+	}
+
+	page := query.Select("id, isbn, title, authors, publisher, category, total_copies, available_copies, library_id, created_at")
+	switch {
+	case q.Sort == Relevance && text != "":
+		page = page.Order(clause.OrderBy{Expression: clause.Expr{SQL: "? DESC, title, id", Vars: []interface{}{rank}}})
+	case q.Sort == Newest:
+		page = page.Order("id DESC")
+	case q.Sort == Available:
+		page = page.Order("available_copies DESC, title, id")
+	default:
+		page = page.Order("title, id")
+	}
+	err := page.Limit(q.Limit).Offset(q.Offset).Find(&result.Books).Error
+	return result, err
+}
+
+// likeWeights score a term found in each column, mirroring the search_vector weights
+var likeWeights = []struct {
+	column string
+	weight int
+}{
+	{"title", 8},
+	{"authors", 4},
+	{"category", 2},
+	{"publisher", 1},
+}
+
+// likeMatch requires every word of the text to appear in one of the searched
+// columns and scores each book by where its words were found
+func likeMatch(query *gorm.DB, text string) (*gorm.DB, clause.Expr) {
+	var scores []string
+	var vars []interface{}
+	for _, term := range strings.Fields(text) {
+		pattern := containsPattern(term)
+		var anyColumn []string
+		var termVars []interface{}
+		for _, w := range likeWeights {
+			anyColumn = append(anyColumn, "LOWER("+w.column+")"+likeContains)
+			termVars = append(termVars, pattern)
+			scores = append(scores, "CASE WHEN LOWER("+w.column+")"+likeContains+" THEN "+strconv.Itoa(w.weight)+" ELSE 0 END")
+			vars = append(vars, pattern)
+		}
+		query = query.Where("("+strings.Join(anyColumn, " OR ")+")", termVars...)
+	}
+	return query, clause.Expr{SQL: "(" + strings.Join(scores, " + ") + ")", Vars: vars}
+}
+
+// likeContains matches a column against a containsPattern
+const likeContains = ` LIKE ? ESCAPE '\'`
+
+// likeEscaper escapes LIKE wildcards so they match themselves
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// containsPattern builds a LIKE pattern matching value anywhere in a lowercased column
+func containsPattern(value string) string {
+	return "%" + likeEscaper.Replace(strings.ToLower(value)) + "%"
+}
+
+func validSort(sort string) bool {
+	for _, known := range Sorts {
+		if known == sort {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+// Package tokens issues access/refresh token pairs, rotates refresh tokens and
+// keeps the server-side revocation list checked by AuthMiddleware.
+package tokens
+
+import (
+	"errors"
+	"library-management/config"
+	"library-management/models"
+	"library-management/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// Pair is what a client receives after login or refresh
+type Pair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // Access token lifetime in seconds
+}
+
+// Issue starts a new refresh token family for a freshly authenticated user
+func Issue(db *gorm.DB, user models.User) (Pair, error) {
+	familyID, err := utils.RandomToken(16)
+	if err != nil {
+		return Pair{}, err
+	}
+	return issueInFamily(db, user, familyID)
+}
+
+func issueInFamily(tx *gorm.DB, user models.User, familyID string) (Pair, error) {
+	// Library memberships are read on every issue so a refresh picks up changes
+	var libraryIDs []uint
+	if err := tx.Model(&models.UserLibrary{}).Where("user_id = ?", user.ID).Order("library_id").Pluck("library_id", &libraryIDs).Error; err != nil {
+		return Pair{}, err
+	}
+
+	accessToken, claims, err := utils.GenerateAccessToken(user.ID, user.Role, libraryIDs)
+	if err != nil {
+		return Pair{}, err
+	}
+
+	refreshToken, err := utils.RandomToken(32)
+	if err != nil {
+		return Pair{}, err
+	}
+
+	record := models.RefreshToken{
+		UserID:          user.ID,
+		FamilyID:        familyID,
+		TokenHash:       utils.HashToken(refreshToken),
+		AccessJTI:       claims.JTI,
+		AccessExpiresAt: claims.ExpiresAt,
+		ExpiresAt:       time.Now().Add(config.AppConfig.RefreshTTL),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return Pair{}, err
+	}
+
+	return Pair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(time.Until(claims.ExpiresAt).Seconds()),
+	}, nil
+}
+
+// Rotate exchanges a refresh token for a new pair. Each refresh token is good
+// for one exchange; presenting it again means it leaked, so the whole family
+// and the access tokens issued from it are revoked.
+func Rotate(db *gorm.DB, refreshToken string) (Pair, error) {
+	var pair Pair
+	reused := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", utils.HashToken(refreshToken)).
+			First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if current.UsedAt != nil || current.RevokedAt != nil {
+			reused = true
+			return revokeFamily(tx, current.FamilyID)
+		}
+
+		now := time.Now()
+		if now.After(current.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		var user models.User
+		if err := tx.First(&user, current.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		var err error
+		pair, err = issueInFamily(tx, user, current.FamilyID)
+		return err
+	})
+	if err != nil {
+		return Pair{}, err
+	}
+	if reused {
+		return Pair{}, ErrRefreshTokenReused
+	}
+	return pair, nil
+}
+
+// Logout revokes an access token and, when given, the refresh token family it
+// was issued with. Refresh tokens belonging to another user are ignored.
+func Logout(db *gorm.DB, claims utils.TokenClaims, refreshToken string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := revokeAccessToken(tx, claims.JTI, claims.UserID, claims.ExpiresAt); err != nil {
+			return err
+		}
+		if refreshToken == "" {
+			return nil
+		}
+
+		var current models.RefreshToken
+		err := tx.Where("token_hash = ? AND user_id = ?", utils.HashToken(refreshToken), claims.UserID).First(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return revokeFamily(tx, current.FamilyID)
+	})
+}
+
+// revokeFamily revokes every refresh token in a family along with the access
+// tokens that are still valid
+func revokeFamily(tx *gorm.DB, familyID string) error {
+	now := time.Now()
+
+	var members []models.RefreshToken
+	if err := tx.Where("family_id = ?", familyID).Find(&members).Error; err != nil {
+		return err
+	}
+	for _, member := range members {
+		if member.AccessJTI != "" && member.AccessExpiresAt.After(now) {
+			if err := revokeAccessToken(tx, member.AccessJTI, member.UserID, member.AccessExpiresAt); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
+
+func revokeAccessToken(tx *gorm.DB, jti string, userID uint, expiresAt time.Time) error {
+	if jti == "" {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.RevokedToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt}).Error
+}
+
+// IsRevoked reports whether an access token has been revoked
+func IsRevoked(db *gorm.DB, jti string) (bool, error) {
+	var count int64
+	err := db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// PurgeExpired deletes refresh tokens and revocation entries that have expired
+// and can no longer be used. It returns the number of rows removed.
+func PurgeExpired(db *gorm.DB, now time.Time) (int64, error) {
+	revoked := db.Where("expires_at < ?", now).Delete(&models.RevokedToken{})
+	if revoked.Error != nil {
+		return 0, revoked.Error
+	}
+	refresh := db.Where("expires_at < ?", now).Delete(&models.RefreshToken{})
+	return revoked.RowsAffected + refresh.RowsAffected, refresh.Error
+}
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"errors"
+	"library-management/controllers"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// ✅ Test ApproveIssue sets a copy aside and leaves the request ready for pickup
+func TestApproveIssueReservesCopy(t *testing.T) {
+	SetupTestDatabase()
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.PUT("/issue/approve/:id", withUser(1, "admin", 1), controllers.ApproveIssue(TestDB))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "request_events" WHERE "request_events"."id" = \$1 .* FOR UPDATE`).
+		WithArgs("4", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "library_id", "reader_id", "request_type", "status"}).
+			AddRow(4, "12345", 1, 2, "issue", "pending"))
+	expectTransition()
+	expectBookLock(1, 1)
+	expectLoanPolicy()
+	expectNoHolds()
+	expectEligible()
+	mock.ExpectExec(`SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectBookLock(1, 1)
+	mock.ExpectQuery(`SELECT \* FROM "items" WHERE book_id = \$1 AND status = \$2 .* FOR UPDATE`).
+		WithArgs(5, "available", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "barcode", "book_id", "library_id", "status"}).
+			AddRow(9, "1-12345-001", 5, 1, "available"))
+	mock.ExpectExec(`UPDATE "items" SET "status"=\$1`).WithArgs("reserved", sqlmock.AnyArg(), 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRecount("reserved", 1)
+	expectTransition()
+	mock.ExpectCommit()
+
+	req, _ := http.NewRequest(http.MethodPut, "/issue/approve/4", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "ready for pickup")
+	assert.Contains(t, w.Body.String(), `"pickup_deadline"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// ❌ Test ApproveIssue rolls back when the last copy is already gone
+func TestApproveIssueNoCopies(t *testing.T) {
+	SetupTestDatabase()
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.PUT("/issue/approve/:id", withUser(1, "admin", 1), controllers.ApproveIssue(TestDB))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "request_events"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "library_id", "reader_id", "request_type", "status"}).
+			AddRow(4, "12345", 1, 2, "issue", "pending"))
+	expectTransition()
+	expectBookLock(1, 0)
+	expectLoanPolicy()
+	expectNoHolds()
+	expectEligible()
+	mock.ExpectExec(`SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectBookLock(1, 0)
+	mock.ExpectQuery(`SELECT \* FROM "items"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	req, _ := http.NewRequest(http.MethodPut, "/issue/approve/4", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "No available copies to issue")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// ❌ Test ApproveIssue reports a failed lookup as a server error, not a missing request
+func TestApproveIssueLookupFails(t *testing.T) {
+	SetupTestDatabase()
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.PUT("/issue/approve/:id", withUser(1, "admin", 1), controllers.ApproveIssue(TestDB))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "request_events"`).WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	req, _ := http.NewRequest(http.MethodPut, "/issue/approve/4", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "Issue request not found")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectTransition expects a request status update and its history row
+func expectTransition() {
+	mock.ExpectExec(`UPDATE "request_events" SET .*"status"=`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "request_transitions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+}
+
+// expectBookLock expects book 12345 in library 1 to be locked with the given counters
+func expectBookLock(total, available int) {
+	mock.ExpectQuery(`SELECT \* FROM "books" WHERE \(isbn = \$1 AND library_id = \$2\) .* FOR UPDATE`).
+		WithArgs("12345", 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "total_copies", "available_copies"}).
+			AddRow(5, "12345", 1, total, available))
+}
+
+// expectRecount expects book 5's counters to be recounted from its items, all
+// of them in one status
+func expectRecount(status string, count int) {
+	mock.ExpectQuery(`SELECT status, COUNT\(\*\) AS count FROM "items" WHERE book_id = \$1 GROUP BY "status"`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "count"}).AddRow(status, count))
+	mock.ExpectExec(`UPDATE "books" SET .*"available_copies"=\$`).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// expectLoanPolicy expects the reader's policy lookup, with no library rules
+func expectLoanPolicy() {
+	mock.ExpectQuery(`SELECT "tier" FROM "user_libraries"`).WillReturnRows(sqlmock.NewRows([]string{"tier"}).AddRow(""))
+	mock.ExpectQuery(`SELECT \* FROM "library_policies"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+// expectEligible expects an eligibility check for an active reader with no
+// loans, copies set aside or fines
+func expectEligible() {
+	mock.ExpectQuery(`SELECT id, status FROM "users"`).WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(2, "active"))
+	mock.ExpectQuery(`SELECT id, issue_status, expected_return_date FROM "issue_registries"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "issue_status", "expected_return_date"}))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "request_events"`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "holds"`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`FROM "fine_entries"`).WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(0))
+}
+
+// expectNoHolds expects a holds queue lookup that finds nobody waiting
+func expectNoHolds() {
+	mock.ExpectQuery(`SELECT \* FROM "holds" .* FOR UPDATE`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
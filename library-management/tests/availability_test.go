@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"library-management/config"
+	"library-management/controllers"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/availability"
+	"library-management/services/inventory"
+	"library-management/services/policies"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// ✅ Test the forecast follows this library's loans and the queue ahead of the reader
+func TestAvailabilityForecast(t *testing.T) {
+	f := newCirculationFixture(t, 2)
+	now := time.Now()
+
+	soon := f.createLoan(t, now.AddDate(0, 0, 2))
+	f.createLoan(t, now.AddDate(0, 0, 5))
+
+	// A returned loan and a loan from another branch must not count
+	require.NoError(t, f.db.Create(&models.IssueRegistry{ISBN: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID,
+		IssueStatus: models.LoanReturned, ExpectedReturnDate: now.Add(time.Hour).Unix()}).Error)
+	other := models.Library{Name: "Branch"}
+	require.NoError(t, f.db.Create(&other).Error)
+	require.NoError(t, f.db.Create(&models.IssueRegistry{ISBN: f.book.ISBN, LibraryID: other.ID, ReaderID: f.reader.ID,
+		IssueStatus: models.LoanIssued, ExpectedReturnDate: now.Add(time.Hour).Unix()}).Error)
+
+	first := f.createReader(t, "first")
+	second := f.createReader(t, "second")
+	third := f.createReader(t, "third")
+	for _, reader := range []models.User{first, second, third} {
+		require.NoError(t, f.db.Create(&models.Hold{ISBN: f.book.ISBN, LibraryID: f.library.ID, ReaderID: reader.ID, Status: models.HoldWaiting}).Error)
+	}
+
+	forecast, err := availability.For(f.db, f.book.ISBN, f.library.ID, second.ID, now)
+	require.NoError(t, err)
+	assert.Equal(t, availability.Waiting, forecast.Status)
+	assert.Equal(t, 2, forecast.OnLoan)
+	assert.Equal(t, 3, forecast.QueueLength)
+	assert.Equal(t, 2, forecast.Position)
+	require.NotNil(t, forecast.EstimatedDate)
+	assert.Equal(t, now.AddDate(0, 0, 5).Unix(), forecast.EstimatedDate.Unix())
+
+	// The third reader waits for the first copy to come round again
+	forecast, err = availability.For(f.db, f.book.ISBN, f.library.ID, third.ID, now)
+	require.NoError(t, err)
+	assert.Equal(t, 3, forecast.Position)
+	loanPeriod := time.Duration(config.AppConfig.LoanPeriodDays) * 24 * time.Hour
+	assert.Equal(t, time.Unix(soon.ExpectedReturnDate, 0).Add(loanPeriod).Unix(), forecast.EstimatedDate.Unix())
+
+	// A reader not yet queued is told the place they would take
+	path := fmt.Sprintf("/books/%s/availability?library_id=%d", f.book.ISBN, f.library.ID)
+	w := f.serve(t, f.reader, http.MethodGet, "/books/:isbn/availability", path, "",
+		middleware.RequireLibraryRole("user", middleware.LibraryFromQuery("library_id")), controllers.BookAvailability(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response availability.Forecast
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 4, response.Position)
+	assert.Equal(t, time.Unix(soon.ExpectedReturnDate, 0).Add(loanPeriod).Add(3*24*time.Hour).Unix(), response.EstimatedDate.Unix())
+
+	// Search reports the same estimate instead of the returned loan's date
+	w = f.serve(t, f.reader, http.MethodGet, "/books/search", "/books/search?title=effective", "", controllers.SearchBooks(f.db))
+	require.Equal(t, http.StatusOK, w.Code)
+	var search struct {
+		Books []map[string]interface{} `json:"books"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &search))
+	require.Len(t, search.Books, 1)
+	assert.Equal(t, response.EstimatedDate.Local().Format("2006-01-02 15:04:05"), search.Books[0]["next_available_date"])
+}
+
+// ✅ Test a reader with a copy set aside is told to collect it
+func TestAvailabilityReadyHold(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	deadline := time.Now().AddDate(0, 0, 2).Unix()
+	_, item, err := inventory.Reserve(f.db, f.book.ISBN, f.library.ID)
+	require.NoError(t, err)
+	require.NoError(t, f.db.Create(&models.Hold{ISBN: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID,
+		ItemID: &item.ID, Status: models.HoldReady, PickupDeadline: &deadline}).Error)
+
+	forecast, err := availability.For(f.db, f.book.ISBN, f.library.ID, f.reader.ID, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, availability.Ready, forecast.Status)
+	require.NotNil(t, forecast.PickupDeadline)
+	assert.Equal(t, deadline, forecast.PickupDeadline.Unix())
+
+	_, err = availability.For(f.db, "0000000000", f.library.ID, f.reader.ID, time.Now())
+	assert.Error(t, err)
+}
+
+// ✅ Test forecasts for a page of books cost the same queries as one and keep each book's queue and policy
+func TestAvailabilityForBooks(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	now := time.Now()
+	f.createLoan(t, now.AddDate(0, 0, 3))
+	require.NoError(t, f.db.First(&f.book, f.book.ID).Error)
+
+	novel := models.Book{ISBN: "9780306406157", Title: "A Novel", Category: "Fiction", LibraryID: f.library.ID}
+	require.NoError(t, f.db.Create(&novel).Error)
+	require.NoError(t, f.db.Create(&models.IssueRegistry{ISBN: novel.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID,
+		IssueStatus: models.LoanIssued, ExpectedReturnDate: now.AddDate(0, 0, 10).Unix()}).Error)
+	first := f.createReader(t, "first")
+	require.NoError(t, f.db.Create(&models.Hold{ISBN: novel.ISBN, LibraryID: f.library.ID, ReaderID: first.ID, Status: models.HoldWaiting}).Error)
+	_, err := policies.Save(f.db, models.LibraryPolicy{LibraryID: f.library.ID, Category: "Fiction", LoanPeriodDays: intPtr(7)})
+	require.NoError(t, err)
+
+	queries := 0
+	require.NoError(t, f.db.Callback().Query().After("gorm:query").Register("test:count_queries", func(*gorm.DB) { queries++ }))
+	_, err = availability.ForBooks(f.db, []models.Book{f.book}, f.reader.ID, now)
+	require.NoError(t, err)
+	single := queries
+
+	queries = 0
+	forecasts, err := availability.ForBooks(f.db, []models.Book{f.book, novel}, f.reader.ID, now)
+	require.NoError(t, err)
+	assert.Equal(t, single, queries)
+
+	book := forecasts[f.book.ID]
+	assert.Equal(t, 1, book.Position)
+	assert.Equal(t, 1, book.OnLoan)
+	assert.Equal(t, now.AddDate(0, 0, 3).Unix(), book.EstimatedDate.Unix())
+
+	// The novel's copy reaches this reader after one fiction loan to the reader ahead
+	fiction := forecasts[novel.ID]
+	assert.Equal(t, 2, fiction.Position)
+	assert.Equal(t, 1, fiction.QueueLength)
+	assert.Equal(t, now.AddDate(0, 0, 17).Unix(), fiction.EstimatedDate.Unix())
+}
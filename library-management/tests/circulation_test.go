@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"library-management/controllers"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/inventory"
+	"library-management/services/requests"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// circulationFixture seeds one library with an admin, a reader and a book.
+// Helpers that several test files need are methods on it, kept in this file.
+type circulationFixture struct {
+	db      *gorm.DB
+	library models.Library
+	admin   models.User
+	reader  models.User
+	book    models.Book
+}
+
+func newCirculationFixture(t *testing.T, copies int) circulationFixture {
+	db := SetupSQLiteDatabase(t)
+	f := circulationFixture{db: db}
+
+	f.library = models.Library{Name: "Central"}
+	require.NoError(t, db.Create(&f.library).Error)
+
+	f.admin = models.User{Name: "Admin", Email: "admin@example.com", Role: "admin", Password: "x"}
+	f.reader = models.User{Name: "Reader", Email: "reader@example.com", Role: "user", Password: "x"}
+	require.NoError(t, db.Create(&f.admin).Error)
+	require.NoError(t, db.Create(&f.reader).Error)
+	require.NoError(t, db.Create(&models.UserLibrary{UserID: f.admin.ID, LibraryID: f.library.ID}).Error)
+	require.NoError(t, db.Create(&models.UserLibrary{UserID: f.reader.ID, LibraryID: f.library.ID}).Error)
+
+	f.book = models.Book{ISBN: "9780134685991", Title: "Effective Java", Authors: "Joshua Bloch", Publisher: "Addison-Wesley",
+		LibraryID: f.library.ID}
+	if copies == 0 {
+		require.NoError(t, db.Create(&f.book).Error)
+		return f
+	}
+	book, _, err := inventory.AddCopies(db, f.book, make([]inventory.NewItem, copies))
+	require.NoError(t, err)
+	f.book = book
+	return f
+}
+
+// serve runs one request against a handler chain with the caller's identity on the context
+func (f circulationFixture) serve(t *testing.T, user models.User, method, route, path, body string, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Handle(method, route, append([]gin.HandlerFunc{withUser(user.ID, user.Role, f.library.ID)}, handlers...)...)
+
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// availableCopies reloads the fixture book's count of copies on the shelf
+func (f circulationFixture) availableCopies(t *testing.T) int {
+	var book models.Book
+	require.NoError(t, f.db.First(&book, f.book.ID).Error)
+	return book.AvailableCopies
+}
+
+// createReader registers another reader at the fixture library
+func (f circulationFixture) createReader(t *testing.T, name string) models.User {
+	reader := models.User{Name: name, Email: name + "@example.com", Role: "user", Password: "x"}
+	require.NoError(t, f.db.Create(&reader).Error)
+	require.NoError(t, f.db.Create(&models.UserLibrary{UserID: reader.ID, LibraryID: f.library.ID}).Error)
+	return reader
+}
+
+// createLoan lends a copy of the fixture book to the fixture reader, due at due
+func (f circulationFixture) createLoan(t *testing.T, due time.Time) models.IssueRegistry {
+	_, item, err := inventory.Reserve(f.db, f.book.ISBN, f.library.ID)
+	require.NoError(t, err)
+	_, err = inventory.Lend(f.db, item.ID)
+	require.NoError(t, err)
+	loan := models.IssueRegistry{ISBN: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID, IssueApproverID: f.admin.ID,
+		ItemID: &item.ID, IssueStatus: "issued", IssueDate: due.AddDate(0, 0, -14).Unix(), ExpectedReturnDate: due.Unix()}
+	require.NoError(t, f.db.Create(&loan).Error)
+	return loan
+}
+
+// createRequest files a pending issue request for the fixture book
+func (f circulationFixture) createRequest(t *testing.T, reader models.User) models.RequestEvent {
+	request := models.RequestEvent{BookID: f.book.ISBN, LibraryID: f.library.ID, ReaderID: reader.ID, RequestDate: time.Now().Unix(), RequestType: "issue"}
+	require.NoError(t, requests.Create(f.db, &request, &reader.ID))
+	return request
+}
+
+// renewMine renews one of user's loans through the reader endpoint and returns the status
+func (f circulationFixture) renewMine(t *testing.T, user models.User, loanID uint) int {
+	path := fmt.Sprintf("/me/loans/%d/renew", loanID)
+	return f.serve(t, user, http.MethodPost, "/me/loans/:id/renew", path, "", controllers.RenewMyLoan(f.db)).Code
+}
+
+// hold returns the reader's latest hold
+func (f circulationFixture) hold(t *testing.T, reader models.User) models.Hold {
+	var hold models.Hold
+	require.NoError(t, f.db.Where("reader_id = ?", reader.ID).Order("id DESC").First(&hold).Error)
+	return hold
+}
+
+// checkout hands over the copy set aside for an approved request
+func (f circulationFixture) checkout(t *testing.T, requestID uint) *httptest.ResponseRecorder {
+	return f.serve(t, f.admin, http.MethodPut, "/issue/checkout/:id", fmt.Sprintf("/issue/checkout/%d", requestID), "", controllers.CheckoutIssue(f.db))
+}
+
+// ✅ Test SearchBooks matches case-insensitively on SQLite
+func TestSearchBooksCaseInsensitive(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+
+	w := f.serve(t, f.reader, http.MethodGet, "/books/search", "/books/search?title=effective&author=BLOCH", "", controllers.SearchBooks(f.db))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Books []map[string]interface{} `json:"books"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Books, 1)
+	assert.Equal(t, "Effective Java", response.Books[0]["title"])
+}
+
+// ✅ Test a full request, approve, checkout, return cycle restores the copy count
+func TestIssueAndReturnCycle(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	issueBody := fmt.Sprintf(`{"isbn": "%s", "libraryid": %d}`, f.book.ISBN, f.library.ID)
+
+	w := f.serve(t, f.reader, http.MethodPost, "/issue", "/issue", issueBody,
+		middleware.RequireLibraryRole("user", middleware.LibraryFromBody("libraryid")), controllers.RequestIssue(f.db))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var request models.RequestEvent
+	require.NoError(t, f.db.Where("reader_id = ? AND request_type = ?", f.reader.ID, "issue").First(&request).Error)
+
+	w = f.serve(t, f.admin, http.MethodPut, "/issue/approve/:id", fmt.Sprintf("/issue/approve/%d", request.ID), "", controllers.ApproveIssue(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 0, f.availableCopies(t))
+
+	w = f.checkout(t, request.ID)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = f.serve(t, f.reader, http.MethodPost, "/return", "/return", issueBody, controllers.RequestReturn(f.db))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var returnRequest models.RequestEvent
+	require.NoError(t, f.db.Where("reader_id = ? AND request_type = ?", f.reader.ID, "return").First(&returnRequest).Error)
+
+	w = f.serve(t, f.admin, http.MethodPut, "/return/approve/:id", fmt.Sprintf("/return/approve/%d", returnRequest.ID), "", controllers.ApproveReturn(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 1, f.availableCopies(t))
+
+	var loan models.IssueRegistry
+	require.NoError(t, f.db.First(&loan, *returnRequest.IssueID).Error)
+	assert.Equal(t, "returned", loan.IssueStatus)
+	assert.Equal(t, f.admin.ID, loan.ReturnApproverID)
+	assert.NotZero(t, loan.ReturnDate)
+}
+
+// ✅ Test disapproving keeps the request with its rejection and allows a new request
+func TestDisapproveIssueRecordsRejection(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	issueBody := fmt.Sprintf(`{"isbn": "%s", "libraryid": %d}`, f.book.ISBN, f.library.ID)
+	requestIssue := middleware.RequireLibraryRole("user", middleware.LibraryFromBody("libraryid"))
+
+	w := f.serve(t, f.reader, http.MethodPost, "/issue", "/issue", issueBody, requestIssue, controllers.RequestIssue(f.db))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var request models.RequestEvent
+	require.NoError(t, f.db.Where("reader_id = ?", f.reader.ID).First(&request).Error)
+
+	path := fmt.Sprintf("/issue/disapprove/%d", request.ID)
+	w = f.serve(t, f.admin, http.MethodPut, "/issue/disapprove/:id", path, `{"reason": "Reference copy only"}`, controllers.DisapproveIssue(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	require.NoError(t, f.db.First(&request, request.ID).Error)
+	assert.Equal(t, "Reference copy only", request.RejectionReason)
+	require.NotNil(t, request.RejectedByID)
+	assert.Equal(t, f.admin.ID, *request.RejectedByID)
+	assert.NotNil(t, request.RejectedAt)
+
+	// A disapproved request can be neither disapproved again nor approved
+	w = f.serve(t, f.admin, http.MethodPut, "/issue/disapprove/:id", path, "", controllers.DisapproveIssue(f.db))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = f.serve(t, f.admin, http.MethodPut, "/issue/approve/:id", fmt.Sprintf("/issue/approve/%d", request.ID), "", controllers.ApproveIssue(f.db))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "Request is rejected and cannot be approved")
+	assert.Equal(t, 1, f.availableCopies(t))
+
+	// The reader may ask again once the earlier request was refused
+	w = f.serve(t, f.reader, http.MethodPost, "/issue", "/issue", issueBody, requestIssue, controllers.RequestIssue(f.db))
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+}
+
+// ❌ Test disapproving a request from another library is forbidden
+func TestDisapproveIssueOtherLibrary(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	request := models.RequestEvent{BookID: f.book.ISBN, LibraryID: f.library.ID + 1, ReaderID: f.reader.ID, RequestDate: 1, RequestType: "issue"}
+	require.NoError(t, f.db.Create(&request).Error)
+
+	w := f.serve(t, f.admin, http.MethodPut, "/issue/disapprove/:id", fmt.Sprintf("/issue/disapprove/%d", request.ID), "", controllers.DisapproveIssue(f.db))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	require.NoError(t, f.db.First(&request, request.ID).Error)
+	assert.Nil(t, request.RejectedAt)
+}
+
+// ❌ Test IssueBookToUser only issues to readers registered at the library
+func TestIssueBookToUserValidatesReader(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	outsider := models.User{Name: "Outsider", Email: "outsider@example.com", Role: "user", Password: "x"}
+	require.NoError(t, f.db.Create(&outsider).Error)
+
+	issue := func(userID uint) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"user_id": %d, "library_id": %d}`, userID, f.library.ID)
+		return f.serve(t, f.admin, http.MethodPost, "/issue/book/:isbn", "/issue/book/"+f.book.ISBN, body,
+			middleware.RequireLibraryRole("admin", middleware.LibraryFromBody("library_id")), controllers.IssueBookToUser(f.db))
+	}
+
+	w := issue(9999)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = issue(f.admin.ID)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "only be issued to readers")
+	w = issue(outsider.ID)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "not registered in this library")
+	assert.Equal(t, 1, f.availableCopies(t))
+
+	w = issue(f.reader.ID)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 0, f.availableCopies(t))
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"library-management/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/logger"
+)
+
+func writeConfigFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	return path
+}
+
+// ✅ Test defaults are valid once development mode is chosen
+func TestConfigDefaults(t *testing.T) {
+	t.Setenv("LIBRARY_ENV", "development")
+
+	cfg, err := config.Load("")
+	require.NoError(t, err)
+	assert.Equal(t, config.DefaultJWTSecret, cfg.JWTSecret)
+	assert.Equal(t, ":8080", cfg.ListenAddr)
+	assert.Equal(t, 15*time.Minute, cfg.TokenTTL)
+	assert.Equal(t, 7*24*time.Hour, cfg.RefreshTTL)
+	assert.Equal(t, 14, cfg.LoanPeriodDays)
+	assert.Equal(t, 5, cfg.MaxLoans)
+	assert.Equal(t, 2, cfg.MaxRenewals)
+}
+
+// ✅ Test only the debug log level logs every SQL statement
+func TestGormLogLevel(t *testing.T) {
+	for level, want := range map[string]logger.LogLevel{
+		"debug": logger.Info,
+		"info":  logger.Warn,
+		"WARN":  logger.Warn,
+		"error": logger.Error,
+	} {
+		cfg := config.Default()
+		cfg.LogLevel = level
+		assert.Equal(t, want, cfg.GormLogLevel(), level)
+	}
+}
+
+// ✅ Test environment variables override the config file
+func TestConfigFileAndEnvOverride(t *testing.T) {
+	path := writeConfigFile(t, `
+env: production
+listen_addr: ":9090"
+jwt_secret: "file-secret-value"
+token_ttl: 2h
+loan_period_days: 21
+log_level: warn
+`)
+	t.Setenv("LISTEN_ADDR", ":7070")
+	t.Setenv("TOKEN_TTL", "30m")
+	t.Setenv("MAX_RENEWALS", "0")
+	t.Setenv("MAX_FINE", "500")
+	t.Setenv("PICKUP_EXPIRY", "24h")
+	t.Setenv("IMPORT_MAX_BYTES", "1048576")
+	t.Setenv("MAX_NEW_COPIES", "50")
+	t.Setenv("IMPORT_TIMEOUT", "30m")
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "production", cfg.Env)
+	assert.Equal(t, ":7070", cfg.ListenAddr)
+	assert.Equal(t, "file-secret-value", cfg.JWTSecret)
+	assert.Equal(t, 30*time.Minute, cfg.TokenTTL)
+	assert.Equal(t, 21, cfg.LoanPeriodDays)
+	assert.Equal(t, 0, cfg.MaxRenewals)
+	assert.Equal(t, int64(500), cfg.MaxFine)
+	assert.Equal(t, 24*time.Hour, cfg.PickupExpiry)
+	assert.Equal(t, int64(1048576), cfg.ImportMaxBytes)
+	assert.Equal(t, 50, cfg.MaxNewCopies)
+	assert.Equal(t, 30*time.Minute, cfg.ImportTimeout)
+}
+
+// ❌ Test published secrets are refused unless development is chosen explicitly
+func TestConfigRejectsPlaceholderSecrets(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    string
+		secret string
+	}{
+		{"built-in secret with env unset", "", ""},
+		{"built-in secret in production", "production", ""},
+		{"example secret with env unset", "", "change-me"},
+		{"example secret in production", "production", "change-me"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("LIBRARY_ENV", tt.env)
+			}
+			if tt.secret != "" {
+				t.Setenv("JWT_SECRET", tt.secret)
+			}
+
+			_, err := config.Load("")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "jwt_secret must be changed")
+		})
+	}
+}
+
+// ✅ Test the example config only loads once its placeholder secret is replaced
+func TestConfigExampleFile(t *testing.T) {
+	_, err := config.Load("../config.example.yaml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "jwt_secret must be changed")
+
+	t.Setenv("JWT_SECRET", "a-real-secret")
+	cfg, err := config.Load("../config.example.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "production", cfg.Env)
+}
+
+// ❌ Test invalid values are reported together
+func TestConfigValidation(t *testing.T) {
+	path := writeConfigFile(t, `
+loan_period_days: 0
+fine_per_day: -5
+request_expiry: -1h
+import_max_bytes: 0
+max_new_copies: 0
+import_timeout: 0s
+log_level: verbose
+`)
+
+	t.Setenv("LIBRARY_ENV", "development")
+
+	_, err := config.Load(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "loan_period_days must be positive")
+	assert.Contains(t, err.Error(), "fine_per_day cannot be negative")
+	assert.Contains(t, err.Error(), "request_expiry cannot be negative")
+	assert.Contains(t, err.Error(), "import_max_bytes must be positive")
+	assert.Contains(t, err.Error(), "max_new_copies must be positive")
+	assert.Contains(t, err.Error(), "import_timeout must be positive")
+	assert.Contains(t, err.Error(), "log_level must be one of")
+}
+
+// ✅ Test the database driver setting picks the backend and how many connections SQLite keeps
+func TestConnectDatabase(t *testing.T) {
+	tests := []struct {
+		name     string
+		driver   string
+		dsn      string
+		err      string
+		maxConns int // 0 for no limit
+	}{
+		{"sqlite in memory", "sqlite", ":memory:", "", 1},
+		{"sqlite shared memory", "sqlite", "file:books?mode=memory&cache=shared", "", 1},
+		{"sqlite file", "sqlite", filepath.Join(t.TempDir(), "library.db"), "", 0},
+		{"unknown driver", "mysql", "root@/library", `unsupported database driver "mysql"`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.DatabaseDriver = tt.driver
+			cfg.DatabaseDSN = tt.dsn
+			cfg.LogLevel = "error"
+
+			db, err := config.ConnectDatabase(cfg)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			sqlDB, err := db.DB()
+			require.NoError(t, err)
+			defer sqlDB.Close()
+			assert.Equal(t, tt.maxConns, sqlDB.Stats().MaxOpenConnections)
+			assert.Equal(t, "sqlite", db.Dialector.Name())
+		})
+	}
+
+	// An unknown driver is also caught when the configuration is loaded
+	t.Setenv("LIBRARY_ENV", "development")
+	t.Setenv("DATABASE_DRIVER", "mysql")
+	_, err := config.Load("")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `database_driver must be 'postgres' or 'sqlite', got "mysql"`)
+}
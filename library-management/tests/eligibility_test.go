@@ -0,0 +1,233 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"library-management/controllers"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/eligibility"
+	"library-management/services/inventory"
+	"library-management/services/policies"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type eligibilityResponse struct {
+	Eligible bool                 `json:"eligible"`
+	Reasons  []eligibility.Reason `json:"reasons"`
+}
+
+func reasonCodes(reasons []eligibility.Reason) []string {
+	codes := make([]string, len(reasons))
+	for i, reason := range reasons {
+		codes[i] = reason.Code
+	}
+	return codes
+}
+
+func (f circulationFixture) myEligibility(t *testing.T) eligibilityResponse {
+	path := fmt.Sprintf("/me/eligibility?library_id=%d", f.library.ID)
+	w := f.serve(t, f.reader, http.MethodGet, "/me/eligibility", path, "",
+		middleware.RequireLibraryRole("user", middleware.LibraryFromQuery("library_id")), controllers.MyEligibility(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response eligibilityResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+// ✅ Test every reason a reader is blocked is reported together
+func TestEligibilityReasons(t *testing.T) {
+	f := newCirculationFixture(t, 2)
+	assert.True(t, f.myEligibility(t).Eligible)
+
+	// An overdue loan the overdue job has not marked yet, an unpaid fine over
+	// the limit and a suspended account
+	f.createLoan(t, time.Now().AddDate(0, 0, -1))
+	require.NoError(t, f.db.Create(&models.FineEntry{ReaderID: f.reader.ID, LibraryID: f.library.ID,
+		Kind: models.FineCharge, AmountCents: 5000}).Error)
+	path := fmt.Sprintf("/users/%d/status", f.reader.ID)
+	w := f.serve(t, f.admin, http.MethodPut, "/users/:id/status", path, `{"status": "suspended"}`, controllers.SetAccountStatus(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	response := f.myEligibility(t)
+	assert.False(t, response.Eligible)
+	assert.Equal(t, []string{eligibility.AccountSuspended, eligibility.OverdueLoans, eligibility.UnpaidFines}, reasonCodes(response.Reasons))
+
+	body := fmt.Sprintf(`{"user_id": %d, "library_id": %d}`, f.reader.ID, f.library.ID)
+	w = f.serve(t, f.admin, http.MethodPost, "/issue/book/:isbn", "/issue/book/"+f.book.ISBN, body,
+		middleware.RequireLibraryRole("admin", middleware.LibraryFromBody("library_id")), controllers.IssueBookToUser(f.db))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	var blocked struct {
+		Reasons []eligibility.Reason `json:"reasons"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &blocked))
+	assert.Len(t, blocked.Reasons, 3)
+	assert.Equal(t, 1, f.availableCopies(t))
+}
+
+// ❌ Test an issue request cannot be approved once the reader becomes ineligible
+func TestApproveIssueChecksEligibility(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	request := f.createRequest(t, f.reader)
+	require.NoError(t, f.db.Model(&f.reader).Update("status", models.AccountSuspended).Error)
+
+	w := f.serve(t, f.admin, http.MethodPut, "/issue/approve/:id", fmt.Sprintf("/issue/approve/%d", request.ID), "", controllers.ApproveIssue(f.db))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), eligibility.AccountSuspended)
+
+	require.NoError(t, f.db.First(&request, request.ID).Error)
+	assert.Equal(t, models.RequestPending, request.Status)
+	assert.Equal(t, 1, f.availableCopies(t))
+
+	issueBody := fmt.Sprintf(`{"isbn": "%s", "libraryid": %d}`, f.book.ISBN, f.library.ID)
+	w = f.serve(t, f.reader, http.MethodPost, "/issue", "/issue", issueBody,
+		middleware.RequireLibraryRole("user", middleware.LibraryFromBody("libraryid")), controllers.RequestIssue(f.db))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// ❌ Test admins can only change the status of readers in their libraries
+func TestSetAccountStatus(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	outsider := models.User{Name: "Outsider", Email: "outsider@example.com", Role: "user", Password: "x"}
+	require.NoError(t, f.db.Create(&outsider).Error)
+
+	setStatus := func(userID uint, body string) int {
+		path := fmt.Sprintf("/users/%d/status", userID)
+		return f.serve(t, f.admin, http.MethodPut, "/users/:id/status", path, body, controllers.SetAccountStatus(f.db)).Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, setStatus(f.reader.ID, `{"status": "banned"}`))
+	assert.Equal(t, http.StatusForbidden, setStatus(outsider.ID, `{"status": "suspended"}`))
+	assert.Equal(t, http.StatusNotFound, setStatus(f.admin.ID, `{"status": "suspended"}`))
+	assert.Equal(t, http.StatusOK, setStatus(f.reader.ID, `{"status": "suspended"}`))
+	assert.Equal(t, http.StatusOK, setStatus(f.reader.ID, `{"status": "active"}`))
+
+	require.NoError(t, f.db.First(&f.reader, f.reader.ID).Error)
+	assert.Equal(t, models.AccountActive, f.reader.Status)
+}
+
+// ✅ Test copies set aside for a reader count towards the loan limit
+func TestLoanLimitCountsCopiesSetAside(t *testing.T) {
+	itemID := uint(1)
+	tests := []struct {
+		name     string
+		loans    []string // Loan statuses
+		requests []string // Issue request statuses, each with a copy set aside unless pending
+		holds    []string // Hold statuses, each ready one with a copy set aside
+		legacy   int      // Requests ready for pickup approved before copies were tracked
+		limited  bool     // Another book is refused
+		handover bool     // Collecting a copy already set aside is refused
+	}{
+		{name: "nothing out"},
+		{name: "one loan", loans: []string{models.LoanIssued}},
+		{name: "loan and request ready for pickup", loans: []string{models.LoanIssued}, requests: []string{models.RequestReadyForPickup}, limited: true},
+		{name: "loan and ready hold", loans: []string{models.LoanIssued}, holds: []string{models.HoldReady}, limited: true},
+		{name: "two copies set aside", requests: []string{models.RequestReadyForPickup}, holds: []string{models.HoldReady}, limited: true},
+		{name: "three copies set aside", requests: []string{models.RequestReadyForPickup, models.RequestApproved}, holds: []string{models.HoldReady},
+			limited: true, handover: true},
+		{name: "pending requests and waiting holds hold no copy", loans: []string{models.LoanIssued},
+			requests: []string{models.RequestPending, models.RequestPending}, holds: []string{models.HoldWaiting}},
+		{name: "closed requests and holds", loans: []string{models.LoanIssued},
+			requests: []string{models.RequestFulfilled, models.RequestExpired}, holds: []string{models.HoldFulfilled, models.HoldExpired}},
+		{name: "returned loans", loans: []string{models.LoanReturned, models.LoanReturned, models.LoanIssued}},
+		{name: "legacy approvals hold no copy", loans: []string{models.LoanIssued}, legacy: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newCirculationFixture(t, 0)
+			for _, status := range tt.loans {
+				require.NoError(t, f.db.Create(&models.IssueRegistry{ISBN: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID,
+					IssueStatus: status, ExpectedReturnDate: time.Now().AddDate(0, 0, 7).Unix()}).Error)
+			}
+			for _, status := range tt.requests {
+				request := models.RequestEvent{BookID: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID,
+					RequestType: "issue", Status: status, RequestDate: 1}
+				if status != models.RequestPending {
+					request.ItemID = &itemID
+				}
+				require.NoError(t, f.db.Create(&request).Error)
+			}
+			for _, status := range tt.holds {
+				hold := models.Hold{ISBN: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID, Status: status}
+				if status != models.HoldWaiting {
+					hold.ItemID = &itemID
+				}
+				require.NoError(t, f.db.Create(&hold).Error)
+			}
+			for i := 0; i < tt.legacy; i++ {
+				require.NoError(t, f.db.Create(&models.RequestEvent{BookID: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID,
+					RequestType: "issue", Status: models.RequestReadyForPickup, RequestDate: 1}).Error)
+			}
+
+			policy := policies.Defaults()
+			policy.MaxLoans = 2
+			reasons, err := eligibility.Reasons(f.db, f.reader.ID, f.library.ID, policy, time.Now())
+			require.NoError(t, err)
+			if tt.limited {
+				assert.Equal(t, []string{eligibility.LoanLimit}, reasonCodes(reasons))
+			} else {
+				assert.Empty(t, reasons)
+			}
+			assert.Equal(t, tt.limited, eligibility.Check(f.db, f.reader.ID, f.library.ID, policy, time.Now()) != nil)
+			assert.Equal(t, tt.handover, eligibility.CheckHandover(f.db, f.reader.ID, f.library.ID, policy, time.Now()) != nil)
+		})
+	}
+}
+
+// ❌ Test an admin cannot approve a request past the reader's loan limit
+func TestApproveIssueRespectsLoanLimit(t *testing.T) {
+	f := newCirculationFixture(t, 3)
+	_, err := policies.Save(f.db, models.LibraryPolicy{LibraryID: f.library.ID, MaxLoans: intPtr(1)})
+	require.NoError(t, err)
+	approve := func(request models.RequestEvent) *httptest.ResponseRecorder {
+		return f.serve(t, f.admin, http.MethodPut, "/issue/approve/:id", fmt.Sprintf("/issue/approve/%d", request.ID), "", controllers.ApproveIssue(f.db))
+	}
+	first := f.createRequest(t, f.reader)
+	second := f.createRequest(t, f.reader)
+
+	require.Equal(t, http.StatusOK, approve(first).Code)
+	w := approve(second)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), eligibility.LoanLimit)
+	require.NoError(t, f.db.First(&second, second.ID).Error)
+	assert.Equal(t, models.RequestPending, second.Status)
+	assert.Equal(t, 2, f.availableCopies(t))
+
+	// The copy already set aside can still be collected at the limit
+	require.Equal(t, http.StatusOK, f.checkout(t, first.ID).Code)
+	assert.Equal(t, http.StatusForbidden, approve(second).Code)
+}
+
+// ✅ Test a reader at the limit can still be issued the copy their ready hold set aside
+func TestIssueHeldCopyAtLoanLimit(t *testing.T) {
+	f := newCirculationFixture(t, 2)
+	_, err := policies.Save(f.db, models.LibraryPolicy{LibraryID: f.library.ID, MaxLoans: intPtr(1)})
+	require.NoError(t, err)
+	_, item, err := inventory.Reserve(f.db, f.book.ISBN, f.library.ID)
+	require.NoError(t, err)
+	hold := models.Hold{ISBN: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID, Status: models.HoldReady, ItemID: &item.ID}
+	require.NoError(t, f.db.Create(&hold).Error)
+
+	issue := func() int {
+		body := fmt.Sprintf(`{"user_id": %d, "library_id": %d}`, f.reader.ID, f.library.ID)
+		return f.serve(t, f.admin, http.MethodPost, "/issue/book/:isbn", "/issue/book/"+f.book.ISBN, body,
+			middleware.RequireLibraryRole("admin", middleware.LibraryFromBody("library_id")), controllers.IssueBookToUser(f.db)).Code
+	}
+	require.Equal(t, http.StatusOK, issue())
+	var loan models.IssueRegistry
+	require.NoError(t, f.db.Where("reader_id = ?", f.reader.ID).First(&loan).Error)
+	require.NotNil(t, loan.ItemID)
+	assert.Equal(t, item.ID, *loan.ItemID)
+	assert.Equal(t, 1, f.availableCopies(t))
+
+	// With the held copy on loan the limit is reached
+	assert.Equal(t, http.StatusForbidden, issue())
+	assert.Equal(t, 1, f.availableCopies(t))
+}
@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"library-management/controllers"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/catalog"
+	"library-management/services/imports"
+	"library-management/services/inventory"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// export fetches the fixture library's catalog as its admin
+func (f circulationFixture) export(t *testing.T, format string) *httptest.ResponseRecorder {
+	t.Helper()
+	path := fmt.Sprintf("/libraries/%d/catalog/export", f.library.ID)
+	if format != "" {
+		path += "?format=" + format
+	}
+	return f.serve(t, f.admin, http.MethodGet, "/libraries/:id/catalog/export", path, "",
+		middleware.RequireLibraryRole("admin|owner", middleware.LibraryFromParam("id")), controllers.ExportCatalog(f.db))
+}
+
+// exportFixture holds two books in the fixture library, one copy of the
+// first on loan, and a book in another library that must not be exported
+func exportFixture(t *testing.T) circulationFixture {
+	f := newCirculationFixture(t, 3)
+	f.createLoan(t, time.Now().AddDate(0, 0, 7))
+
+	_, _, err := inventory.AddCopies(f.db, models.Book{ISBN: "9781492052593", Title: "Learning Go", Authors: "Jon Bodner",
+		Category: "Programming", LibraryID: f.library.ID}, make([]inventory.NewItem, 1))
+	require.NoError(t, err)
+
+	other := models.Library{Name: "Branch"}
+	require.NoError(t, f.db.Create(&other).Error)
+	_, _, err = inventory.AddCopies(f.db, models.Book{ISBN: "9780804429573", Title: "Gardening", LibraryID: other.ID},
+		make([]inventory.NewItem, 1))
+	require.NoError(t, err)
+	return f
+}
+
+// ✅ Test the CSV export lists the library's books with copy counts and loans
+func TestExportCatalogCSV(t *testing.T) {
+	f := exportFixture(t)
+
+	w := f.export(t, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, fmt.Sprintf(`attachment; filename="library-%d-catalog.csv"`, f.library.ID), w.Header().Get("Content-Disposition"))
+
+	rows, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"isbn", "title", "authors", "publisher", "version", "category", "copies", "available_copies", "on_loan"},
+		{"9780134685991", "Effective Java", "Joshua Bloch", "Addison-Wesley", "", "", "3", "2", "1"},
+		{"9781492052593", "Learning Go", "Jon Bodner", "", "", "Programming", "1", "1", "0"},
+	}, rows)
+
+	// Importing the export adds every copy of each book
+	records, err := imports.Parse(models.ImportCSV, w.Body.Bytes())
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Len(t, records[0].Items, 3)
+	assert.Len(t, records[1].Items, 1)
+}
+
+// ✅ Test the JSON Lines export writes one book per line
+func TestExportCatalogJSONLines(t *testing.T) {
+	f := exportFixture(t)
+
+	w := f.export(t, catalog.JSONLines)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	var entries []catalog.Entry
+	scanner := bufio.NewScanner(strings.NewReader(w.Body.String()))
+	for scanner.Scan() {
+		var entry catalog.Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.Len(t, entries, 2)
+	assert.Equal(t, catalog.Entry{ISBN: "9780134685991", Title: "Effective Java", Authors: "Joshua Bloch", Publisher: "Addison-Wesley",
+		TotalCopies: 3, AvailableCopies: 2, OnLoan: 1}, entries[0])
+	assert.Equal(t, "9781492052593", entries[1].ISBN)
+}
+
+// ✅ Test the MARCXML export is a MARC21 collection with holdings in field 999
+func TestExportCatalogMARCXML(t *testing.T) {
+	f := exportFixture(t)
+
+	w := f.export(t, catalog.MARCXML)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, fmt.Sprintf(`attachment; filename="library-%d-catalog.xml"`, f.library.ID), w.Header().Get("Content-Disposition"))
+
+	var collection struct {
+		XMLName xml.Name `xml:"http://www.loc.gov/MARC21/slim collection"`
+		Records []struct {
+			DataFields []struct {
+				Tag       string `xml:"tag,attr"`
+				Subfields []struct {
+					Code  string `xml:"code,attr"`
+					Value string `xml:",chardata"`
+				} `xml:"subfield"`
+			} `xml:"datafield"`
+		} `xml:"record"`
+	}
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &collection))
+	require.Len(t, collection.Records, 2)
+
+	fields := map[string]map[string]string{}
+	for _, field := range collection.Records[0].DataFields {
+		fields[field.Tag] = map[string]string{}
+		for _, subfield := range field.Subfields {
+			fields[field.Tag][subfield.Code] = subfield.Value
+		}
+	}
+	assert.Equal(t, "9780134685991", fields["020"]["a"])
+	assert.Equal(t, "Effective Java", fields["245"]["a"])
+	assert.Equal(t, map[string]string{"t": "3", "a": "2", "l": "1"}, fields["999"])
+}
+
+// ❌ Test an unknown format is rejected before anything is streamed
+func TestExportCatalogUnknownFormat(t *testing.T) {
+	f := exportFixture(t)
+
+	w := f.export(t, "pdf")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+	assert.Contains(t, w.Body.String(), "Invalid export format")
+}
@@ -0,0 +1,209 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"library-management/config"
+	"library-management/controllers"
+	"library-management/jobs"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/fines"
+	"library-management/services/loans"
+	"library-management/services/policies"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (f circulationFixture) balance(t *testing.T) int64 {
+	balance, err := fines.ReaderBalance(f.db, f.reader.ID, f.library.ID)
+	require.NoError(t, err)
+	return balance
+}
+
+// ✅ Test the overdue job marks late loans and charges each full day once
+func TestOverdueLoansJob(t *testing.T) {
+	f := newCirculationFixture(t, 2)
+	due := time.Now().Add(-(3*24 + 1) * time.Hour)
+	late := f.createLoan(t, due)
+	current := f.createLoan(t, time.Now().AddDate(0, 0, 5))
+
+	require.NoError(t, jobs.OverdueLoans(context.Background(), f.db, time.Now()))
+	require.NoError(t, f.db.First(&late, late.ID).Error)
+	require.NoError(t, f.db.First(&current, current.ID).Error)
+	assert.Equal(t, models.LoanOverdue, late.IssueStatus)
+	assert.Equal(t, models.LoanIssued, current.IssueStatus)
+	assert.Equal(t, 3*config.AppConfig.FinePerDay, f.balance(t))
+
+	// Running again the same day charges nothing more
+	require.NoError(t, jobs.OverdueLoans(context.Background(), f.db, time.Now()))
+	assert.Equal(t, 3*config.AppConfig.FinePerDay, f.balance(t))
+
+	// A day later only the extra day is charged
+	require.NoError(t, jobs.OverdueLoans(context.Background(), f.db, time.Now().Add(24*time.Hour)))
+	assert.Equal(t, 4*config.AppConfig.FinePerDay, f.balance(t))
+
+	var charges int64
+	require.NoError(t, f.db.Model(&models.FineEntry{}).Where("issue_id = ? AND kind = ?", late.ID, models.FineCharge).Count(&charges).Error)
+	assert.Equal(t, int64(2), charges)
+}
+
+// ✅ Test a loan owes for every due date it has had, up to the cap
+func TestFineOwed(t *testing.T) {
+	now := time.Now()
+	daysAgo := func(days float64) int64 { return now.Add(-time.Duration(days * 24 * float64(time.Hour))).Unix() }
+	renewal := func(previousDue int64, renewedDaysAgo float64) models.LoanRenewal {
+		return models.LoanRenewal{PreviousDueDate: previousDue, CreatedAt: now.Add(-time.Duration(renewedDaysAgo * 24 * float64(time.Hour)))}
+	}
+
+	tests := []struct {
+		name     string
+		due      int64
+		renewals []models.LoanRenewal
+		maxFine  int64
+		days     int64 // Expected fine in days of the daily rate
+	}{
+		{"not due yet", daysAgo(-3), nil, 0, 0},
+		{"less than a day late", daysAgo(0.5), nil, 0, 0},
+		{"only full days count", daysAgo(2.9), nil, 0, 2},
+		{"renewed on time", daysAgo(1.5), []models.LoanRenewal{renewal(daysAgo(20), 21)}, 0, 1},
+		{"renewed while overdue", daysAgo(2.5), []models.LoanRenewal{renewal(daysAgo(20), 16.5)}, 0, 3 + 2},
+		{"renewed twice while overdue", daysAgo(-5), []models.LoanRenewal{
+			renewal(daysAgo(30), 28.5), renewal(daysAgo(14.5), 13),
+		}, 0, 1 + 1},
+		{"cap covers every period", daysAgo(4), []models.LoanRenewal{renewal(daysAgo(20), 16)}, 6, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := policies.Defaults()
+			policy.MaxFine = tt.maxFine * policy.FinePerDay
+
+			loan := models.IssueRegistry{ExpectedReturnDate: tt.due}
+			assert.Equal(t, tt.days*policy.FinePerDay, fines.Owed(loan, tt.renewals, policy, now))
+		})
+	}
+}
+
+// ✅ Test renewing an overdue loan charges its fine and a later overdue period is charged too
+func TestRenewedLoanFines(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	rate := config.AppConfig.FinePerDay
+	loan := f.createLoan(t, time.Now().AddDate(0, 0, -3).Add(-time.Hour))
+
+	// The fine is settled when the loan is renewed, before the overdue job has run
+	_, _, err := loans.Renew(f.db, loan.ID, &f.reader.ID, f.reader.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3*rate, f.balance(t))
+
+	// Running the job now finds nothing more to charge
+	require.NoError(t, jobs.OverdueLoans(context.Background(), f.db, time.Now()))
+	assert.Equal(t, 3*rate, f.balance(t))
+
+	// Two days after the new due date the second period is charged on top
+	later := time.Now().AddDate(0, 0, config.AppConfig.LoanPeriodDays+2).Add(time.Hour)
+	require.NoError(t, jobs.OverdueLoans(context.Background(), f.db, later))
+	assert.Equal(t, 5*rate, f.balance(t))
+
+	entries, err := fines.Ledger(f.db, f.reader.ID, f.library.ID)
+	require.NoError(t, err)
+	var charges []int64
+	for _, entry := range entries {
+		require.Equal(t, models.FineCharge, entry.Kind)
+		require.NotNil(t, entry.IssueID)
+		assert.Equal(t, loan.ID, *entry.IssueID)
+		charges = append(charges, entry.AmountCents)
+	}
+	assert.Equal(t, []int64{3 * rate, 2 * rate}, charges)
+}
+
+// ✅ Test returning an overdue book settles its fine and renewing clears the overdue status
+func TestReturnChargesOverdueFine(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	loan := f.createLoan(t, time.Now().AddDate(0, 0, -2).Add(-time.Hour))
+	require.NoError(t, f.db.Model(&f.book).Update("available_copies", 0).Error)
+	require.NoError(t, jobs.OverdueLoans(context.Background(), f.db, time.Now()))
+
+	// Overdue loans can still be returned
+	issueBody := fmt.Sprintf(`{"isbn": "%s", "libraryid": %d}`, f.book.ISBN, f.library.ID)
+	w := f.serve(t, f.reader, http.MethodPost, "/return", "/return", issueBody, controllers.RequestReturn(f.db))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var returnRequest models.RequestEvent
+	require.NoError(t, f.db.Where("reader_id = ? AND request_type = ?", f.reader.ID, "return").First(&returnRequest).Error)
+	w = f.serve(t, f.admin, http.MethodPut, "/return/approve/:id", fmt.Sprintf("/return/approve/%d", returnRequest.ID), "", controllers.ApproveReturn(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 2*config.AppConfig.FinePerDay, f.balance(t))
+
+	require.NoError(t, f.db.First(&loan, loan.ID).Error)
+	assert.Equal(t, models.LoanReturned, loan.IssueStatus)
+
+	renewed := f.createLoan(t, time.Now().AddDate(0, 0, -1).Add(-time.Hour))
+	require.NoError(t, jobs.OverdueLoans(context.Background(), f.db, time.Now()))
+	require.Equal(t, http.StatusOK, f.renewMine(t, f.reader, renewed.ID))
+	require.NoError(t, f.db.First(&renewed, renewed.ID).Error)
+	assert.Equal(t, models.LoanIssued, renewed.IssueStatus)
+}
+
+// ✅ Test payments and waivers reduce the balance and are refused beyond it
+func TestFinePaymentsAndWaivers(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	f.createLoan(t, time.Now().AddDate(0, 0, -4).Add(-time.Hour))
+	require.NoError(t, jobs.OverdueLoans(context.Background(), f.db, time.Now()))
+	owed := 4 * config.AppConfig.FinePerDay
+	require.Equal(t, owed, f.balance(t))
+
+	scope := middleware.RequireLibraryRole("admin", middleware.LibraryFromBody("library_id"))
+	credit := func(route string, amount int64, reason string) int {
+		body := fmt.Sprintf(`{"library_id": %d, "reader_id": %d, "amount_cents": %d, "reason": %q}`, f.library.ID, f.reader.ID, amount, reason)
+		handler := controllers.RecordFinePayment(f.db)
+		if route == "/fines/waivers" {
+			handler = controllers.WaiveFine(f.db)
+		}
+		return f.serve(t, f.admin, http.MethodPost, route, route, body, scope, handler).Code
+	}
+
+	assert.Equal(t, http.StatusConflict, credit("/fines/payments", owed+1, ""))
+	assert.Equal(t, http.StatusCreated, credit("/fines/payments", config.AppConfig.FinePerDay, "Cash"))
+	assert.Equal(t, http.StatusBadRequest, credit("/fines/waivers", config.AppConfig.FinePerDay, ""))
+	assert.Equal(t, http.StatusCreated, credit("/fines/waivers", config.AppConfig.FinePerDay, "First offence"))
+	assert.Equal(t, owed-2*config.AppConfig.FinePerDay, f.balance(t))
+
+	// The handler must act on the library the middleware checked, not the body's
+	path := fmt.Sprintf("/fines/payments?library_id=%d", f.library.ID)
+	body := fmt.Sprintf(`{"library_id": %d, "reader_id": %d, "amount_cents": 1}`, f.library.ID+1, f.reader.ID)
+	w := f.serve(t, f.admin, http.MethodPost, "/fines/payments", path, body,
+		middleware.RequireLibraryRole("admin", middleware.LibraryFromQuery("library_id")), controllers.RecordFinePayment(f.db))
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+	var waiver models.FineEntry
+	require.NoError(t, f.db.Where("kind = ?", models.FineWaiver).First(&waiver).Error)
+	require.NotNil(t, waiver.ActorID)
+	assert.Equal(t, f.admin.ID, *waiver.ActorID)
+	assert.Equal(t, "First offence", waiver.Reason)
+
+	w = f.serve(t, f.reader, http.MethodGet, "/me/fines", "/me/fines", "", controllers.ListMyFines(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var mine struct {
+		BalanceCents int64              `json:"balance_cents"`
+		Entries      []models.FineEntry `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &mine))
+	assert.Equal(t, owed-2*config.AppConfig.FinePerDay, mine.BalanceCents)
+	assert.Len(t, mine.Entries, 3)
+
+	path = fmt.Sprintf("/fines?library_id=%d", f.library.ID)
+	w = f.serve(t, f.admin, http.MethodGet, "/fines", path, "",
+		middleware.RequireLibraryRole("admin", middleware.LibraryFromQuery("library_id")), controllers.ListFines(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var listed struct {
+		Balances []fines.Balance `json:"balances"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed.Balances, 1)
+	assert.Equal(t, f.reader.ID, listed.Balances[0].ReaderID)
+}
@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"library-management/controllers"
+	"library-management/jobs"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/holds"
+	"library-management/services/inventory"
+	"library-management/services/requests"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ✅ Test requesting a book with no free copies joins the queue in order
+func TestRequestIssueJoinsHoldsQueue(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+	second := f.createReader(t, "second")
+	issueBody := fmt.Sprintf(`{"isbn": "%s", "libraryid": %d}`, f.book.ISBN, f.library.ID)
+	requestIssue := middleware.RequireLibraryRole("user", middleware.LibraryFromBody("libraryid"))
+
+	w := f.serve(t, f.reader, http.MethodPost, "/issue", "/issue", issueBody, requestIssue, controllers.RequestIssue(f.db))
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+	w = f.serve(t, second, http.MethodPost, "/issue", "/issue", issueBody, requestIssue, controllers.RequestIssue(f.db))
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var response struct {
+		Position int `json:"position"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.Position)
+
+	w = f.serve(t, second, http.MethodPost, "/issue", "/issue", issueBody, requestIssue, controllers.RequestIssue(f.db))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// No issue request is left behind for a queued reader
+	var pending int64
+	require.NoError(t, f.db.Model(&models.RequestEvent{}).Count(&pending).Error)
+	assert.Zero(t, pending)
+}
+
+// ✅ Test requesting a title whose hold is ready returns the request set aside for it
+func TestRequestIssueWithReadyHold(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+	_, _, err := holds.Place(f.db, f.reader.ID, f.book.ISBN, f.library.ID)
+	require.NoError(t, err)
+	_, _, err = inventory.AddCopies(f.db, f.book, make([]inventory.NewItem, 1))
+	require.NoError(t, err)
+	_, err = holds.Allocate(f.db, f.book.ISBN, f.library.ID)
+	require.NoError(t, err)
+
+	issueBody := fmt.Sprintf(`{"isbn": "%s", "libraryid": %d}`, f.book.ISBN, f.library.ID)
+	w := f.serve(t, f.reader, http.MethodPost, "/issue", "/issue", issueBody,
+		middleware.RequireLibraryRole("user", middleware.LibraryFromBody("libraryid")), controllers.RequestIssue(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Request models.RequestEvent `json:"request"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, *f.hold(t, f.reader).RequestID, response.Request.ID)
+	assert.Equal(t, models.RequestReadyForPickup, response.Request.Status)
+
+	var requests int64
+	require.NoError(t, f.db.Model(&models.RequestEvent{}).Count(&requests).Error)
+	assert.Equal(t, int64(1), requests)
+}
+
+// ✅ Test a returned copy is set aside for the head of the queue and claimed on issue
+func TestReturnedCopyGoesToHold(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	loan := f.createLoan(t, time.Now().AddDate(0, 0, 7))
+
+	waiting := f.createReader(t, "waiting")
+	_, position, err := holds.Place(f.db, waiting.ID, f.book.ISBN, f.library.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, position)
+
+	// The loan cannot be renewed while someone is waiting for the title
+	assert.Equal(t, http.StatusConflict, f.renewMine(t, f.reader, loan.ID))
+
+	returnRequest := models.RequestEvent{BookID: f.book.ISBN, ReaderID: f.reader.ID, LibraryID: f.library.ID,
+		RequestType: "return", RequestDate: time.Now().Unix(), IssueID: &loan.ID, Status: models.RequestPending}
+	require.NoError(t, f.db.Create(&returnRequest).Error)
+	w := f.serve(t, f.admin, http.MethodPut, "/return/approve/:id", fmt.Sprintf("/return/approve/%d", returnRequest.ID), "", controllers.ApproveReturn(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	hold := f.hold(t, waiting)
+	assert.Equal(t, models.HoldReady, hold.Status)
+	require.NotNil(t, hold.PickupDeadline)
+	assert.Equal(t, 0, f.availableCopies(t))
+
+	body := fmt.Sprintf(`{"user_id": %d, "library_id": %d}`, waiting.ID, f.library.ID)
+	w = f.serve(t, f.admin, http.MethodPost, "/issue/book/:isbn", "/issue/book/"+f.book.ISBN, body,
+		middleware.RequireLibraryRole("admin", middleware.LibraryFromBody("library_id")), controllers.IssueBookToUser(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, models.HoldFulfilled, f.hold(t, waiting).Status)
+	assert.Equal(t, 0, f.availableCopies(t))
+
+	var request models.RequestEvent
+	require.NoError(t, f.db.First(&request, *hold.RequestID).Error)
+	assert.Equal(t, models.RequestFulfilled, request.Status)
+}
+
+// ✅ Test a ready hold is checked out and reported through its request ready for pickup
+func TestReadyHoldRequest(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+	second := f.createReader(t, "second")
+	for _, reader := range []models.User{f.reader, second} {
+		_, _, err := holds.Place(f.db, reader.ID, f.book.ISBN, f.library.ID)
+		require.NoError(t, err)
+	}
+	_, _, err := inventory.AddCopies(f.db, f.book, make([]inventory.NewItem, 2))
+	require.NoError(t, err)
+	ready, err := holds.Allocate(f.db, f.book.ISBN, f.library.ID)
+	require.NoError(t, err)
+	require.Len(t, ready, 2)
+
+	// Each ready hold has a request holding its copy until its own deadline
+	hold := f.hold(t, f.reader)
+	require.NotNil(t, hold.RequestID)
+	var request models.RequestEvent
+	require.NoError(t, f.db.First(&request, *hold.RequestID).Error)
+	assert.Equal(t, models.RequestReadyForPickup, request.Status)
+	assert.Equal(t, *hold.ItemID, *request.ItemID)
+	assert.Equal(t, *hold.PickupDeadline, *request.PickupDeadline)
+
+	require.Equal(t, http.StatusOK, f.checkout(t, request.ID).Code)
+	assert.Equal(t, models.HoldFulfilled, f.hold(t, f.reader).Status)
+	require.NoError(t, f.db.First(&request, request.ID).Error)
+	assert.Equal(t, models.RequestFulfilled, request.Status)
+
+	// The request expiry leaves the other hold's request to the hold's deadline
+	expired, err := requests.ExpireStale(f.db, time.Now().AddDate(0, 0, 30), 0, time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, expired)
+	expired, err = holds.ExpireOverdue(f.db, time.Now().AddDate(0, 0, 30))
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	assert.Equal(t, 1, f.availableCopies(t))
+
+	uncollected, err := requests.Uncollected(f.db, f.library.ID, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, uncollected, 1)
+	assert.Equal(t, *f.hold(t, second).RequestID, uncollected[0].ID)
+	assert.Equal(t, "Not collected by the hold's pickup deadline", uncollected[0].ExpiryReason)
+}
+
+// ✅ Test uncollected holds expire and the copy moves to the next reader
+func TestExpireOverdueHolds(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+	first := f.createReader(t, "first")
+	second := f.createReader(t, "second")
+	for _, reader := range []models.User{first, second} {
+		_, _, err := holds.Place(f.db, reader.ID, f.book.ISBN, f.library.ID)
+		require.NoError(t, err)
+	}
+
+	_, _, err := inventory.AddCopies(f.db, f.book, make([]inventory.NewItem, 1))
+	require.NoError(t, err)
+	ready, err := holds.Allocate(f.db, f.book.ISBN, f.library.ID)
+	require.NoError(t, err)
+	require.Len(t, ready, 1)
+	assert.Equal(t, first.ID, ready[0].ReaderID)
+
+	// Nothing has expired yet
+	expired, err := holds.ExpireOverdue(f.db, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, expired)
+
+	expired, err = holds.ExpireOverdue(f.db, time.Now().AddDate(0, 0, 30))
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	assert.Equal(t, models.HoldExpired, f.hold(t, first).Status)
+	assert.Equal(t, models.HoldReady, f.hold(t, second).Status)
+	assert.Equal(t, 0, f.availableCopies(t))
+}
+
+// ✅ Test cancelling a ready hold releases its copy
+func TestCancelMyHold(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+	hold, _, err := holds.Place(f.db, f.reader.ID, f.book.ISBN, f.library.ID)
+	require.NoError(t, err)
+	_, _, err = inventory.AddCopies(f.db, f.book, make([]inventory.NewItem, 1))
+	require.NoError(t, err)
+	_, err = holds.Allocate(f.db, f.book.ISBN, f.library.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, f.availableCopies(t))
+
+	other := f.createReader(t, "other")
+	path := fmt.Sprintf("/me/holds/%d", hold.ID)
+	w := f.serve(t, other, http.MethodDelete, "/me/holds/:id", path, "", controllers.CancelMyHold(f.db))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = f.serve(t, f.reader, http.MethodDelete, "/me/holds/:id", path, "", controllers.CancelMyHold(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, models.HoldCancelled, f.hold(t, f.reader).Status)
+	assert.Equal(t, 1, f.availableCopies(t))
+
+	var request models.RequestEvent
+	require.NoError(t, f.db.First(&request, *f.hold(t, f.reader).RequestID).Error)
+	assert.Equal(t, models.RequestCancelled, request.Status)
+
+	w = f.serve(t, f.reader, http.MethodDelete, "/me/holds/:id", path, "", controllers.CancelMyHold(f.db))
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+// ✅ Test the scheduler runs the hold expiry job
+func TestSchedulerRunOnce(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+	_, _, err := holds.Place(f.db, f.reader.ID, f.book.ISBN, f.library.ID)
+	require.NoError(t, err)
+	require.NoError(t, f.db.Model(&f.book).Update("total_copies", 1).Error)
+	deadline := time.Now().Add(-time.Hour).Unix()
+	require.NoError(t, f.db.Model(&models.Hold{}).Where("reader_id = ?", f.reader.ID).
+		Updates(map[string]interface{}{"status": models.HoldReady, "pickup_deadline": deadline}).Error)
+
+	s := jobs.NewScheduler(f.db)
+	s.Every("expire-holds", time.Minute, jobs.ExpireHolds)
+	require.NoError(t, s.RunOnce(context.Background()))
+	assert.Equal(t, models.HoldExpired, f.hold(t, f.reader).Status)
+}
@@ -0,0 +1,354 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"library-management/config"
+	"library-management/controllers"
+	"library-management/jobs"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/holds"
+	"library-management/services/imports"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upload posts a catalog file to ImportCatalog as an admin of the fixture's library
+func (f circulationFixture) upload(t *testing.T, filename, content string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/imports", withUser(f.admin.ID, f.admin.Role, f.library.ID),
+		middleware.RequireLibraryRole("admin", middleware.LibraryFromQuery("library_id")), controllers.ImportCatalog(f.db))
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/imports?library_id=%d", f.library.ID), &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// ✅ Test a CSV import merges copies, imports new books and reports bad rows
+func TestImportCSV(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+	waiting := f.createReader(t, "waiting")
+	_, _, err := holds.Place(f.db, waiting.ID, f.book.ISBN, f.library.ID)
+	require.NoError(t, err)
+
+	csv := "ISBN,Title,Author,Publisher,Copies,Barcode,Condition\n" +
+		"978-0-13-468599-1,Effective Java,Joshua Bloch,Addison-Wesley,2,,\n" +
+		"0-8044-2957-X,Gardening,Jane Doe,Acme,,GD-1,new\n" +
+		"9780804429574,Broken,,,1,,\n" +
+		"9781492052593,,Jon Bodner,,1,,\n" +
+		"9781492052593,Learning Go,Jon Bodner,O'Reilly,three,,\n" +
+		"9781492052593,Learning Go,Jon Bodner,O'Reilly,1,GD-1,\n" +
+		"9781492052593,Learning Go,Jon Bodner,O'Reilly,1,,mint\n" +
+		"9781492052593,Learning Go\n"
+	w := f.upload(t, "catalog.csv", csv)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var queued struct {
+		Import models.CatalogImport `json:"import"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &queued))
+	assert.Equal(t, models.ImportQueued, queued.Import.Status)
+	assert.Equal(t, 8, queued.Import.TotalRows)
+
+	require.NoError(t, jobs.ImportCatalog(context.Background(), f.db, time.Now()))
+
+	path := fmt.Sprintf("/imports/%d", queued.Import.ID)
+	w = f.serve(t, f.admin, http.MethodGet, "/imports/:id", path, "",
+		middleware.RequireLibraryRole("admin", middleware.LibraryFromImport(f.db, "id")), controllers.GetImport(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var report struct {
+		Import   models.CatalogImport        `json:"import"`
+		Progress int                         `json:"progress"`
+		Errors   []models.CatalogImportError `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, models.ImportCompleted, report.Import.Status)
+	assert.Equal(t, 100, report.Progress)
+	assert.Equal(t, 2, report.Import.ImportedRows)
+	assert.Equal(t, 6, report.Import.FailedRows)
+
+	rows := map[int]string{}
+	for _, rowError := range report.Errors {
+		rows[rowError.Row] = rowError.Message
+	}
+	assert.Equal(t, map[int]string{
+		4: `Invalid ISBN "9780804429574"`,
+		5: "Title is required",
+		6: `Copies must be a whole number of at least 1, got "three"`,
+		7: "Barcode is already in use",
+		8: "Condition must be one of new, good, fair, poor, damaged",
+		9: "Wrong number of columns",
+	}, rows)
+
+	// The new copies of the existing book went to the reader waiting for it first
+	var book models.Book
+	require.NoError(t, f.db.First(&book, f.book.ID).Error)
+	assert.Equal(t, 2, book.TotalCopies)
+	assert.Equal(t, 1, book.AvailableCopies)
+	assert.Equal(t, models.HoldReady, f.hold(t, waiting).Status)
+
+	var gardening models.Book
+	require.NoError(t, f.db.Where("isbn = ?", "9780804429573").First(&gardening).Error)
+	assert.Equal(t, "Jane Doe", gardening.Authors)
+	var item models.Item
+	require.NoError(t, f.db.Where("barcode = ?", "GD-1").First(&item).Error)
+	assert.Equal(t, gardening.ID, item.BookID)
+	assert.Equal(t, "new", item.Condition)
+}
+
+// ❌ Test files that cannot be read are refused before they are queued
+func TestImportRejectsUnreadableFiles(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+
+	w := f.upload(t, "catalog.csv", "title,author\nGo,Someone\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "isbn column")
+
+	w = f.upload(t, "catalog.pdf", "%PDF")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Format must be csv, marc or marcxml")
+
+	w = f.upload(t, "catalog.xml", "<collection></collection>")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var count int64
+	require.NoError(t, f.db.Model(&models.CatalogImport{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+// marcField is one data field for marc21: the tag and "ab" style subfield pairs
+type marcField struct {
+	tag       string
+	subfields []string // Code followed by value, e.g. "aEffective Java"
+}
+
+// marc21 encodes one record in ISO 2709 transmission format
+func marc21(fields ...marcField) string {
+	var directory, data strings.Builder
+	for _, field := range fields {
+		value := "  " // Blank indicators
+		for _, subfield := range field.subfields {
+			value += "\x1f" + subfield
+		}
+		value += "\x1e"
+		fmt.Fprintf(&directory, "%s%04d%05d", field.tag, len(value), data.Len())
+		data.WriteString(value)
+	}
+	directory.WriteString("\x1e")
+	base := 24 + directory.Len()
+	length := base + data.Len() + 1
+	leader := fmt.Sprintf("%05dnam a22%05d   4500", length, base)
+	return leader + directory.String() + data.String() + "\x1d"
+}
+
+// ✅ Test MARC21 and MARCXML records map onto books and copies
+func TestImportMARC(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+
+	marc := marc21(
+		marcField{"020", []string{"a9781492052593 (paperback)"}},
+		marcField{"100", []string{"aBodner, Jon,"}},
+		marcField{"245", []string{"aLearning Go :", "ban idiomatic approach /"}},
+		marcField{"264", []string{"aSebastopol :", "bO'Reilly,"}},
+		marcField{"852", []string{"pLG-1"}},
+		marcField{"852", []string{"pLG-2"}},
+	) + "garbage\x1d"
+	job, err := imports.Create(f.db, f.library.ID, nil, imports.DetectFormat("books.mrc"), "books.mrc", []byte(marc))
+	require.NoError(t, err)
+	require.NoError(t, imports.Run(context.Background(), f.db, job.ID))
+
+	job, rowErrors, err := imports.Get(f.db, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, job.ImportedRows)
+	require.Len(t, rowErrors, 1)
+	assert.Equal(t, 2, rowErrors[0].Row)
+	assert.Equal(t, "Malformed MARC record", rowErrors[0].Message)
+
+	var book models.Book
+	require.NoError(t, f.db.Where("isbn = ?", "9781492052593").First(&book).Error)
+	assert.Equal(t, "Learning Go: an idiomatic approach", book.Title)
+	assert.Equal(t, "Bodner, Jon", book.Authors)
+	assert.Equal(t, "O'Reilly", book.Publisher)
+	assert.Equal(t, 2, book.TotalCopies)
+
+	marcXML := `<?xml version="1.0" encoding="UTF-8"?>
+<collection xmlns="http://www.loc.gov/MARC21/slim">
+  <record>
+    <datafield tag="020" ind1=" " ind2=" "><subfield code="a">0-13-468599-7</subfield></datafield>
+    <datafield tag="245" ind1="1" ind2="0"><subfield code="a">Effective Java.</subfield></datafield>
+    <datafield tag="700" ind1="1" ind2=" "><subfield code="a">Bloch, Joshua.</subfield></datafield>
+  </record>
+</collection>`
+	job, err = imports.Create(f.db, f.library.ID, nil, imports.DetectFormat("books.xml"), "books.xml", []byte(marcXML))
+	require.NoError(t, err)
+	finished, err := imports.RunQueued(context.Background(), f.db)
+	require.NoError(t, err)
+	assert.Equal(t, 1, finished)
+
+	var effectiveJava models.Book
+	require.NoError(t, f.db.First(&effectiveJava, f.book.ID).Error)
+	assert.Equal(t, 1, effectiveJava.TotalCopies)
+	assert.Equal(t, "Joshua Bloch", effectiveJava.Authors, "an existing book keeps its details")
+}
+
+// ✅ Test an interrupted import goes back to the queue and resumes where it stopped
+func TestImportResumes(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+	job, err := imports.Create(f.db, f.library.ID, nil, models.ImportCSV, "books.csv",
+		[]byte("isbn,title\n9780134685991,Effective Java\n9781492052593,Learning Go\n"))
+	require.NoError(t, err)
+
+	// As if the first row was saved before the server stopped
+	require.NoError(t, f.db.Model(&job).Updates(map[string]interface{}{"processed_rows": 1, "imported_rows": 1}).Error)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, imports.Run(ctx, f.db, job.ID))
+	job, _, err = imports.Get(f.db, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportQueued, job.Status)
+
+	require.NoError(t, imports.Run(context.Background(), f.db, job.ID))
+	job, _, err = imports.Get(f.db, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportCompleted, job.Status)
+	assert.Equal(t, 2, job.ImportedRows)
+	require.NotNil(t, job.FinishedAt)
+
+	// Only the second row was imported by this run
+	var books []models.Book
+	require.NoError(t, f.db.Order("id").Find(&books).Error)
+	require.Len(t, books, 2)
+	assert.Equal(t, 0, books[0].TotalCopies)
+	assert.Equal(t, "9781492052593", books[1].ISBN)
+}
+
+// ✅ Test an import left running by a runner that died is requeued once it goes quiet
+func TestImportRequeuesInterruptedRun(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+	payload := []byte("isbn,title\n9780134685991,Effective Java\n9781492052593,Learning Go\n")
+	crashed, err := imports.Create(f.db, f.library.ID, nil, models.ImportCSV, "crashed.csv", payload)
+	require.NoError(t, err)
+	busy, err := imports.Create(f.db, f.library.ID, nil, models.ImportCSV, "busy.csv", payload)
+	require.NoError(t, err)
+
+	// As if the server stopped after saving the first row, an hour ago, while
+	// another runner is still working through the second import
+	require.NoError(t, f.db.Model(&crashed).UpdateColumns(map[string]interface{}{
+		"status": models.ImportRunning, "processed_rows": 1, "imported_rows": 1, "updated_at": time.Now().Add(-time.Hour),
+	}).Error)
+	require.NoError(t, f.db.Model(&busy).Update("status", models.ImportRunning).Error)
+
+	finished, err := imports.RunQueued(context.Background(), f.db)
+	require.NoError(t, err)
+	assert.Zero(t, finished, "running imports are not claimed")
+
+	require.NoError(t, jobs.ImportCatalog(context.Background(), f.db, time.Now()))
+	crashed, _, err = imports.Get(f.db, crashed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportCompleted, crashed.Status)
+	assert.Equal(t, 2, crashed.ProcessedRows)
+	busy, _, err = imports.Get(f.db, busy.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportRunning, busy.Status)
+
+	// The run that took over only imported the row that was left
+	var books []models.Book
+	require.NoError(t, f.db.Order("id").Find(&books).Error)
+	require.Len(t, books, 2)
+	assert.Equal(t, 1, books[1].TotalCopies)
+}
+
+// ❌ Test ISO 2709 records with bad leader or directory numbers are reported, not imported
+func TestImportMalformedMARC(t *testing.T) {
+	valid := marc21(marcField{"020", []string{"a9781492052593"}}, marcField{"245", []string{"aLearning Go"}})
+	// patch overwrites part of the valid record
+	patch := func(at int, text string) string {
+		return valid[:at] + text + valid[at+len(text):]
+	}
+
+	tests := []struct {
+		name   string
+		record string
+	}{
+		{"too short for a leader", "01234nam a22\x1d"},
+		{"negative base address", patch(12, "-0001")},
+		{"signed base address", patch(12, "+0049")},
+		{"base address past the end", patch(12, "99999")},
+		{"base address inside the leader", patch(12, "00010")},
+		{"negative field length", patch(27, "-001")},
+		{"negative field start", patch(31, "-0001")},
+		{"field past the end", patch(31, "99999")},
+		{"field length past the end", patch(27, "9999")},
+		{"letters in the directory", patch(27, "00a1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := imports.Parse(models.ImportMARC, []byte(tt.record))
+			require.NoError(t, err)
+			require.Len(t, records, 1)
+			assert.Equal(t, "Malformed MARC record", records[0].Problem)
+		})
+	}
+
+	records, err := imports.Parse(models.ImportMARC, []byte(valid))
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Empty(t, records[0].Problem)
+	assert.Equal(t, "Learning Go", records[0].Book.Title)
+}
+
+// ❌ Test a row cannot add more copies than one request may
+func TestImportCopyLimit(t *testing.T) {
+	limit := config.AppConfig.MaxNewCopies
+	var holdings []marcField
+	for i := 0; i <= limit; i++ {
+		holdings = append(holdings, marcField{"852", []string{fmt.Sprintf("pLG-%d", i)}})
+	}
+
+	tests := []struct {
+		name    string
+		format  string
+		payload string
+		problem string
+	}{
+		{"CSV copies at the limit", models.ImportCSV, fmt.Sprintf("isbn,title,copies\n9781492052593,Learning Go,%d\n", limit), ""},
+		{"CSV copies above the limit", models.ImportCSV, fmt.Sprintf("isbn,title,copies\n9781492052593,Learning Go,%d\n", limit+1),
+			fmt.Sprintf("At most %d copies can be added in one row, got %d", limit, limit+1)},
+		{"CSV copies overflowing an int", models.ImportCSV, "isbn,title,copies\n9781492052593,Learning Go,99999999999999999999\n",
+			`Copies must be a whole number of at least 1, got "99999999999999999999"`},
+		{"MARC holdings above the limit", models.ImportMARC, marc21(append([]marcField{{"245", []string{"aLearning Go"}}}, holdings...)...),
+			fmt.Sprintf("At most %d copies can be added in one record, got %d", limit, limit+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := imports.Parse(tt.format, []byte(tt.payload))
+			require.NoError(t, err)
+			require.Len(t, records, 1)
+			assert.Equal(t, tt.problem, records[0].Problem)
+			if tt.problem != "" {
+				assert.Empty(t, records[0].Items)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package tests
+
+import (
+	"errors"
+	"library-management/config"
+	"library-management/models"
+	"library-management/services/inventory"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// openInventoryDB opens a database for the concurrency tests below. Only
+// Postgres honours the SELECT ... FOR UPDATE row locks they exercise, so it is
+// used when TEST_POSTGRES_DSN is set. Otherwise they run on a file-backed SQLite
+// database whose transactions take the write lock up front: that still checks
+// the counters stay in range, but runs every transaction one at a time.
+func openInventoryDB(t *testing.T) *gorm.DB {
+	if os.Getenv("TEST_POSTGRES_DSN") != "" {
+		return openPostgresDatabase(t)
+	}
+	dsn := filepath.Join(t.TempDir(), "inventory.db") + "?_pragma=busy_timeout(10000)&_txlock=immediate"
+	return openSQLiteDatabase(t, dsn)
+}
+
+func seedBook(t *testing.T, db *gorm.DB, copies int) models.Book {
+	book, created, err := inventory.AddCopies(db, models.Book{ISBN: "9780134685991", Title: "Effective Java", LibraryID: 1}, make([]inventory.NewItem, copies))
+	require.NoError(t, err)
+	require.True(t, created)
+	return book
+}
+
+// ✅ Concurrent reservations never hand out more copies than exist
+func TestInventoryConcurrentReserve(t *testing.T) {
+	db := openInventoryDB(t)
+	seedBook(t, db, 5)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved, exhausted := 0, 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := inventory.Reserve(db, "9780134685991", 1)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				reserved++
+			case errors.Is(err, inventory.ErrNoCopiesAvailable):
+				exhausted++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, reserved)
+	assert.Equal(t, 15, exhausted)
+
+	var book models.Book
+	require.NoError(t, db.Where("isbn = ? AND library_id = ?", "9780134685991", 1).First(&book).Error)
+	assert.Equal(t, 0, book.AvailableCopies)
+	assert.Equal(t, 5, book.TotalCopies)
+}
+
+// ✅ Interleaved reserve, release and removal keep counters in range
+func TestInventoryConcurrentMixedOperations(t *testing.T) {
+	db := openInventoryDB(t)
+	seedBook(t, db, 3)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			switch i % 3 {
+			case 0:
+				_, _, err = inventory.Reserve(db, "9780134685991", 1)
+			case 1:
+				_, err = inventory.Release(db, uint(i%3)+1)
+			case 2:
+				_, _, err = inventory.Withdraw(db, "9780134685991", 1, "")
+			}
+			if err != nil &&
+				!errors.Is(err, inventory.ErrNoCopiesAvailable) &&
+				!errors.Is(err, inventory.ErrAllCopiesAvailable) &&
+				!errors.Is(err, inventory.ErrCopiesOnLoan) &&
+				!errors.Is(err, inventory.ErrItemNotFound) &&
+				!errors.Is(err, inventory.ErrBookNotFound) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var book models.Book
+	err := db.Where("isbn = ? AND library_id = ?", "9780134685991", 1).First(&book).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return // every copy was withdrawn
+	}
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, book.AvailableCopies, 0)
+	assert.LessOrEqual(t, book.AvailableCopies, book.TotalCopies)
+}
+
+// ❌ Copies on loan cannot be removed or undercut by an update
+func TestInventoryProtectsIssuedCopies(t *testing.T) {
+	db := openInventoryDB(t)
+	seedBook(t, db, 2)
+
+	_, reserved, err := inventory.Reserve(db, "9780134685991", 1)
+	require.NoError(t, err)
+
+	_, _, err = inventory.Withdraw(db, "9780134685991", 1, reserved.Barcode)
+	assert.ErrorIs(t, err, inventory.ErrItemNotAvailable)
+
+	_, _, err = inventory.Update(db, "9780134685991", 1, inventory.BookChanges{TotalCopies: intPtr(0)})
+	assert.ErrorIs(t, err, inventory.ErrCopiesOnLoan)
+
+	book, removed, err := inventory.Update(db, "9780134685991", 1, inventory.BookChanges{TotalCopies: intPtr(4)})
+	require.NoError(t, err)
+	assert.False(t, removed)
+	assert.Equal(t, 4, book.TotalCopies)
+	assert.Equal(t, 3, book.AvailableCopies)
+}
+
+func stringPtr(s string) *string { return &s }
+
+// ✅ Test Update only changes the fields it is given and removes a book left with no copies
+func TestInventoryUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		changes  inventory.BookChanges
+		err      error
+		removed  bool
+		title    string
+		category string
+		copies   int
+	}{
+		{"no changes", inventory.BookChanges{}, nil, false, "Effective Java", "Programming", 2},
+		{"title only", inventory.BookChanges{Title: stringPtr("Effective Java, 3rd Edition")}, nil, false, "Effective Java, 3rd Edition", "Programming", 2},
+		{"category cleared", inventory.BookChanges{Category: stringPtr("")}, nil, false, "Effective Java", "", 2},
+		{"more copies", inventory.BookChanges{TotalCopies: intPtr(5)}, nil, false, "Effective Java", "Programming", 5},
+		{"fewer copies", inventory.BookChanges{TotalCopies: intPtr(1)}, nil, false, "Effective Java", "Programming", 1},
+		{"no copies left", inventory.BookChanges{TotalCopies: intPtr(0)}, nil, true, "Effective Java", "Programming", 0},
+		{"negative copies", inventory.BookChanges{TotalCopies: intPtr(-1)}, inventory.ErrInvalidCopyCount, false, "", "", 0},
+		{"too many new copies", inventory.BookChanges{TotalCopies: intPtr(2 + config.AppConfig.MaxNewCopies + 1)}, inventory.ErrTooManyCopies, false, "", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openInventoryDB(t)
+			_, _, err := inventory.AddCopies(db, models.Book{ISBN: "9780134685991", Title: "Effective Java", Category: "Programming", LibraryID: 1},
+				make([]inventory.NewItem, 2))
+			require.NoError(t, err)
+
+			book, removed, err := inventory.Update(db, "9780134685991", 1, tt.changes)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.removed, removed)
+			assert.Equal(t, tt.title, book.Title)
+			assert.Equal(t, tt.category, book.Category)
+			assert.Equal(t, tt.copies, book.TotalCopies)
+
+			_, err = inventory.LockBook(db, "9780134685991", 1)
+			if tt.removed {
+				assert.ErrorIs(t, err, inventory.ErrBookNotFound)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// ✅ Test copies keep their barcodes and counters follow the items
+func TestInventoryItems(t *testing.T) {
+	db := openInventoryDB(t)
+	book, _, err := inventory.AddCopies(db, models.Book{ISBN: "9780134685991", Title: "Effective Java", LibraryID: 1},
+		[]inventory.NewItem{{Barcode: "EJ-0001", Condition: "new"}, {}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, book.TotalCopies)
+
+	_, _, err = inventory.AddCopies(db, book, []inventory.NewItem{{Barcode: "EJ-0001"}})
+	assert.ErrorIs(t, err, inventory.ErrBarcodeTaken)
+	_, _, err = inventory.AddCopies(db, book, []inventory.NewItem{{Condition: "mint"}})
+	assert.ErrorIs(t, err, inventory.ErrInvalidCondition)
+
+	items, err := inventory.Items(db, book.ISBN, 1)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "new", items[0].Condition)
+	assert.Equal(t, "1-9780134685991-001", items[1].Barcode)
+
+	// The reserved copy is the one lent and the one that comes back
+	_, reserved, err := inventory.Reserve(db, book.ISBN, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "EJ-0001", reserved.Barcode)
+	_, err = inventory.Lend(db, reserved.ID)
+	require.NoError(t, err)
+	_, err = inventory.Lend(db, reserved.ID)
+	assert.ErrorIs(t, err, inventory.ErrItemNotAvailable)
+
+	book, err = inventory.Release(db, reserved.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, book.AvailableCopies)
+	_, err = inventory.Release(db, reserved.ID)
+	assert.ErrorIs(t, err, inventory.ErrAllCopiesAvailable)
+
+	// Withdrawing a chosen copy, then the last one, removes the book
+	book, removed, err := inventory.Withdraw(db, book.ISBN, 1, "EJ-0001")
+	require.NoError(t, err)
+	assert.False(t, removed)
+	assert.Equal(t, 1, book.TotalCopies)
+	_, removed, err = inventory.Withdraw(db, book.ISBN, 1, "")
+	require.NoError(t, err)
+	assert.True(t, removed)
+
+	items, err = inventory.Items(db, book.ISBN, 1)
+	require.NoError(t, err)
+	for _, item := range items {
+		assert.Equal(t, models.ItemWithdrawn, item.Status)
+	}
+}
+
+// ✅ Test generated barcodes continue after hand-entered ones in the same pattern
+func TestInventoryGeneratedBarcodes(t *testing.T) {
+	db := openInventoryDB(t)
+	book, _, err := inventory.AddCopies(db, models.Book{ISBN: "9780134685991", Title: "Effective Java", LibraryID: 1},
+		[]inventory.NewItem{{Barcode: "1-9780134685991-002"}})
+	require.NoError(t, err)
+
+	_, _, err = inventory.AddCopies(db, book, []inventory.NewItem{{}, {Barcode: "1-9780134685991-007"}, {}})
+	require.NoError(t, err)
+
+	items, err := inventory.Items(db, book.ISBN, 1)
+	require.NoError(t, err)
+	require.Len(t, items, 4)
+	assert.Equal(t, []string{"1-9780134685991-002", "1-9780134685991-003", "1-9780134685991-007", "1-9780134685991-008"},
+		[]string{items[0].Barcode, items[1].Barcode, items[2].Barcode, items[3].Barcode})
+}
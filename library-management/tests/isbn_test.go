@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"fmt"
+	"library-management/controllers"
+	"library-management/isbn"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/inventory"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ✅ Test ISBN-10 and ISBN-13 forms normalize to the same bare ISBN-13
+func TestISBNNormalize(t *testing.T) {
+	for _, raw := range []string{"9780134685991", "978-0-13-468599-1", " 978 0 13 468599 1 ", "0134685997", "0-13-468599-7"} {
+		normalized, err := isbn.Normalize(raw)
+		require.NoError(t, err, raw)
+		assert.Equal(t, "9780134685991", normalized, raw)
+	}
+
+	// An "X" check digit is accepted in either case
+	normalized, err := isbn.Normalize("0-8044-2957-x")
+	require.NoError(t, err)
+	assert.Equal(t, "9780804429573", normalized)
+
+	for _, raw := range []string{"", "12345", "9780134685992", "0134685996", "978013468599X", "97801346859911"} {
+		_, err := isbn.Normalize(raw)
+		assert.ErrorIs(t, err, isbn.ErrInvalid, raw)
+	}
+}
+
+// ✅ Test conversion between ISBN-10 and ISBN-13
+func TestISBNConvert(t *testing.T) {
+	isbn13, err := isbn.To13("080442957X")
+	require.NoError(t, err)
+	assert.Equal(t, "9780804429573", isbn13)
+
+	isbn10, err := isbn.To10("978-0-8044-2957-3")
+	require.NoError(t, err)
+	assert.Equal(t, "080442957X", isbn10)
+
+	_, err = isbn.To10("9791032300824")
+	assert.ErrorIs(t, err, isbn.ErrNoISBN10)
+	_, err = isbn.To13("0134685996")
+	assert.ErrorIs(t, err, isbn.ErrInvalid)
+}
+
+// ✅ Test handlers store and look up books by the normalized ISBN
+func TestHandlersNormalizeISBN(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+	bookScope := middleware.RequireLibraryRole("admin", middleware.LibraryFromBody("libraryid"))
+
+	body := fmt.Sprintf(`{"isbn": "0-8044-2957-X", "title": "Gardening", "libraryid": %d, "totalcopies": 1}`, f.library.ID)
+	w := f.serve(t, f.admin, http.MethodPost, "/book", "/book", body, bookScope, controllers.AddBook(f.db))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	body = fmt.Sprintf(`{"isbn": "978-0-8044-2957-3", "title": "Gardening", "libraryid": %d, "totalcopies": 1}`, f.library.ID)
+	w = f.serve(t, f.admin, http.MethodPost, "/book", "/book", body, bookScope, controllers.AddBook(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var book models.Book
+	require.NoError(t, f.db.Where("title = ?", "Gardening").First(&book).Error)
+	assert.Equal(t, "9780804429573", book.ISBN)
+	assert.Equal(t, 2, book.TotalCopies)
+
+	body = fmt.Sprintf(`{"isbn": "080442957x", "libraryid": %d}`, f.library.ID)
+	w = f.serve(t, f.reader, http.MethodPost, "/issue", "/issue", body,
+		middleware.RequireLibraryRole("user", middleware.LibraryFromBody("libraryid")), controllers.RequestIssue(f.db))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var request models.RequestEvent
+	require.NoError(t, f.db.Last(&request).Error)
+	assert.Equal(t, "9780804429573", request.BookID)
+
+	// A typo in the check digit is refused rather than creating another title
+	body = fmt.Sprintf(`{"isbn": "978-0-8044-2957-4", "title": "Gardening", "libraryid": %d, "totalcopies": 1}`, f.library.ID)
+	w = f.serve(t, f.admin, http.MethodPost, "/book", "/book", body, bookScope, controllers.AddBook(f.db))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	path := fmt.Sprintf("/book/not-an-isbn/items?library_id=%d", f.library.ID)
+	w = f.serve(t, f.admin, http.MethodGet, "/book/:isbn/items", path, "",
+		middleware.RequireLibraryRole("admin", middleware.LibraryFromQuery("library_id")), controllers.ListBookItems(f.db))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// ✅ Test existing rows are rewritten and duplicate books merged
+func TestNormalizeISBNs(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+
+	// Rows written before ISBNs were normalized
+	hyphenated := models.Book{ISBN: "978-0-13-468599-1", Title: "Effective Java", LibraryID: f.library.ID}
+	require.NoError(t, f.db.Create(&hyphenated).Error)
+	require.NoError(t, f.db.Create(&models.Item{Barcode: "EJ-OLD", BookID: hyphenated.ID, LibraryID: f.library.ID,
+		Status: models.ItemAvailable}).Error)
+	legacy := models.Book{ISBN: "0-8044-2957-X", Title: "Gardening", LibraryID: f.library.ID}
+	require.NoError(t, f.db.Create(&legacy).Error)
+	broken := models.Book{ISBN: "12345", Title: "Unknown", LibraryID: f.library.ID}
+	require.NoError(t, f.db.Create(&broken).Error)
+	require.NoError(t, f.db.Create(&models.IssueRegistry{ISBN: "0134685997", LibraryID: f.library.ID, ReaderID: f.reader.ID,
+		IssueStatus: models.LoanReturned}).Error)
+	require.NoError(t, f.db.Create(&models.RequestEvent{BookID: "0134685997", LibraryID: f.library.ID, ReaderID: f.reader.ID,
+		RequestType: "issue", Status: models.RequestRejected}).Error)
+
+	report, err := inventory.NormalizeISBNs(f.db, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Books)
+	assert.Equal(t, 1, report.Merged)
+	var unchanged models.Book
+	require.NoError(t, f.db.First(&unchanged, legacy.ID).Error)
+	assert.Equal(t, "0-8044-2957-X", unchanged.ISBN)
+
+	report, err = inventory.NormalizeISBNs(f.db, false)
+	require.NoError(t, err)
+	assert.Equal(t, inventory.NormalizeReport{Books: 1, Merged: 1, Requests: 1, Loans: 1, Invalid: []string{"12345"}}, report)
+
+	var books []models.Book
+	require.NoError(t, f.db.Order("id").Find(&books).Error)
+	require.Len(t, books, 3)
+	assert.Equal(t, "9780134685991", books[0].ISBN)
+	assert.Equal(t, 2, books[0].TotalCopies)
+	assert.Equal(t, "9780804429573", books[1].ISBN)
+	assert.Equal(t, "12345", books[2].ISBN)
+
+	var loans int64
+	require.NoError(t, f.db.Model(&models.IssueRegistry{}).Where("isbn = ?", "9780134685991").Count(&loans).Error)
+	assert.Equal(t, int64(1), loans)
+	var requestEvent models.RequestEvent
+	require.NoError(t, f.db.First(&requestEvent).Error)
+	assert.Equal(t, "9780134685991", requestEvent.BookID)
+}
@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"library-management/config"
+	"library-management/controllers"
+	"library-management/middleware"
+	"library-management/models"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// ✅ Test admins add copies with their barcodes, list them and withdraw one by barcode
+func TestBookItems(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+	bookScope := middleware.RequireLibraryRole("admin", middleware.LibraryFromBody("libraryid"))
+
+	body := fmt.Sprintf(`{"isbn": "9781492052593", "title": "Learning Go", "libraryid": %d,
+		"items": [{"barcode": "LG-1", "condition": "new"}, {"barcode": "LG-2"}]}`, f.library.ID)
+	w := f.serve(t, f.admin, http.MethodPost, "/book", "/book", body, bookScope, controllers.AddBook(f.db))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// A barcode already on another copy is refused
+	w = f.serve(t, f.admin, http.MethodPost, "/book", "/book", body, bookScope, controllers.AddBook(f.db))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// A plain copy count still works and gets generated barcodes
+	body = fmt.Sprintf(`{"isbn": "9781492052593", "title": "Learning Go", "libraryid": %d, "totalcopies": 1}`, f.library.ID)
+	w = f.serve(t, f.admin, http.MethodPost, "/book", "/book", body, bookScope, controllers.AddBook(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	listItems := func() []models.Item {
+		path := fmt.Sprintf("/book/9781492052593/items?library_id=%d", f.library.ID)
+		w := f.serve(t, f.admin, http.MethodGet, "/book/:isbn/items", path, "",
+			middleware.RequireLibraryRole("admin", middleware.LibraryFromQuery("library_id")), controllers.ListBookItems(f.db))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Items []models.Item `json:"items"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Items
+	}
+	items := listItems()
+	require.Len(t, items, 3)
+	assert.Equal(t, []string{"LG-1", "LG-2", fmt.Sprintf("%d-9781492052593-001", f.library.ID)},
+		[]string{items[0].Barcode, items[1].Barcode, items[2].Barcode})
+	assert.Equal(t, "new", items[0].Condition)
+	assert.Equal(t, "good", items[1].Condition)
+
+	// Withdraw a chosen copy; an unknown barcode is reported as missing
+	remove := func(barcode string) int {
+		body := fmt.Sprintf(`{"libraryid": %d, "barcode": %q}`, f.library.ID, barcode)
+		return f.serve(t, f.admin, http.MethodDelete, "/book/:isbn", "/book/9781492052593", body, bookScope, controllers.RemoveBook(f.db)).Code
+	}
+	assert.Equal(t, http.StatusNotFound, remove("LG-9"))
+	require.Equal(t, http.StatusOK, remove("LG-2"))
+
+	items = listItems()
+	assert.Equal(t, models.ItemWithdrawn, items[1].Status)
+	var book models.Book
+	require.NoError(t, f.db.Where("isbn = ?", "9781492052593").First(&book).Error)
+	assert.Equal(t, 2, book.TotalCopies)
+	assert.Equal(t, 2, book.AvailableCopies)
+}
+
+// ❌ Test one request cannot add more copies than the configured maximum
+func TestAddBookCopyLimit(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+	bookScope := middleware.RequireLibraryRole("admin", middleware.LibraryFromBody("libraryid"))
+	limit := config.AppConfig.MaxNewCopies
+
+	tests := []struct {
+		name   string
+		copies string
+		code   int
+	}{
+		{"at the limit", fmt.Sprintf(`"totalcopies": %d`, limit), http.StatusCreated},
+		{"count above the limit", fmt.Sprintf(`"totalcopies": %d`, limit+1), http.StatusBadRequest},
+		{"huge count", `"totalcopies": 2000000000`, http.StatusBadRequest},
+		{"items above the limit", `"items": [` + strings.Repeat(`{},`, limit) + `{}]`, http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"isbn": "9781492052593", "title": "Book %d", "libraryid": %d, %s}`, i, f.library.ID, tt.copies)
+			w := f.serve(t, f.admin, http.MethodPost, "/book", "/book", body, bookScope, controllers.AddBook(f.db))
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.code == http.StatusBadRequest {
+				assert.Contains(t, w.Body.String(), fmt.Sprintf("At most %d copies", limit))
+			}
+		})
+	}
+}
+
+// ✅ Test an update keeps fields it leaves out and removes a book set to no copies
+func TestUpdateBookPartial(t *testing.T) {
+	f := newCirculationFixture(t, 2)
+	bookScope := middleware.RequireLibraryRole("admin", middleware.LibraryFromBody("libraryid"))
+	require.NoError(t, f.db.Model(&f.book).Update("category", "Programming").Error)
+	update := func(fields string) (int, string) {
+		body := fmt.Sprintf(`{"libraryid": %d, %s}`, f.library.ID, fields)
+		w := f.serve(t, f.admin, http.MethodPut, "/book/:isbn", "/book/9780134685991", body, bookScope, controllers.UpdateBook(f.db))
+		return w.Code, w.Body.String()
+	}
+
+	code, body := update(`"title": "Effective Java, 3rd Edition"`)
+	require.Equal(t, http.StatusOK, code, body)
+	var book models.Book
+	require.NoError(t, f.db.First(&book, f.book.ID).Error)
+	assert.Equal(t, "Effective Java, 3rd Edition", book.Title)
+	assert.Equal(t, "Programming", book.Category)
+	assert.Equal(t, 2, book.TotalCopies)
+
+	code, body = update(`"totalcopies": 0`)
+	require.Equal(t, http.StatusOK, code, body)
+	assert.Contains(t, body, "Book removed from inventory")
+	assert.ErrorIs(t, f.db.First(&book, f.book.ID).Error, gorm.ErrRecordNotFound)
+}
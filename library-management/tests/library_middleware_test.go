@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"fmt"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scopedRouter serves one route behind RequireLibraryRole and echoes the resolved library
+func scopedRouter(role, route string, source middleware.LibrarySource, libraryIDs ...uint) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	handler := func(c *gin.Context) {
+		libraryID, _ := middleware.ScopedLibrary(c)
+		c.JSON(http.StatusOK, gin.H{"library_id": libraryID})
+	}
+	r.Handle(http.MethodPost, route, withUser(1, "admin", libraryIDs...), middleware.RequireLibraryRole(role, source), handler)
+	return r
+}
+
+func postScoped(r *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// ✅ Test the library is resolved from path, query and body
+func TestRequireLibraryRoleSources(t *testing.T) {
+	w := postScoped(scopedRouter("admin", "/libraries/:library", middleware.LibraryFromParam("library"), 3), "/libraries/3", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"library_id": 3}`, w.Body.String())
+
+	w = postScoped(scopedRouter("admin", "/books", middleware.LibraryFromQuery("library_id"), 3), "/books?library_id=3", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Body fields match case-insensitively, like encoding/json
+	w = postScoped(scopedRouter("admin", "/book", middleware.LibraryFromBody("libraryid"), 3), "/book", `{"ISBN": "1", "LibraryID": 3}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// ❌ Test requests outside the caller's libraries are refused
+func TestRequireLibraryRoleForbidden(t *testing.T) {
+	w := postScoped(scopedRouter("admin", "/book", middleware.LibraryFromBody("libraryid"), 3), "/book", `{"libraryid": 4}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "not assigned to this library")
+
+	w = postScoped(scopedRouter("user", "/book", middleware.LibraryFromBody("libraryid"), 3), "/book", `{"libraryid": 3}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Access denied")
+}
+
+// ❌ Test a missing or malformed library ID is a bad request
+func TestRequireLibraryRoleMissingLibrary(t *testing.T) {
+	w := postScoped(scopedRouter("admin", "/book", middleware.LibraryFromBody("libraryid"), 3), "/book", `{"isbn": "1"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Library ID is required")
+
+	w = postScoped(scopedRouter("admin", "/books", middleware.LibraryFromQuery("library_id"), 3), "/books?library_id=abc", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// ❌ Test bodies naming the library twice or too large to check are refused
+func TestRequireLibraryRoleAmbiguousBody(t *testing.T) {
+	r := scopedRouter("admin", "/fines/payments", middleware.LibraryFromBody("library_id"), 1)
+
+	w := postScoped(r, "/fines/payments", `{"library_id": 1, "LIBRARY_ID": 2}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Duplicate library_id")
+
+	w = postScoped(r, "/fines/payments", `{"library_id": 1, "library_id": 1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	padding := strings.Repeat(" ", 2<<20)
+	w = postScoped(r, "/fines/payments", `{"library_id": 1,`+padding+`"reader_id": 2}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+// ✅ Test request routes are scoped to the library the request was made in
+func TestRequireLibraryRoleFromRequestEvent(t *testing.T) {
+	db := SetupSQLiteDatabase(t)
+	request := models.RequestEvent{BookID: "1", LibraryID: 7, ReaderID: 2, RequestDate: 1, RequestType: "issue"}
+	require.NoError(t, db.Create(&request).Error)
+
+	source := middleware.LibraryFromRequestEvent(db, "id")
+	w := postScoped(scopedRouter("admin", "/issue/approve/:id", source, 7), fmt.Sprintf("/issue/approve/%d", request.ID), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = postScoped(scopedRouter("admin", "/issue/approve/:id", source, 8), fmt.Sprintf("/issue/approve/%d", request.ID), "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = postScoped(scopedRouter("admin", "/issue/approve/:id", source, 7), "/issue/approve/999", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// ✅ Test access tokens carry the user's libraries
+func TestAccessTokenCarriesLibraries(t *testing.T) {
+	token, _, err := utils.GenerateAccessToken(1, "admin", []uint{2, 5})
+	require.NoError(t, err)
+
+	claims, err := utils.ParseJWT(token)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{2, 5}, claims.LibraryIDs)
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"library-management/controllers"
+	"library-management/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ✅ Test readers only see their own requests
+func TestListMyRequests(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	other := models.User{Name: "Other", Email: "other@example.com", Role: "user", Password: "x"}
+	require.NoError(t, f.db.Create(&other).Error)
+
+	mine := f.createRequest(t, f.reader)
+	f.createRequest(t, other)
+
+	w := f.serve(t, f.reader, http.MethodGet, "/me/requests", "/me/requests", "", controllers.ListMyRequests(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Requests []map[string]interface{} `json:"requests"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Requests, 1)
+	assert.Equal(t, float64(mine.ID), response.Requests[0]["id"])
+	assert.Equal(t, "pending", response.Requests[0]["status"])
+
+	w = f.serve(t, f.reader, http.MethodGet, "/me/requests", "/me/requests?status=fulfilled", "", controllers.ListMyRequests(f.db))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Empty(t, response.Requests)
+}
+
+// ✅ Test a reader can cancel a pending request once, and only their own
+func TestCancelMyRequest(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	request := f.createRequest(t, f.reader)
+	path := fmt.Sprintf("/me/requests/%d", request.ID)
+
+	w := f.serve(t, f.admin, http.MethodDelete, "/me/requests/:id", path, "", controllers.CancelMyRequest(f.db))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = f.serve(t, f.reader, http.MethodDelete, "/me/requests/:id", path, "", controllers.CancelMyRequest(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	require.NoError(t, f.db.First(&request, request.ID).Error)
+	assert.Equal(t, models.RequestCancelled, request.Status)
+
+	w = f.serve(t, f.reader, http.MethodDelete, "/me/requests/:id", path, "", controllers.CancelMyRequest(f.db))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "Request is cancelled and cannot be cancelled")
+}
+
+// ✅ Test loans list the book, library and overdue flag
+func TestListMyLoans(t *testing.T) {
+	f := newCirculationFixture(t, 2)
+	now := time.Now()
+	loans := []models.IssueRegistry{
+		{ISBN: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID, IssueApproverID: f.admin.ID, IssueStatus: "issued",
+			IssueDate: now.AddDate(0, 0, -20).Unix(), ExpectedReturnDate: now.AddDate(0, 0, -6).Unix()},
+		{ISBN: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID, IssueApproverID: f.admin.ID, IssueStatus: "issued",
+			IssueDate: now.Unix(), ExpectedReturnDate: now.AddDate(0, 0, 14).Unix()},
+		{ISBN: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID, IssueApproverID: f.admin.ID, IssueStatus: "returned",
+			IssueDate: now.Unix(), ExpectedReturnDate: now.Unix(), ReturnDate: now.Unix()},
+	}
+	require.NoError(t, f.db.Create(&loans).Error)
+
+	w := f.serve(t, f.reader, http.MethodGet, "/me/loans", "/me/loans", "", controllers.ListMyLoans(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Loans []map[string]interface{} `json:"loans"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Loans, 2)
+	assert.Equal(t, "Effective Java", response.Loans[0]["title"])
+	assert.Equal(t, "Central", response.Loans[0]["library"])
+	assert.Equal(t, true, response.Loans[0]["overdue"])
+	assert.Equal(t, false, response.Loans[1]["overdue"])
+
+	// Admins see none of the reader's loans under their own identity
+	w = f.serve(t, f.admin, http.MethodGet, "/me/loans", "/me/loans", "", controllers.ListMyLoans(f.db))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Empty(t, response.Loans)
+}
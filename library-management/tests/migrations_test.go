@@ -0,0 +1,285 @@
+package tests
+
+import (
+	"library-management/migrations"
+	"library-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// ✅ Test every migration applies, rolls back and re-applies cleanly
+func TestMigrationsUpDown(t *testing.T) {
+	db := SetupSQLiteDatabase(t)
+	all := migrations.All()
+	require.NotEmpty(t, all)
+
+	pending, err := migrations.Pending(db)
+	require.NoError(t, err)
+	assert.Equal(t, 0, pending)
+
+	ran, err := migrations.Down(db, len(all))
+	require.NoError(t, err)
+	assert.Len(t, ran, len(all))
+	assert.False(t, db.Migrator().HasTable("books"))
+
+	pending, err = migrations.Pending(db)
+	require.NoError(t, err)
+	assert.Equal(t, len(all), pending)
+
+	ran, err = migrations.Up(db)
+	require.NoError(t, err)
+	assert.Len(t, ran, len(all))
+	assert.True(t, db.Migrator().HasTable("books"))
+}
+
+// ✅ Test status reports applied migrations in version order
+func TestMigrationsStatus(t *testing.T) {
+	db := SetupSQLiteDatabase(t)
+
+	_, err := migrations.Down(db, 1)
+	require.NoError(t, err)
+
+	statuses, err := migrations.Statuses(db)
+	require.NoError(t, err)
+	require.Len(t, statuses, len(migrations.All()))
+
+	for i, status := range statuses {
+		if i > 0 {
+			assert.Greater(t, status.Version, statuses[i-1].Version)
+		}
+		last := i == len(statuses)-1
+		assert.Equal(t, !last, status.Applied, "migration %04d_%s", status.Version, status.Name)
+	}
+}
+
+// Tables as AutoMigrate created them before loans recorded their library
+type legacyBook struct {
+	gorm.Model
+	ISBN            string `gorm:"not null"`
+	Title           string `gorm:"not null"`
+	TotalCopies     int
+	AvailableCopies int
+	LibraryID       uint `gorm:"index"`
+}
+
+func (legacyBook) TableName() string { return "books" }
+
+type legacyIssueRegistry struct {
+	gorm.Model
+	ISBN               string `gorm:"not null"`
+	ReaderID           uint   `gorm:"not null"`
+	IssueApproverID    uint   `gorm:"not null"`
+	IssueStatus        string `gorm:"type:varchar(50);not null"`
+	IssueDate          int64  `gorm:"not null"`
+	ExpectedReturnDate int64  `gorm:"not null"`
+	ReturnDate         int64  `gorm:"default:0"`
+	ReturnApproverID   uint   `gorm:"default:0"`
+}
+
+func (legacyIssueRegistry) TableName() string { return "issue_registries" }
+
+// ✅ Test a database set up before per-library loans gives its loans a library
+func TestInitialSchemaBackfillsLoanLibraries(t *testing.T) {
+	db := SetupSQLiteDatabase(t)
+	_, err := migrations.Down(db, len(migrations.All()))
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&legacyBook{}, &legacyIssueRegistry{}))
+
+	// One ISBN held by a single library, one held by two
+	require.NoError(t, db.Create(&[]legacyBook{
+		{ISBN: "12345", Title: "Go", TotalCopies: 1, LibraryID: 1},
+		{ISBN: "67890", Title: "Rust", TotalCopies: 1, AvailableCopies: 1, LibraryID: 1},
+		{ISBN: "67890", Title: "Rust", TotalCopies: 1, AvailableCopies: 1, LibraryID: 2},
+	}).Error)
+	require.NoError(t, db.Create(&[]legacyIssueRegistry{
+		{ISBN: "12345", ReaderID: 2, IssueApproverID: 1, IssueStatus: "issued", IssueDate: 1, ExpectedReturnDate: 2},
+		{ISBN: "67890", ReaderID: 2, IssueApproverID: 1, IssueStatus: "issued", IssueDate: 1, ExpectedReturnDate: 2},
+	}).Error)
+
+	_, err = migrations.Up(db)
+	require.NoError(t, err)
+
+	var libraries []uint
+	require.NoError(t, db.Table("issue_registries").Order("id").Pluck("COALESCE(library_id, 0)", &libraries).Error)
+	assert.Equal(t, []uint{1, 0}, libraries)
+}
+
+// migrateDownTo rolls back every migration newer than version
+func migrateDownTo(t *testing.T, db *gorm.DB, version int) {
+	steps := 0
+	for _, m := range migrations.All() {
+		if m.Version > version {
+			steps++
+		}
+	}
+	_, err := migrations.Down(db, steps)
+	require.NoError(t, err)
+}
+
+// ✅ Test the status backfill turns old disapprovals into rejections and expires approvals that never became loans
+func TestRequestStatusBackfill(t *testing.T) {
+	db := SetupSQLiteDatabase(t)
+	migrateDownTo(t, db, 3)
+
+	// Rows as the pre-status handlers left them
+	require.NoError(t, db.Exec(`INSERT INTO request_events (id, book_id, library_id, reader_id, request_date, request_type, deleted_at)
+		VALUES (1, '1', 1, 2, 1, 'issue', CURRENT_TIMESTAMP)`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO request_events (id, book_id, library_id, reader_id, request_date, request_type, approval_date)
+		VALUES (2, '1', 1, 2, 1, 'issue', 5)`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO request_events (id, book_id, library_id, reader_id, request_date, request_type)
+		VALUES (3, '1', 1, 2, 1, 'issue')`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO request_events (id, book_id, library_id, reader_id, request_date, request_type, approval_date)
+		VALUES (4, '2', 1, 2, 3, 'issue', 5)`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO request_events (id, book_id, library_id, reader_id, request_date, request_type, approval_date)
+		VALUES (5, '3', 1, 2, 3, 'issue', 5)`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO request_events (id, book_id, library_id, reader_id, request_date, request_type, approval_date)
+		VALUES (6, '5', 1, 2, 3, 'issue', 5)`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO issue_registries (id, isbn, library_id, reader_id, issue_approver_id, issue_status, issue_date, expected_return_date)
+		VALUES (1, '2', 1, 2, 1, 'issued', 6, 20), (2, '3', 2, 2, 1, 'issued', 6, 20), (3, '5', 0, 2, 1, 'issued', 6, 20)`).Error)
+
+	_, err := migrations.Up(db)
+	require.NoError(t, err)
+
+	var rows []struct {
+		ID     uint
+		Status string
+	}
+	statuses := func() {
+		rows = nil
+		require.NoError(t, db.Raw("SELECT id, status FROM request_events WHERE deleted_at IS NULL ORDER BY id").Scan(&rows).Error)
+	}
+	statuses()
+	require.Len(t, rows, 6)
+	assert.Equal(t, "rejected", rows[0].Status)
+	assert.Equal(t, "expired", rows[1].Status, "approved but never issued")
+	assert.Equal(t, "pending", rows[2].Status)
+	assert.Equal(t, "fulfilled", rows[3].Status)
+	assert.Equal(t, "expired", rows[4].Status, "issued only in another library")
+	assert.Equal(t, "fulfilled", rows[5].Status, "issued in no recorded library")
+
+	// Readers see why the corrected requests expired
+	var expired models.RequestEvent
+	require.NoError(t, db.First(&expired, 2).Error)
+	assert.Equal(t, "Approved but never issued", expired.ExpiryReason)
+	assert.NotNil(t, expired.ExpiredAt)
+
+	// Requests with a transition history were not backfilled and keep their status
+	require.NoError(t, db.Exec(`INSERT INTO request_events (id, book_id, library_id, reader_id, request_date, request_type, approval_date, status)
+		VALUES (7, '4', 1, 2, 3, 'issue', 5, 'fulfilled')`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO request_transitions (request_id, from_status, to_status, created_at)
+		VALUES (7, 'ready_for_pickup', 'fulfilled', CURRENT_TIMESTAMP)`).Error)
+	migrateDownTo(t, db, 14)
+	_, err = migrations.Up(db)
+	require.NoError(t, err)
+
+	statuses()
+	require.Len(t, rows, 7)
+	assert.Equal(t, "expired", rows[1].Status)
+	assert.Equal(t, "fulfilled", rows[6].Status)
+}
+
+// ✅ Test the item backfill gives loans, ready holds and pickups their own copies
+func TestItemsBackfill(t *testing.T) {
+	db := SetupSQLiteDatabase(t)
+	migrateDownTo(t, db, 10)
+
+	// Four copies: one on loan, one set aside for a hold, two on the shelf
+	require.NoError(t, db.Exec(`INSERT INTO books (id, isbn, title, library_id, total_copies, available_copies, created_at)
+		VALUES (1, '12345', 'Go', 1, 4, 2, CURRENT_TIMESTAMP)`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO issue_registries (id, isbn, library_id, reader_id, issue_approver_id, issue_status, issue_date, expected_return_date)
+		VALUES (1, '12345', 1, 2, 1, 'issued', 1, 2)`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO holds (id, isbn, library_id, reader_id, status, created_at, updated_at)
+		VALUES (1, '12345', 1, 3, 'ready', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`).Error)
+
+	_, err := migrations.Up(db)
+	require.NoError(t, err)
+
+	var items []models.Item
+	require.NoError(t, db.Order("id").Find(&items).Error)
+	require.Len(t, items, 4)
+	assert.Equal(t, []string{models.ItemOnLoan, models.ItemReserved, models.ItemAvailable, models.ItemAvailable},
+		[]string{items[0].Status, items[1].Status, items[2].Status, items[3].Status})
+	assert.Equal(t, "1-12345-001", items[0].Barcode)
+
+	var loan models.IssueRegistry
+	require.NoError(t, db.First(&loan, 1).Error)
+	require.NotNil(t, loan.ItemID)
+	assert.Equal(t, items[0].ID, *loan.ItemID)
+	var hold models.Hold
+	require.NoError(t, db.First(&hold, 1).Error)
+	require.NotNil(t, hold.ItemID)
+	assert.Equal(t, items[1].ID, *hold.ItemID)
+
+	var book models.Book
+	require.NoError(t, db.First(&book, 1).Error)
+	assert.Equal(t, 4, book.TotalCopies)
+	assert.Equal(t, 2, book.AvailableCopies)
+}
+
+// ✅ Test loans from before per-library loans get their copy, or are flagged without a library
+func TestLoanLibrariesBackfill(t *testing.T) {
+	db := SetupSQLiteDatabase(t)
+	migrateDownTo(t, db, 10)
+
+	// One ISBN held by a single library with its only copy out, one held by two libraries
+	require.NoError(t, db.Exec(`INSERT INTO books (id, isbn, title, library_id, total_copies, available_copies, created_at)
+		VALUES (1, '12345', 'Go', 1, 1, 0, CURRENT_TIMESTAMP)`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO books (id, isbn, title, library_id, total_copies, available_copies, created_at)
+		VALUES (2, '67890', 'Rust', 1, 1, 1, CURRENT_TIMESTAMP), (3, '67890', 'Rust', 2, 1, 1, CURRENT_TIMESTAMP)`).Error)
+	// Migration 1 placed the first loan; the second has no library
+	require.NoError(t, db.Exec(`INSERT INTO issue_registries (id, isbn, library_id, reader_id, issue_approver_id, issue_status, issue_date, expected_return_date)
+		VALUES (1, '12345', 1, 2, 1, 'issued', 1, 2), (2, '67890', 0, 2, 1, 'overdue', 1, 2)`).Error)
+
+	_, err := migrations.Up(db)
+	require.NoError(t, err)
+
+	var items []models.Item
+	require.NoError(t, db.Where("book_id = ?", 1).Find(&items).Error)
+	require.Len(t, items, 1)
+
+	var loans []models.IssueRegistry
+	require.NoError(t, db.Order("id").Find(&loans).Error)
+	require.Len(t, loans, 2)
+	assert.Equal(t, uint(1), loans[0].LibraryID)
+	require.NotNil(t, loans[0].ItemID)
+	assert.Equal(t, items[0].ID, *loans[0].ItemID)
+	assert.False(t, loans[0].NeedsRepair)
+
+	assert.Zero(t, loans[1].LibraryID)
+	assert.Nil(t, loans[1].ItemID)
+	assert.True(t, loans[1].NeedsRepair)
+}
+
+// ✅ Test ready holds from before hold requests get a request ready for pickup, dropped again on rollback
+func TestHoldRequestsBackfill(t *testing.T) {
+	db := SetupSQLiteDatabase(t)
+	migrateDownTo(t, db, 15)
+
+	require.NoError(t, db.Exec(`INSERT INTO holds (id, isbn, library_id, reader_id, status, ready_at, pickup_deadline, item_id, created_at, updated_at)
+		VALUES (1, '12345', 1, 2, 'ready', 10, 20, 7, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+		(2, '12345', 1, 3, 'waiting', NULL, NULL, NULL, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`).Error)
+
+	_, err := migrations.Up(db)
+	require.NoError(t, err)
+
+	var ready, waiting models.Hold
+	require.NoError(t, db.First(&ready, 1).Error)
+	require.NoError(t, db.First(&waiting, 2).Error)
+	assert.Nil(t, waiting.RequestID)
+	require.NotNil(t, ready.RequestID)
+
+	var request models.RequestEvent
+	require.NoError(t, db.First(&request, *ready.RequestID).Error)
+	assert.Equal(t, models.RequestReadyForPickup, request.Status)
+	assert.Equal(t, uint(2), request.ReaderID)
+	assert.Equal(t, uint(7), *request.ItemID)
+	assert.Equal(t, int64(20), *request.PickupDeadline)
+
+	migrateDownTo(t, db, 15)
+	var requests int64
+	require.NoError(t, db.Table("request_events").Count(&requests).Error)
+	assert.Zero(t, requests)
+}
@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"library-management/controllers"
+	"library-management/models"
+	"library-management/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ✅ Test bcrypt hashing round trip
+func TestHashPassword(t *testing.T) {
+	hash, err := utils.HashPassword("password123")
+	require.NoError(t, err)
+	assert.NotEqual(t, "password123", hash)
+
+	match, needsRehash := utils.CheckPassword(hash, "password123")
+	assert.True(t, match)
+	assert.False(t, needsRehash)
+
+	match, _ = utils.CheckPassword(hash, "wrongpassword")
+	assert.False(t, match)
+}
+
+// ✅ Test legacy plaintext passwords still match and are flagged for rehash
+func TestCheckPasswordLegacyPlaintext(t *testing.T) {
+	match, needsRehash := utils.CheckPassword("password123", "password123")
+	assert.True(t, match)
+	assert.True(t, needsRehash)
+
+	match, needsRehash = utils.CheckPassword("password123", "wrongpassword")
+	assert.False(t, match)
+	assert.False(t, needsRehash)
+}
+
+// ✅ Test Login upgrades a plaintext password to bcrypt
+func TestLoginRehashesLegacyPassword(t *testing.T) {
+	SetupTestDatabase()
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/auth/login", controllers.Login(TestDB))
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE email = \$1`).
+		WithArgs("legacy@example.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password", "role"}).
+			AddRow(4, "legacy@example.com", "password123", "user"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET "password"=\$1`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectRefreshTokenInsert()
+
+	req, _ := http.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email": "legacy@example.com", "password": "password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "token")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// ✅ Test Login with a bcrypt-hashed password does not rewrite it
+func TestLoginWithHashedPassword(t *testing.T) {
+	SetupTestDatabase()
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/auth/login", controllers.Login(TestDB))
+
+	hash, err := utils.HashPassword("password123")
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE email = \$1`).
+		WithArgs("hashed@example.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password", "role"}).
+			AddRow(5, "hashed@example.com", hash, "admin"))
+	expectRefreshTokenInsert()
+
+	req, _ := http.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email": "hashed@example.com", "password": "password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectRefreshTokenInsert expects the refresh token stored by a successful login
+func expectRefreshTokenInsert() {
+	mock.ExpectQuery(`SELECT "library_id" FROM "user_libraries"`).
+		WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "refresh_tokens"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+}
+
+// ✅ Test the registration endpoints never send back the password hash
+func TestRegisterResponsesOmitPassword(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+	owner := models.User{Name: "Owner", Email: "owner@example.com", Role: "owner", Password: "x"}
+	require.NoError(t, f.db.Create(&owner).Error)
+
+	tests := []struct {
+		name    string
+		user    models.User
+		path    string
+		handler gin.HandlerFunc
+		body    string
+		key     string
+	}{
+		{"owner", owner, "/owner/register", controllers.RegisterOwnerNew(f.db),
+			`{"name": "Second", "email": "second@example.com", "password": "secret123", "role": "owner"}`, "owner"},
+		{"admin", owner, "/admin/register", controllers.RegisterAdmin(f.db),
+			fmt.Sprintf(`{"name": "Clerk", "email": "clerk@example.com", "password": "secret123", "library_ids": [%d]}`, f.library.ID), "admin"},
+		{"user", f.admin, "/user/register", controllers.RegisterUser(f.db),
+			fmt.Sprintf(`{"name": "Ann", "email": "ann@example.com", "password": "secret123", "library_ids": [%d]}`, f.library.ID), "user"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := f.serve(t, tt.user, http.MethodPost, tt.path, tt.path, tt.body, tt.handler)
+			require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+			var response map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			var created map[string]interface{}
+			require.NoError(t, json.Unmarshal(response[tt.key], &created))
+			assert.NotEmpty(t, created["Email"])
+			assert.NotContains(t, created, "password")
+			assert.NotContains(t, created, "Password")
+			assert.NotContains(t, w.Body.String(), "$2a$")
+		})
+	}
+}
+
+// ❌ Test creating an owner with any other role, or none, is refused
+func TestRegisterOwnerRejectsOtherRoles(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+	owner := models.User{Name: "Owner", Email: "owner@example.com", Role: "owner", Password: "x"}
+	require.NoError(t, f.db.Create(&owner).Error)
+
+	for _, role := range []string{`"role": "admin", `, `"role": "Owner", `, ""} {
+		body := `{` + role + `"name": "Second", "email": "second@example.com", "password": "secret123"}`
+		w := f.serve(t, owner, http.MethodPost, "/owner", "/owner", body, controllers.RegisterOwnerNew(f.db))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), "Invalid role, must be 'owner'")
+	}
+
+	var count int64
+	require.NoError(t, f.db.Model(&models.User{}).Where("email = ?", "second@example.com").Count(&count).Error)
+	assert.Zero(t, count)
+}
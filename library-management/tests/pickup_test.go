@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"library-management/config"
+	"library-management/controllers"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/availability"
+	"library-management/services/policies"
+	"library-management/services/requests"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ✅ Test the pickup report counts collected and uncollected approvals
+func TestPickupReport(t *testing.T) {
+	f := newCirculationFixture(t, 2)
+	second := f.createReader(t, "second")
+	collected := f.createRequest(t, f.reader)
+	forgotten := f.createRequest(t, second)
+	for _, request := range []uint{collected.ID, forgotten.ID} {
+		w := f.serve(t, f.admin, http.MethodPut, "/issue/approve/:id", fmt.Sprintf("/issue/approve/%d", request), "", controllers.ApproveIssue(f.db))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// The reader with a copy set aside is told to collect it
+	forecast, err := availability.For(f.db, f.book.ISBN, f.library.ID, second.ID, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, availability.Ready, forecast.Status)
+	assert.NotNil(t, forecast.PickupDeadline)
+
+	require.Equal(t, http.StatusOK, f.checkout(t, collected.ID).Code)
+	expired, err := requests.ExpireStale(f.db, time.Now().Add(config.AppConfig.PickupExpiry+time.Hour), 0, config.AppConfig.PickupExpiry)
+	require.NoError(t, err)
+	require.Equal(t, 1, expired)
+	assert.Equal(t, 1, f.availableCopies(t))
+
+	// An approval the status backfill expired was never ready for pickup
+	approved := time.Now().Unix()
+	require.NoError(t, f.db.Create(&models.RequestEvent{BookID: f.book.ISBN, LibraryID: f.library.ID, ReaderID: second.ID,
+		RequestDate: approved, RequestType: "issue", ApprovalDate: &approved, Status: models.RequestExpired}).Error)
+
+	path := fmt.Sprintf("/reports/pickups?library_id=%d", f.library.ID)
+	w := f.serve(t, f.admin, http.MethodGet, "/reports/pickups", path, "",
+		middleware.RequireLibraryRole("admin", middleware.LibraryFromQuery("library_id")), controllers.PickupReport(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var report struct {
+		Pickups     requests.PickupStats     `json:"pickups"`
+		Uncollected []map[string]interface{} `json:"uncollected"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, requests.PickupStats{Approved: 2, Collected: 1, Uncollected: 1}, report.Pickups)
+	require.Len(t, report.Uncollected, 1)
+	assert.Equal(t, float64(forgotten.ID), report.Uncollected[0]["id"])
+
+	// Approvals before the cut-off are left out
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	w = f.serve(t, f.admin, http.MethodGet, "/reports/pickups", path+"&since="+tomorrow, "",
+		middleware.RequireLibraryRole("admin", middleware.LibraryFromQuery("library_id")), controllers.PickupReport(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Zero(t, report.Pickups.Approved)
+
+	w = f.serve(t, f.admin, http.MethodGet, "/reports/pickups", path+"&since=yesterday", "",
+		middleware.RequireLibraryRole("admin", middleware.LibraryFromQuery("library_id")), controllers.PickupReport(f.db))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// approvedRequest creates a request for the fixture book and approves it, so a
+// copy is set aside for the reader
+func (f circulationFixture) approvedRequest(t *testing.T, reader models.User) models.RequestEvent {
+	request := f.createRequest(t, reader)
+	w := f.serve(t, f.admin, http.MethodPut, "/issue/approve/:id", fmt.Sprintf("/issue/approve/%d", request.ID), "", controllers.ApproveIssue(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, f.db.First(&request, request.ID).Error)
+	return request
+}
+
+// ❌ Test a copy set aside is only handed over to an eligible reader with a request ready for pickup
+func TestCheckoutRejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		approve   bool
+		prepare   func(t *testing.T, f circulationFixture, request models.RequestEvent)
+		code      int
+		status    string // Request status afterwards
+		available int    // Copies on the shelf afterwards, of 2
+	}{
+		{name: "not approved yet", code: http.StatusConflict, status: models.RequestPending, available: 2},
+		{name: "library the admin does not manage", approve: true,
+			prepare: func(t *testing.T, f circulationFixture, request models.RequestEvent) {
+				other := models.Library{Name: "Branch"}
+				require.NoError(t, f.db.Create(&other).Error)
+				require.NoError(t, f.db.Model(&request).Update("library_id", other.ID).Error)
+			},
+			code: http.StatusForbidden, status: models.RequestReadyForPickup, available: 1},
+		{name: "reader suspended since approval", approve: true,
+			prepare: func(t *testing.T, f circulationFixture, request models.RequestEvent) {
+				require.NoError(t, f.db.Model(&f.reader).Update("status", models.AccountSuspended).Error)
+			},
+			code: http.StatusForbidden, status: models.RequestReadyForPickup, available: 1},
+		{name: "reader reached the loan limit since approval", approve: true,
+			prepare: func(t *testing.T, f circulationFixture, request models.RequestEvent) {
+				_, err := policies.Save(f.db, models.LibraryPolicy{LibraryID: f.library.ID, MaxLoans: intPtr(1)})
+				require.NoError(t, err)
+				f.createLoan(t, time.Now().AddDate(0, 0, 7))
+			},
+			code: http.StatusForbidden, status: models.RequestReadyForPickup, available: 0},
+		{name: "pickup deadline passed", approve: true,
+			prepare: func(t *testing.T, f circulationFixture, request models.RequestEvent) {
+				expired, err := requests.ExpireStale(f.db, time.Now().Add(config.AppConfig.PickupExpiry+time.Hour), 0, config.AppConfig.PickupExpiry)
+				require.NoError(t, err)
+				require.Equal(t, 1, expired)
+			},
+			code: http.StatusConflict, status: models.RequestExpired, available: 2},
+		{name: "cancelled by the reader",
+			prepare: func(t *testing.T, f circulationFixture, request models.RequestEvent) {
+				require.NoError(t, requests.Transition(f.db, &request, models.RequestCancelled, &f.reader.ID, ""))
+			},
+			code: http.StatusConflict, status: models.RequestCancelled, available: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newCirculationFixture(t, 2)
+			var request models.RequestEvent
+			if tt.approve {
+				request = f.approvedRequest(t, f.reader)
+			} else {
+				request = f.createRequest(t, f.reader)
+			}
+			if tt.prepare != nil {
+				tt.prepare(t, f, request)
+			}
+
+			assert.Equal(t, tt.code, f.checkout(t, request.ID).Code)
+			require.NoError(t, f.db.First(&request, request.ID).Error)
+			assert.Equal(t, tt.status, request.Status)
+			assert.Nil(t, request.IssueID)
+			assert.Equal(t, tt.available, f.availableCopies(t))
+		})
+	}
+}
+
+// ✅ Test requests ready for pickup expire exactly at their deadline, and only when pickup expiry is on
+func TestPickupExpiry(t *testing.T) {
+	pickup := config.AppConfig.PickupExpiry
+	tests := []struct {
+		name      string
+		after     time.Duration // Time after approval the sweep runs
+		pickupFor time.Duration
+		collected bool // The reader collected the book before the sweep
+		expired   int
+	}{
+		{name: "before the deadline", after: pickup - time.Hour, pickupFor: pickup},
+		{name: "after the deadline", after: pickup + time.Hour, pickupFor: pickup, expired: 1},
+		{name: "pickup expiry disabled", after: 30 * 24 * time.Hour},
+		{name: "collected in time", after: pickup + time.Hour, pickupFor: pickup, collected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newCirculationFixture(t, 1)
+			request := f.approvedRequest(t, f.reader)
+			if tt.collected {
+				require.Equal(t, http.StatusOK, f.checkout(t, request.ID).Code)
+			}
+
+			expired, err := requests.ExpireStale(f.db, time.Now().Add(tt.after), 0, tt.pickupFor)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expired, expired)
+
+			require.NoError(t, f.db.First(&request, request.ID).Error)
+			if tt.expired == 0 {
+				assert.NotEqual(t, models.RequestExpired, request.Status)
+				assert.Equal(t, 0, f.availableCopies(t))
+				return
+			}
+			assert.Equal(t, models.RequestExpired, request.Status)
+			assert.Equal(t, "Not collected within 3 days of approval", request.ExpiryReason)
+			assert.Equal(t, 1, f.availableCopies(t))
+
+			var item models.Item
+			require.NoError(t, f.db.First(&item, *request.ItemID).Error)
+			assert.Equal(t, models.ItemAvailable, item.Status)
+		})
+	}
+}
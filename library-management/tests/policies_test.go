@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"library-management/controllers"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/services/policies"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func intPtr(n int) *int { return &n }
+
+// policyRequest calls a policy route the way the router chains it
+func (f circulationFixture) policyRequest(t *testing.T, user models.User, method, route, path, body string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	return f.serve(t, user, method, route, path, body,
+		middleware.RequireLibraryRole("admin|owner", middleware.LibraryFromParam("id")), handler)
+}
+
+// ✅ Test more specific rules override the base rule field by field
+func TestPolicyResolution(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	defaults := policies.Defaults()
+	libraryID := f.library.ID
+
+	for _, rule := range []models.LibraryPolicy{
+		{LibraryID: libraryID, LoanPeriodDays: intPtr(21), MaxLoans: intPtr(3)},
+		{LibraryID: libraryID, Tier: "premium", MaxLoans: intPtr(10)},
+		{LibraryID: libraryID, Category: "reference", LoanPeriodDays: intPtr(2)},
+		{LibraryID: libraryID, Category: "reference", Tier: "premium", LoanPeriodDays: intPtr(5)},
+		{LibraryID: libraryID + 1, LoanPeriodDays: intPtr(99)},
+	} {
+		_, err := policies.Save(f.db, rule)
+		require.NoError(t, err)
+	}
+
+	base, err := policies.Resolve(f.db, libraryID, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, 21, base.LoanPeriodDays)
+	assert.Equal(t, 3, base.MaxLoans)
+	assert.Equal(t, defaults.MaxRenewals, base.MaxRenewals)
+
+	premium, err := policies.Resolve(f.db, libraryID, "fiction", "premium")
+	require.NoError(t, err)
+	assert.Equal(t, 21, premium.LoanPeriodDays)
+	assert.Equal(t, 10, premium.MaxLoans)
+
+	reference, err := policies.Resolve(f.db, libraryID, "reference", "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, reference.LoanPeriodDays)
+	assert.Equal(t, 3, reference.MaxLoans)
+
+	both, err := policies.Resolve(f.db, libraryID, "reference", "premium")
+	require.NoError(t, err)
+	assert.Equal(t, 5, both.LoanPeriodDays)
+	assert.Equal(t, 10, both.MaxLoans)
+
+	// Saving the same scope again replaces the rule
+	_, err = policies.Save(f.db, models.LibraryPolicy{LibraryID: libraryID, LoanPeriodDays: intPtr(7)})
+	require.NoError(t, err)
+	base, err = policies.Resolve(f.db, libraryID, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, 7, base.LoanPeriodDays)
+	assert.Equal(t, defaults.MaxLoans, base.MaxLoans)
+}
+
+// ✅ Test admins and owners manage the policy through the API and issues follow it
+func TestLibraryPolicyAPI(t *testing.T) {
+	f := newCirculationFixture(t, 2)
+	route := "/libraries/:id/policies"
+	path := fmt.Sprintf("/libraries/%d/policies", f.library.ID)
+
+	w := f.policyRequest(t, f.admin, http.MethodPut, route, path, `{"loan_period_days": 0, "max_loans": -1}`, controllers.SaveLibraryPolicy(f.db))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "loan_period_days must be positive")
+	assert.Contains(t, w.Body.String(), "max_loans cannot be negative")
+
+	w = f.policyRequest(t, f.admin, http.MethodPut, route, path, `{"loan_period_days": 7, "max_loans": 1}`, controllers.SaveLibraryPolicy(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Owners manage every library without being a member of it
+	owner := models.User{Name: "Owner", Email: "owner@example.com", Role: "owner", Password: "x"}
+	require.NoError(t, f.db.Create(&owner).Error)
+	r := gin.New()
+	r.GET(route, withUser(owner.ID, owner.Role), middleware.RequireLibraryRole("admin|owner", middleware.LibraryFromParam("id")), controllers.GetLibraryPolicy(f.db))
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Effective policies.Policy        `json:"effective"`
+		Rules     []models.LibraryPolicy `json:"rules"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 7, response.Effective.LoanPeriodDays)
+	require.Len(t, response.Rules, 1)
+	require.NotNil(t, response.Rules[0].UpdatedByID)
+	assert.Equal(t, f.admin.ID, *response.Rules[0].UpdatedByID)
+
+	// The loan period and loan limit now come from the library's policy
+	issue := func() *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"user_id": %d, "library_id": %d}`, f.reader.ID, f.library.ID)
+		return f.serve(t, f.admin, http.MethodPost, "/issue/book/:isbn", "/issue/book/"+f.book.ISBN, body,
+			middleware.RequireLibraryRole("admin", middleware.LibraryFromBody("library_id")), controllers.IssueBookToUser(f.db))
+	}
+	require.Equal(t, http.StatusOK, issue().Code)
+	var loan models.IssueRegistry
+	require.NoError(t, f.db.Where("reader_id = ?", f.reader.ID).First(&loan).Error)
+	assert.Equal(t, loan.IssueDate+int64(7*24*time.Hour/time.Second), loan.ExpectedReturnDate)
+
+	w = issue()
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "loan_limit")
+
+	issueBody := fmt.Sprintf(`{"isbn": "%s", "libraryid": %d}`, f.book.ISBN, f.library.ID)
+	w = f.serve(t, f.reader, http.MethodPost, "/issue", "/issue", issueBody,
+		middleware.RequireLibraryRole("user", middleware.LibraryFromBody("libraryid")), controllers.RequestIssue(f.db))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = f.policyRequest(t, f.admin, http.MethodDelete, "/libraries/:id/policies/:rule_id",
+		fmt.Sprintf("%s/%d", path, response.Rules[0].ID), "", controllers.DeleteLibraryPolicy(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = f.policyRequest(t, f.admin, http.MethodDelete, "/libraries/:id/policies/:rule_id",
+		fmt.Sprintf("%s/%d", path, response.Rules[0].ID), "", controllers.DeleteLibraryPolicy(f.db))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// ❌ Test admins cannot change the policy of another library
+func TestLibraryPolicyOtherLibrary(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	other := models.Library{Name: "Branch"}
+	require.NoError(t, f.db.Create(&other).Error)
+
+	path := fmt.Sprintf("/libraries/%d/policies", other.ID)
+	w := f.policyRequest(t, f.admin, http.MethodPut, "/libraries/:id/policies", path, `{"max_loans": 1}`, controllers.SaveLibraryPolicy(f.db))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// ✅ Test tier and category rules apply to renewals and the hold pickup window
+func TestMemberTierPolicy(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	require.NoError(t, f.db.Model(&f.book).Update("category", "reference").Error)
+	_, err := policies.Save(f.db, models.LibraryPolicy{LibraryID: f.library.ID, Category: "reference", MaxRenewals: intPtr(0)})
+	require.NoError(t, err)
+	_, err = policies.Save(f.db, models.LibraryPolicy{LibraryID: f.library.ID, Category: "reference", Tier: "staff", MaxRenewals: intPtr(1)})
+	require.NoError(t, err)
+
+	loan := f.createLoan(t, time.Now().AddDate(0, 0, 3))
+	assert.Equal(t, http.StatusConflict, f.renewMine(t, f.reader, loan.ID))
+
+	path := fmt.Sprintf("/libraries/%d/members/%d/tier", f.library.ID, f.reader.ID)
+	w := f.policyRequest(t, f.admin, http.MethodPut, "/libraries/:id/members/:user_id/tier", path, `{"tier": "staff"}`, controllers.SetMemberTier(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusOK, f.renewMine(t, f.reader, loan.ID))
+	assert.Equal(t, http.StatusConflict, f.renewMine(t, f.reader, loan.ID))
+
+	path = fmt.Sprintf("/libraries/%d/members/%d/tier", f.library.ID, 9999)
+	w = f.policyRequest(t, f.admin, http.MethodPut, "/libraries/:id/members/:user_id/tier", path, `{"tier": "staff"}`, controllers.SetMemberTier(f.db))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
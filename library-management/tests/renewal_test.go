@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"fmt"
+	"library-management/config"
+	"library-management/controllers"
+	"library-management/middleware"
+	"library-management/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ✅ Test readers can renew up to the limit and every renewal is recorded
+func TestRenewMyLoanLimit(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	due := time.Now().AddDate(0, 0, 3)
+	loan := f.createLoan(t, due)
+
+	for i := 0; i < config.AppConfig.MaxRenewals; i++ {
+		require.Equal(t, http.StatusOK, f.renewMine(t, f.reader, loan.ID))
+	}
+	assert.Equal(t, http.StatusConflict, f.renewMine(t, f.reader, loan.ID))
+
+	require.NoError(t, f.db.First(&loan, loan.ID).Error)
+	assert.Equal(t, config.AppConfig.MaxRenewals, loan.RenewalCount)
+	expected := due.AddDate(0, 0, config.AppConfig.LoanPeriodDays*config.AppConfig.MaxRenewals).Unix()
+	assert.Equal(t, expected, loan.ExpectedReturnDate)
+
+	var renewals []models.LoanRenewal
+	require.NoError(t, f.db.Where("issue_id = ?", loan.ID).Order("id").Find(&renewals).Error)
+	require.Len(t, renewals, config.AppConfig.MaxRenewals)
+	assert.Equal(t, due.Unix(), renewals[0].PreviousDueDate)
+	assert.Equal(t, renewals[0].NewDueDate, renewals[1].PreviousDueDate)
+	assert.Equal(t, f.reader.ID, renewals[0].RenewedByID)
+}
+
+// ❌ Test renewal is refused for other readers' loans, returned loans and requested titles
+func TestRenewMyLoanRefused(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	loan := f.createLoan(t, time.Now().AddDate(0, 0, 3))
+
+	other := models.User{Name: "Other", Email: "other@example.com", Role: "user", Password: "x"}
+	require.NoError(t, f.db.Create(&other).Error)
+	assert.Equal(t, http.StatusNotFound, f.renewMine(t, other, loan.ID))
+
+	// Another reader is waiting for the title
+	waiting := f.createRequest(t, other)
+	assert.Equal(t, http.StatusConflict, f.renewMine(t, f.reader, loan.ID))
+
+	// Once that request is no longer open the loan can be renewed again
+	require.NoError(t, f.db.Model(&waiting).Update("status", models.RequestCancelled).Error)
+	assert.Equal(t, http.StatusOK, f.renewMine(t, f.reader, loan.ID))
+
+	require.NoError(t, f.db.Model(&loan).Update("issue_status", "returned").Error)
+	assert.Equal(t, http.StatusBadRequest, f.renewMine(t, f.reader, loan.ID))
+}
+
+// ✅ Test an overdue loan is renewed from today
+func TestRenewOverdueLoan(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	loan := f.createLoan(t, time.Now().AddDate(0, 0, -5))
+
+	require.Equal(t, http.StatusOK, f.renewMine(t, f.reader, loan.ID))
+	require.NoError(t, f.db.First(&loan, loan.ID).Error)
+	assert.Greater(t, loan.ExpectedReturnDate, time.Now().AddDate(0, 0, config.AppConfig.LoanPeriodDays-1).Unix())
+}
+
+// ✅ Test admins renew loans only in their own libraries
+func TestAdminRenewLoan(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	loan := f.createLoan(t, time.Now().AddDate(0, 0, 3))
+	scope := middleware.RequireLibraryRole("admin", middleware.LibraryFromLoan(f.db, "id"))
+
+	path := fmt.Sprintf("/loans/%d/renew", loan.ID)
+	w := f.serve(t, f.admin, http.MethodPost, "/loans/:id/renew", path, "", scope, controllers.RenewLoan(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = f.serve(t, f.admin, http.MethodGet, "/loans/:id/renewals", fmt.Sprintf("/loans/%d/renewals", loan.ID), "", scope, controllers.ListLoanRenewals(f.db))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"renewal_count":1`)
+
+	otherLoan := models.IssueRegistry{ISBN: f.book.ISBN, LibraryID: f.library.ID + 1, ReaderID: f.reader.ID, IssueStatus: "issued",
+		IssueDate: 1, ExpectedReturnDate: time.Now().Unix()}
+	require.NoError(t, f.db.Create(&otherLoan).Error)
+	w = f.serve(t, f.admin, http.MethodPost, "/loans/:id/renew", fmt.Sprintf("/loans/%d/renew", otherLoan.ID), "", scope, controllers.RenewLoan(f.db))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
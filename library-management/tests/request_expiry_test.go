@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"library-management/config"
+	"library-management/controllers"
+	"library-management/jobs"
+	"library-management/models"
+	"library-management/services/inventory"
+	"library-management/services/requests"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ✅ Test pending requests expire after the configured wait and readers see why
+func TestExpirePendingRequests(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	stale := f.createRequest(t, f.reader)
+	fresh := f.createRequest(t, f.reader)
+	old := time.Now().Add(-config.AppConfig.RequestExpiry - time.Hour).Unix()
+	require.NoError(t, f.db.Model(&stale).Update("request_date", old).Error)
+
+	require.NoError(t, jobs.ExpireRequests(context.Background(), f.db, time.Now()))
+	require.NoError(t, f.db.First(&stale, stale.ID).Error)
+	require.NoError(t, f.db.First(&fresh, fresh.ID).Error)
+	assert.Equal(t, models.RequestExpired, stale.Status)
+	assert.Equal(t, "Not approved within 7 days", stale.ExpiryReason)
+	assert.Equal(t, models.RequestPending, fresh.Status)
+
+	history, err := requests.History(f.db, stale.ID)
+	require.NoError(t, err)
+	last := history[len(history)-1]
+	assert.Equal(t, models.RequestExpired, last.ToStatus)
+	assert.Nil(t, last.ActorID)
+
+	w := f.serve(t, f.reader, http.MethodGet, "/me/requests", "/me/requests", "", controllers.ListMyRequests(f.db))
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Requests []map[string]interface{} `json:"requests"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Requests, 2)
+	assert.Contains(t, response.Requests[0], "expires_at")
+	assert.Equal(t, stale.ExpiryReason, response.Requests[1]["expiry_reason"])
+}
+
+// ✅ Test an uncollected approved request gives its copy to the next reader in the queue
+func TestExpireUncollectedRequest(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	next := f.createReader(t, "next")
+	require.NoError(t, f.db.Create(&models.Hold{ISBN: f.book.ISBN, LibraryID: f.library.ID, ReaderID: next.ID, Status: models.HoldWaiting}).Error)
+
+	request := f.createRequest(t, f.reader)
+	_, item, err := inventory.Reserve(f.db, f.book.ISBN, f.library.ID)
+	require.NoError(t, err)
+	approved := time.Now().Add(-config.AppConfig.PickupExpiry - time.Hour).Unix()
+	require.NoError(t, requests.Transition(f.db, &request, models.RequestApproved, &f.admin.ID, ""))
+	require.NoError(t, requests.Transition(f.db, &request, models.RequestReadyForPickup, &f.admin.ID, ""))
+	require.NoError(t, f.db.Model(&request).Updates(map[string]interface{}{"approval_date": approved, "item_id": item.ID}).Error)
+
+	expired, err := requests.ExpireStale(f.db, time.Now(), 0, config.AppConfig.PickupExpiry)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+
+	// The same copy is now set aside for the next reader
+	require.NoError(t, f.db.First(&request, request.ID).Error)
+	require.NotNil(t, f.hold(t, next).ItemID)
+	assert.Equal(t, item.ID, *f.hold(t, next).ItemID)
+	assert.Equal(t, models.RequestExpired, request.Status)
+	assert.Equal(t, "Not collected within 3 days of approval", request.ExpiryReason)
+	assert.Equal(t, models.HoldReady, f.hold(t, next).Status)
+	assert.Equal(t, 0, f.availableCopies(t))
+
+	// Nothing is left to expire on a second run
+	expired, err = requests.ExpireStale(f.db, time.Now(), 0, config.AppConfig.PickupExpiry)
+	require.NoError(t, err)
+	assert.Zero(t, expired)
+}
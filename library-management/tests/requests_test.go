@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"library-management/controllers"
+	"library-management/models"
+	"library-management/services/requests"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ✅ Test the request state machine only allows the documented moves
+func TestRequestTransitions(t *testing.T) {
+	for _, to := range []string{models.RequestApproved, models.RequestRejected, models.RequestCancelled, models.RequestExpired} {
+		assert.True(t, requests.CanTransition(models.RequestPending, to), to)
+	}
+	assert.True(t, requests.CanTransition(models.RequestApproved, models.RequestFulfilled))
+	assert.True(t, requests.CanTransition(models.RequestApproved, models.RequestReadyForPickup))
+	assert.True(t, requests.CanTransition(models.RequestReadyForPickup, models.RequestExpired))
+
+	assert.False(t, requests.CanTransition(models.RequestPending, models.RequestFulfilled))
+	assert.False(t, requests.CanTransition(models.RequestRejected, models.RequestApproved))
+	assert.False(t, requests.CanTransition(models.RequestFulfilled, models.RequestCancelled))
+	assert.False(t, requests.CanTransition(models.RequestApproved, models.RequestApproved))
+	assert.False(t, requests.CanTransition(models.RequestReadyForPickup, models.RequestRejected))
+}
+
+// ✅ Test every status change is recorded with its actor and reason
+func TestRequestHistory(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	request := models.RequestEvent{BookID: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID, RequestDate: 1, RequestType: "issue"}
+	require.NoError(t, requests.Create(f.db, &request, &f.reader.ID))
+	assert.Equal(t, models.RequestPending, request.Status)
+
+	require.NoError(t, requests.Transition(f.db, &request, models.RequestCancelled, &f.reader.ID, "Changed my mind"))
+
+	err := requests.Transition(f.db, &request, models.RequestApproved, &f.admin.ID, "")
+	assert.True(t, errors.Is(err, requests.ErrInvalidTransition))
+
+	var stored models.RequestEvent
+	require.NoError(t, f.db.First(&stored, request.ID).Error)
+	assert.Equal(t, models.RequestCancelled, stored.Status)
+
+	history, err := requests.History(f.db, request.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "", history[0].FromStatus)
+	assert.Equal(t, models.RequestPending, history[0].ToStatus)
+	assert.Equal(t, models.RequestPending, history[1].FromStatus)
+	assert.Equal(t, models.RequestCancelled, history[1].ToStatus)
+	assert.Equal(t, "Changed my mind", history[1].Reason)
+	require.NotNil(t, history[1].ActorID)
+	assert.Equal(t, f.reader.ID, *history[1].ActorID)
+}
+
+// ✅ Test approval sets a copy aside and checkout at the desk fulfils the request
+func TestApproveThenCheckout(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	request := models.RequestEvent{BookID: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID, RequestDate: 1, RequestType: "issue"}
+	require.NoError(t, requests.Create(f.db, &request, &f.reader.ID))
+
+	// Nothing can be handed over before approval
+	assert.Equal(t, http.StatusConflict, f.checkout(t, request.ID).Code)
+
+	w := f.serve(t, f.admin, http.MethodPut, "/issue/approve/:id", fmt.Sprintf("/issue/approve/%d", request.ID), "", controllers.ApproveIssue(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	require.NoError(t, f.db.First(&request, request.ID).Error)
+	assert.Equal(t, models.RequestReadyForPickup, request.Status)
+	assert.Nil(t, request.IssueID)
+	assert.Equal(t, 0, f.availableCopies(t))
+
+	w = f.checkout(t, request.ID)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, f.db.First(&request, request.ID).Error)
+	assert.Equal(t, models.RequestFulfilled, request.Status)
+	require.NotNil(t, request.IssueID)
+	assert.Equal(t, 0, f.availableCopies(t))
+
+	var loan models.IssueRegistry
+	require.NoError(t, f.db.First(&loan, *request.IssueID).Error)
+	assert.Equal(t, models.LoanIssued, loan.IssueStatus)
+
+	history, err := requests.History(f.db, request.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 4)
+	assert.Equal(t, models.RequestApproved, history[1].ToStatus)
+	assert.Equal(t, models.RequestReadyForPickup, history[2].ToStatus)
+	assert.Equal(t, models.RequestFulfilled, history[3].ToStatus)
+
+	// A second checkout cannot issue the book twice
+	assert.Equal(t, http.StatusConflict, f.checkout(t, request.ID).Code)
+}
+
+// ❌ Test a reader who became ineligible after approval cannot collect the book
+func TestCheckoutChecksEligibility(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	request := f.createRequest(t, f.reader)
+	w := f.serve(t, f.admin, http.MethodPut, "/issue/approve/:id", fmt.Sprintf("/issue/approve/%d", request.ID), "", controllers.ApproveIssue(f.db))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, f.db.Model(&f.reader).Update("status", models.AccountSuspended).Error)
+
+	assert.Equal(t, http.StatusForbidden, f.checkout(t, request.ID).Code)
+	require.NoError(t, f.db.First(&request, request.ID).Error)
+	assert.Equal(t, models.RequestReadyForPickup, request.Status)
+}
+
+// ✅ Test ListIssueRequests filters by status
+func TestListIssueRequestsStatusFilter(t *testing.T) {
+	f := newCirculationFixture(t, 2)
+	for i := 0; i < 2; i++ {
+		request := models.RequestEvent{BookID: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID, RequestDate: 1, RequestType: "issue"}
+		require.NoError(t, requests.Create(f.db, &request, &f.reader.ID))
+		if i == 0 {
+			w := f.serve(t, f.admin, http.MethodPut, "/issue/disapprove/:id", fmt.Sprintf("/issue/disapprove/%d", request.ID), `{"reason": "Damaged"}`, controllers.DisapproveIssue(f.db))
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		}
+	}
+
+	// Requests in libraries the admin does not manage are not listed
+	other := models.RequestEvent{BookID: f.book.ISBN, LibraryID: f.library.ID + 1, ReaderID: f.reader.ID, RequestDate: 1, RequestType: "issue"}
+	require.NoError(t, requests.Create(f.db, &other, &f.reader.ID))
+
+	list := func(query string) []map[string]interface{} {
+		w := f.serve(t, f.admin, http.MethodGet, "/issues", "/issues"+query, "", controllers.ListIssueRequests(f.db))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Requests []map[string]interface{} `json:"requests"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Requests
+	}
+
+	assert.Len(t, list(""), 2)
+	rejected := list("?status=rejected")
+	require.Len(t, rejected, 1)
+	assert.Equal(t, "Damaged", rejected[0]["rejection_reason"])
+	assert.Len(t, list("?status=pending"), 1)
+
+	w := f.serve(t, f.admin, http.MethodGet, "/issues", "/issues?status=lost", "", controllers.ListIssueRequests(f.db))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
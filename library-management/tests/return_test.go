@@ -0,0 +1,221 @@
+package tests
+
+import (
+	"fmt"
+	"library-management/controllers"
+	"library-management/middleware"
+	"library-management/migrations"
+	"library-management/models"
+	"library-management/services/requests"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withUser simulates AuthMiddleware by placing the caller's identity and
+// library memberships on the context
+func withUser(userID uint, role string, libraryIDs ...uint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("userID", userID)
+		c.Set("userRole", role)
+		c.Set("libraryIDs", libraryIDs)
+		c.Next()
+	}
+}
+
+// ✅ Test RequestReturn creates a return request for an active loan
+func TestRequestReturn(t *testing.T) {
+	SetupTestDatabase()
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/return", withUser(2, "user", 1), controllers.RequestReturn(TestDB))
+
+	mock.ExpectQuery(`SELECT \* FROM "issue_registries" WHERE \(reader_id = \$1 AND isbn = \$2 AND library_id = \$3 AND issue_status IN \(\$4,\$5\)\)`).
+		WithArgs(2, "9780134685991", 1, "issued", "overdue", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "reader_id", "issue_status"}).
+			AddRow(7, "9780134685991", 1, 2, "issued"))
+
+	mock.ExpectQuery(`SELECT \* FROM "request_events" WHERE \(issue_id = \$1 AND request_type = \$2 AND status IN \(\$3,\$4,\$5\)\)`).
+		WithArgs(7, "return", "pending", "approved", "ready_for_pickup", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "request_events"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`INSERT INTO "request_transitions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	req, _ := http.NewRequest(http.MethodPost, "/return", strings.NewReader(`{"isbn": "978-0-13-468599-1", "libraryid": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), "Return request submitted")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// ❌ Test RequestReturn without an active loan
+func TestRequestReturnNoLoan(t *testing.T) {
+	SetupTestDatabase()
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/return", withUser(2, "user", 1), controllers.RequestReturn(TestDB))
+
+	mock.ExpectQuery(`SELECT \* FROM "issue_registries"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	req, _ := http.NewRequest(http.MethodPost, "/return", strings.NewReader(`{"isbn": "978-0-13-468599-1", "libraryid": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "No active loan found")
+}
+
+// ✅ Test ApproveReturn closes the loan and restores the copy
+func TestApproveReturn(t *testing.T) {
+	SetupTestDatabase()
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.PUT("/return/approve/:id", withUser(1, "admin", 1), controllers.ApproveReturn(TestDB))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "request_events" WHERE "request_events"."id" = \$1 .* FOR UPDATE`).
+		WithArgs("3", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "library_id", "reader_id", "request_type", "issue_id", "status"}).
+			AddRow(3, "12345", 1, 2, "return", 7, "pending"))
+	expectTransition()
+	mock.ExpectQuery(`SELECT \* FROM "issue_registries" WHERE "issue_registries"."id" = \$1 .* FOR UPDATE`).
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "reader_id", "item_id", "issue_status", "expected_return_date"}).
+			AddRow(7, "12345", 1, 2, 9, "issued", time.Now().AddDate(0, 0, 7).Unix()))
+	mock.ExpectExec(`UPDATE "issue_registries" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	onLoan := sqlmock.NewRows([]string{"id", "barcode", "book_id", "library_id", "status"}).AddRow(9, "1-12345-001", 5, 1, "on_loan")
+	mock.ExpectQuery(`SELECT \* FROM "items" WHERE "items"."id" = \$1`).WithArgs(9, 1).WillReturnRows(onLoan)
+	mock.ExpectQuery(`SELECT \* FROM "books" WHERE "books"."id" = \$1 .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "total_copies", "available_copies"}).AddRow(5, "12345", 1, 3, 2))
+	mock.ExpectQuery(`SELECT \* FROM "items" WHERE "items"."id" = \$1 .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "barcode", "book_id", "library_id", "status"}).AddRow(9, "1-12345-001", 5, 1, "on_loan"))
+	mock.ExpectExec(`UPDATE "items" SET "status"=\$1`).WithArgs("available", sqlmock.AnyArg(), 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRecount("available", 3)
+	mock.ExpectExec(`SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectBookLock(3, 3)
+	expectNoHolds()
+	expectTransition()
+	mock.ExpectCommit()
+
+	req, _ := http.NewRequest(http.MethodPut, "/return/approve/3", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Return request approved")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// ❌ Test ApproveReturn refuses issue requests
+func TestApproveReturnRejectsIssueRequest(t *testing.T) {
+	SetupTestDatabase()
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.PUT("/return/approve/:id", withUser(1, "admin", 1), controllers.ApproveReturn(TestDB))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "request_events"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "library_id", "reader_id", "request_type"}).
+			AddRow(3, "12345", 1, 2, "issue"))
+	mock.ExpectRollback()
+
+	req, _ := http.NewRequest(http.MethodPut, "/return/approve/3", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Request is not a return request")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// ✅ Test ApproveReturn closes an old loan that has no copy recorded
+func TestApproveReturnWithoutItem(t *testing.T) {
+	f := newCirculationFixture(t, 1)
+	loan := models.IssueRegistry{ISBN: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID, IssueApproverID: f.admin.ID,
+		IssueStatus: "issued", IssueDate: time.Now().AddDate(0, 0, -7).Unix(), ExpectedReturnDate: time.Now().AddDate(0, 0, 7).Unix()}
+	require.NoError(t, f.db.Create(&loan).Error)
+	request := models.RequestEvent{BookID: f.book.ISBN, LibraryID: f.library.ID, ReaderID: f.reader.ID, RequestDate: time.Now().Unix(),
+		RequestType: "return", IssueID: &loan.ID}
+	require.NoError(t, requests.Create(f.db, &request, &f.reader.ID))
+
+	w := f.serve(t, f.admin, http.MethodPut, "/return/approve/:id", fmt.Sprintf("/return/approve/%d", request.ID), "", controllers.ApproveReturn(f.db))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	require.NoError(t, f.db.First(&loan, loan.ID).Error)
+	assert.Equal(t, models.LoanReturned, loan.IssueStatus)
+	assert.Equal(t, 1, f.availableCopies(t))
+}
+
+// ✅ Test flagged loans can be listed and assigned a library so they can be returned
+func TestRepairFlaggedLoan(t *testing.T) {
+	db := SetupSQLiteDatabase(t)
+	migrateDownTo(t, db, 10)
+
+	require.NoError(t, db.Exec(`INSERT INTO books (id, isbn, title, library_id, total_copies, available_copies, created_at)
+		VALUES (1, '67890', 'Rust', 1, 1, 1, CURRENT_TIMESTAMP), (2, '67890', 'Rust', 2, 1, 0, CURRENT_TIMESTAMP)`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO issue_registries (id, isbn, reader_id, issue_approver_id, issue_status, issue_date, expected_return_date)
+		VALUES (1, '67890', 2, 1, 'issued', 1, 2)`).Error)
+	_, err := migrations.Up(db)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	serve := func(method, route, path, body string, libraryIDs ...uint) *httptest.ResponseRecorder {
+		r := gin.New()
+		handlers := []gin.HandlerFunc{withUser(1, "admin", libraryIDs...)}
+		if method == http.MethodPut {
+			handlers = append(handlers, middleware.RequireLibraryRole("admin|owner", middleware.LibraryFromBody("library_id")), controllers.RepairLoan(db))
+		} else {
+			handlers = append(handlers, controllers.ListLoansNeedingRepair(db))
+		}
+		r.Handle(method, route, handlers...)
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Only admins of a library holding the ISBN see the loan
+	w := serve(http.MethodGet, "/loans/repairs", "/loans/repairs", "", 3)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"loans": []}`, w.Body.String())
+	w = serve(http.MethodGet, "/loans/repairs", "/loans/repairs", "", 2)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"needs_repair":true`)
+
+	w = serve(http.MethodPut, "/loans/:id/repair", "/loans/1/repair", `{"library_id": 2}`, 2)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var loan models.IssueRegistry
+	require.NoError(t, db.First(&loan, 1).Error)
+	assert.Equal(t, uint(2), loan.LibraryID)
+	assert.False(t, loan.NeedsRepair)
+	var item models.Item
+	require.NoError(t, db.Where("book_id = ?", 2).First(&item).Error)
+	require.NotNil(t, loan.ItemID)
+	assert.Equal(t, item.ID, *loan.ItemID)
+
+	w = serve(http.MethodPut, "/loans/:id/repair", "/loans/1/repair", `{"library_id": 2}`, 2)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
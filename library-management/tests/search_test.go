@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"encoding/json"
+	"library-management/controllers"
+	"library-management/models"
+	"library-management/services/inventory"
+	"library-management/services/search"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type searchPage struct {
+	Books []struct {
+		ISBN  string `json:"isbn"`
+		Title string `json:"title"`
+	} `json:"books"`
+	Total      int64 `json:"total"`
+	Limit      int   `json:"limit"`
+	Offset     int   `json:"offset"`
+	NextOffset *int  `json:"next_offset"`
+}
+
+// searchFixture adds books to the fixture library so that "go" is in one
+// title, one author list and one category
+func searchFixture(t *testing.T) circulationFixture {
+	f := newCirculationFixture(t, 1)
+	for _, book := range []models.Book{
+		{ISBN: "9781492052593", Title: "Learning Go", Authors: "Jon Bodner", Publisher: "O'Reilly", Category: "Programming"},
+		{ISBN: "9780804429573", Title: "Gardening", Authors: "Gordon Goings", Category: "Hobbies"},
+		{ISBN: "9780306406157", Title: "Systems", Authors: "Ann Lee", Category: "Go programming"},
+	} {
+		book.LibraryID = f.library.ID
+		_, _, err := inventory.AddCopies(f.db, book, make([]inventory.NewItem, 1))
+		require.NoError(t, err)
+	}
+	return f
+}
+
+func (f circulationFixture) search(t *testing.T, query string) (int, searchPage) {
+	t.Helper()
+	w := f.serve(t, f.reader, http.MethodGet, "/books/search", "/books/search?"+query, "", controllers.SearchBooks(f.db))
+	var page searchPage
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	}
+	return w.Code, page
+}
+
+func titles(page searchPage) []string {
+	var titles []string
+	for _, book := range page.Books {
+		titles = append(titles, book.Title)
+	}
+	return titles
+}
+
+// ✅ Test field filters match anywhere in the field whatever the case
+func TestSearchBooksFieldFilters(t *testing.T) {
+	f := searchFixture(t)
+
+	tests := []struct {
+		name   string
+		query  string
+		titles []string
+	}{
+		{"lower case title", "title=effective", []string{"Effective Java"}},
+		{"upper case author", "author=BLOCH", []string{"Effective Java"}},
+		{"mixed case publisher", "publisher=o%27rEILLY", []string{"Learning Go"}},
+		{"middle of a category", "category=programm", []string{"Learning Go", "Systems"}},
+		{"filters combine", "title=effective&author=BLOCH", []string{"Effective Java"}},
+		{"filters exclude each other", "title=effective&author=bodner", nil},
+		{"blank filter is ignored", "title=+", []string{"Effective Java", "Gardening", "Learning Go", "Systems"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, page := f.search(t, tt.query)
+			require.Equal(t, http.StatusOK, code)
+			assert.Equal(t, tt.titles, titles(page))
+		})
+	}
+}
+
+// ✅ Test %, _ and \ in a query match only themselves
+func TestSearchBooksLiteralWildcards(t *testing.T) {
+	f := newCirculationFixture(t, 0)
+	for _, book := range []models.Book{
+		{ISBN: "9780262033848", Title: "100% Go"},
+		{ISBN: "9780131103627", Title: "1000 Go Puzzles"},
+		{ISBN: "9781492052593", Title: "snake_case Style"},
+		{ISBN: "9780804429573", Title: "snakeXcase Style"},
+		{ISBN: "9780306406157", Title: `C:\Windows Internals`},
+	} {
+		book.LibraryID = f.library.ID
+		_, _, err := inventory.AddCopies(f.db, book, make([]inventory.NewItem, 1))
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		titles []string
+	}{
+		{"percent in text", "q=100%25", []string{"100% Go"}},
+		{"percent in title filter", "title=100%25", []string{"100% Go"}},
+		{"underscore", "title=snake_case", []string{"snake_case Style"}},
+		{"backslash", "q=c:%5Cwindows", []string{`C:\Windows Internals`}},
+		{"lone percent", "q=%25", []string{"100% Go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, page := f.search(t, tt.query)
+			require.Equal(t, http.StatusOK, code)
+			assert.Equal(t, tt.titles, titles(page))
+		})
+	}
+}
+
+// ✅ Test free-text search ranks title matches above author and category matches
+func TestSearchBooksRanksByRelevance(t *testing.T) {
+	f := searchFixture(t)
+
+	code, page := f.search(t, "q=go")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"Learning Go", "Gardening", "Systems"}, titles(page))
+	assert.EqualValues(t, 3, page.Total)
+
+	// Every word has to match somewhere
+	_, page = f.search(t, "q=go+bodner")
+	assert.Equal(t, []string{"Learning Go"}, titles(page))
+
+	// Field filters narrow the free-text matches
+	_, page = f.search(t, "q=go&category=programming")
+	assert.Equal(t, []string{"Learning Go", "Systems"}, titles(page))
+}
+
+// ✅ Test results are paged with a total and the offset of the next page
+func TestSearchBooksPaginates(t *testing.T) {
+	f := searchFixture(t)
+
+	_, page := f.search(t, "sort=title&limit=3")
+	assert.Equal(t, []string{"Effective Java", "Gardening", "Learning Go"}, titles(page))
+	assert.EqualValues(t, 4, page.Total)
+	assert.Equal(t, 3, page.Limit)
+	require.NotNil(t, page.NextOffset)
+	assert.Equal(t, 3, *page.NextOffset)
+
+	_, page = f.search(t, "sort=title&limit=3&offset=3")
+	assert.Equal(t, []string{"Systems"}, titles(page))
+	assert.Nil(t, page.NextOffset)
+
+	_, page = f.search(t, "sort=newest&limit=1")
+	assert.Equal(t, []string{"Systems"}, titles(page))
+
+	_, page = f.search(t, "")
+	assert.Equal(t, search.DefaultLimit, page.Limit)
+}
+
+// ❌ Test unknown sort orders and out-of-range pages are rejected
+func TestSearchBooksRejectsBadPaging(t *testing.T) {
+	f := searchFixture(t)
+
+	for _, query := range []string{"sort=popular", "limit=101", "limit=ten", "offset=-1"} {
+		code, _ := f.search(t, query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+// ✅ Test Postgres searches the weighted search_vector and orders by ts_rank
+func TestSearchBooksPostgresFullText(t *testing.T) {
+	SetupTestDatabase()
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "books" WHERE library_id IN \(\$1\) AND search_vector @@ websearch_to_tsquery\(\$2, \$3\)`).
+		WithArgs(1, "english", "effective java").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT id, isbn, .* WHERE library_id IN \(\$1\) AND search_vector @@ websearch_to_tsquery\(\$2, \$3\) .*`+
+		`ORDER BY ts_rank\(search_vector, websearch_to_tsquery\(\$4, \$5\)\) DESC, title, id LIMIT \$6`).
+		WithArgs(1, "english", "effective java", "english", "effective java", 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "title"}).AddRow(5, "9780134685991", "Effective Java"))
+
+	result, err := search.Books(TestDB, search.Query{Text: "effective java", Libraries: []uint{1}})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, result.Total)
+	require.Len(t, result.Books, 1)
+	assert.Equal(t, "Effective Java", result.Books[0].Title)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,9 +3,13 @@ package tests
 import (
 	"database/sql"
 	"fmt"
+	"library-management/config"
+	"library-management/migrations"
 	"log"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"gorm.io/driver/postgres"
@@ -43,6 +47,82 @@ func SetupTestDatabase() {
 	fmt.Println("✅ GORM Initialized") // Debugging
 }
 
+// ✅ SetupSQLiteDatabase opens a migrated in-memory SQLite database for one test
+func SetupSQLiteDatabase(t *testing.T) *gorm.DB {
+	return openSQLiteDatabase(t, ":memory:")
+}
+
+// openSQLiteDatabase connects through config.ConnectDatabase and applies the
+// migrations so tests exercise the same driver selection and schema as the server
+func openSQLiteDatabase(t *testing.T, dsn string) *gorm.DB {
+	return openTestDatabase(t, "sqlite", dsn)
+}
+
+// openPostgresDatabase opens the Postgres server named by TEST_POSTGRES_DSN in a
+// fresh schema that is dropped after the test. Tests that need real row locks
+// use it; they are skipped when the variable is unset.
+func openPostgresDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("set TEST_POSTGRES_DSN to run tests against Postgres")
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("❌ Failed to open Postgres database: %v", err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("❌ Failed to create test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return openTestDatabase(t, "postgres", withSearchPath(dsn, schema))
+}
+
+// withSearchPath points every connection of a DSN, in URL or key=value form,
+// at one schema
+func withSearchPath(dsn, schema string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&search_path=" + schema
+	}
+	return dsn + "?search_path=" + schema
+}
+
+func openTestDatabase(t *testing.T, driver, dsn string) *gorm.DB {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.DatabaseDriver = driver
+	cfg.DatabaseDSN = dsn
+	cfg.LogLevel = "error"
+
+	db, err := config.ConnectDatabase(cfg)
+	if err != nil {
+		t.Fatalf("❌ Failed to open %s database: %v", driver, err)
+	}
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatalf("❌ Failed to migrate %s database: %v", driver, err)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
 // ✅ TestMain runs before all tests
 func TestMain(m *testing.M) {
 	os.Setenv("TEST_MODE", "true") // ✅ Set test mode
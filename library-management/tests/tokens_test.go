@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"encoding/json"
+	"library-management/controllers"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type tokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// newAuthRouter wires the auth endpoints plus one protected route on SQLite
+func newAuthRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
+	db := SetupSQLiteDatabase(t)
+
+	hash, err := utils.HashPassword("password123")
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&models.User{Name: "Reader", Email: "reader@example.com", Role: "user", Password: hash}).Error)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/auth/login", controllers.Login(db))
+	r.POST("/auth/refresh", controllers.RefreshToken(db))
+	r.POST("/auth/logout", middleware.AuthMiddleware(db, ""), controllers.Logout(db))
+	r.GET("/protected", middleware.AuthMiddleware(db, "user"), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "Success"})
+	})
+	return r, db
+}
+
+func authRequest(r *gin.Engine, method, path, accessToken, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decodePair(t *testing.T, w *httptest.ResponseRecorder) tokenPair {
+	t.Helper()
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var pair tokenPair
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pair))
+	require.NotEmpty(t, pair.Token)
+	require.NotEmpty(t, pair.RefreshToken)
+	return pair
+}
+
+func login(t *testing.T, r *gin.Engine) tokenPair {
+	return decodePair(t, authRequest(r, http.MethodPost, "/auth/login", "", `{"email": "reader@example.com", "password": "password123"}`))
+}
+
+func refreshBody(token string) string {
+	return `{"refresh_token": "` + token + `"}`
+}
+
+// ✅ Test a refresh token can be exchanged once for a new working pair
+func TestRefreshTokenRotation(t *testing.T) {
+	r, db := newAuthRouter(t)
+	first := login(t, r)
+	assert.Positive(t, first.ExpiresIn)
+
+	second := decodePair(t, authRequest(r, http.MethodPost, "/auth/refresh", "", refreshBody(first.RefreshToken)))
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+
+	w := authRequest(r, http.MethodGet, "/protected", second.Token, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Only the hash of a refresh token is stored
+	var stored int64
+	require.NoError(t, db.Model(&models.RefreshToken{}).Where("token_hash = ?", second.RefreshToken).Count(&stored).Error)
+	assert.Zero(t, stored)
+}
+
+// ❌ Test reusing a refresh token revokes every token from that login
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	r, _ := newAuthRouter(t)
+	first := login(t, r)
+	second := decodePair(t, authRequest(r, http.MethodPost, "/auth/refresh", "", refreshBody(first.RefreshToken)))
+
+	w := authRequest(r, http.MethodPost, "/auth/refresh", "", refreshBody(first.RefreshToken))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "already been used")
+
+	// The legitimate successor is gone too
+	w = authRequest(r, http.MethodPost, "/auth/refresh", "", refreshBody(second.RefreshToken))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = authRequest(r, http.MethodGet, "/protected", second.Token, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "revoked")
+
+	// A separate login is unaffected
+	other := login(t, r)
+	w = authRequest(r, http.MethodGet, "/protected", other.Token, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// ❌ Test an unknown refresh token is rejected
+func TestRefreshTokenUnknown(t *testing.T) {
+	r, _ := newAuthRouter(t)
+
+	w := authRequest(r, http.MethodPost, "/auth/refresh", "", refreshBody("not-a-real-token"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// ✅ Test logout revokes the access token and its refresh token
+func TestLogoutRevokesTokens(t *testing.T) {
+	r, _ := newAuthRouter(t)
+	pair := login(t, r)
+
+	w := authRequest(r, http.MethodPost, "/auth/logout", pair.Token, refreshBody(pair.RefreshToken))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = authRequest(r, http.MethodGet, "/protected", pair.Token, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "revoked")
+
+	w = authRequest(r, http.MethodPost, "/auth/refresh", "", refreshBody(pair.RefreshToken))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
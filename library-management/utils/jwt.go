@@ -1,32 +1,87 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// ✅ Securely define the JWT signing key (Do not expose this in public repositories)
-const jwtKey = "your_super_secret_key" // Use an environment variable in production
+// ✅ Signing key and token lifetime, set from the server configuration at startup
+var (
+	jwtKey   = "your_super_secret_key" // Development default, replaced by ConfigureJWT
+	tokenTTL = 15 * time.Minute
+)
+
+// ConfigureJWT sets the signing key and lifetime used for new tokens
+func ConfigureJWT(secret string, ttl time.Duration) {
+	jwtKey = secret
+	tokenTTL = ttl
+}
+
+// TokenClaims are the claims carried by an access token
+type TokenClaims struct {
+	UserID     uint
+	Role       string
+	LibraryIDs []uint // Libraries the user belonged to when the token was issued
+	JTI        string // Unique token ID, used to revoke the token
+	ExpiresAt  time.Time
+}
 
 // ✅ GenerateJWT creates a secure JWT token for authentication
 func GenerateJWT(userID uint, role string) (string, error) {
+	token, _, err := GenerateAccessToken(userID, role, nil)
+	return token, err
+}
+
+// GenerateAccessToken creates a signed access token scoped to the given
+// libraries and returns its claims
+func GenerateAccessToken(userID uint, role string, libraryIDs []uint) (string, TokenClaims, error) {
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", TokenClaims{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(tokenTTL)
+	if libraryIDs == nil {
+		libraryIDs = []uint{}
+	}
 	claims := jwt.MapClaims{
-		"user_id": userID,
-		"role":    role,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(), // Token expires in 1 day
-		"iat":     time.Now().Unix(),                     // Issued at time
-		"nbf":     time.Now().Unix(),                     // Not valid before now
+		"user_id":   userID,
+		"role":      role,
+		"libraries": libraryIDs,
+		"jti":       jti,
+		"exp":       expiresAt.Unix(), // Token expires after the configured TTL
+		"iat":       now.Unix(),       // Issued at time
+		"nbf":       now.Unix(),       // Not valid before now
 	}
 
 	// Create a new token with the claims and sign it
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(jwtKey))
+	signed, err := token.SignedString([]byte(jwtKey))
+	if err != nil {
+		return "", TokenClaims{}, err
+	}
+
+	return signed, TokenClaims{UserID: userID, Role: role, LibraryIDs: libraryIDs, JTI: jti, ExpiresAt: time.Unix(expiresAt.Unix(), 0)}, nil
 }
 
 // ✅ ValidateJWT verifies and extracts claims from a JWT token
 func ValidateJWT(tokenString string) (uint, string, error) {
+	claims, err := ParseJWT(tokenString)
+	if err != nil {
+		return 0, "", err
+	}
+	return claims.UserID, claims.Role, nil
+}
+
+// ParseJWT verifies a token and returns all of its claims
+func ParseJWT(tokenString string) (TokenClaims, error) {
 	claims := jwt.MapClaims{}
 
 	// Parse the token with claims
@@ -40,19 +95,50 @@ func ValidateJWT(tokenString string) (uint, string, error) {
 
 	// Check if token is valid
 	if err != nil || !token.Valid {
-		return 0, "", errors.New("invalid or expired token")
+		return TokenClaims{}, errors.New("invalid or expired token")
 	}
 
 	// Extract userID and role
 	userIDFloat, ok := claims["user_id"].(float64)
 	if !ok {
-		return 0, "", errors.New("invalid user_id claim")
+		return TokenClaims{}, errors.New("invalid user_id claim")
 	}
 
 	role, ok := claims["role"].(string)
 	if !ok {
-		return 0, "", errors.New("invalid role claim")
+		return TokenClaims{}, errors.New("invalid role claim")
+	}
+
+	parsed := TokenClaims{UserID: uint(userIDFloat), Role: role}
+	parsed.JTI, _ = claims["jti"].(string)
+	if libraries, ok := claims["libraries"].([]interface{}); ok {
+		parsed.LibraryIDs = make([]uint, 0, len(libraries))
+		for _, library := range libraries {
+			id, ok := library.(float64)
+			if !ok {
+				return TokenClaims{}, errors.New("invalid libraries claim")
+			}
+			parsed.LibraryIDs = append(parsed.LibraryIDs, uint(id))
+		}
 	}
+	if exp, ok := claims["exp"].(float64); ok {
+		parsed.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+
+	return parsed, nil
+}
+
+// RandomToken returns n random bytes encoded for use in URLs and headers
+func RandomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
 
-	return uint(userIDFloat), role, nil
+// HashToken returns the SHA-256 digest under which an opaque token is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }